@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dcos/dcos-go/dcos/nodeutil"
+	"github.com/dcos/dcos-log/dcos-log/config"
+	"github.com/dcos/dcos-log/dcos-log/journal/reader"
+	"github.com/dcos/dcos-log/dcos-log/version"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	statusOK     = "ok"
+	statusFailed = "failed"
+)
+
+var startTime = time.Now()
+
+// check is a single readiness check. The context carries the request headers and a deadline.
+type check struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+type checkResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Uptime string                 `json:"uptime,omitempty"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Errorf("unable to encode response: %s", err)
+	}
+}
+
+// healthHandler indicates the process is alive and able to serve requests.
+func healthHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{
+		Status: statusOK,
+		Uptime: time.Since(startTime).String(),
+	})
+}
+
+func versionHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, version.Get())
+}
+
+// runChecks executes the checks concurrently and returns the results by check name.
+func runChecks(ctx context.Context, checks []check) (map[string]checkResult, bool) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]checkResult, len(checks))
+		ok      = true
+	)
+
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := c.fn(ctx)
+			result := checkResult{
+				Status:   statusOK,
+				Duration: time.Since(start).String(),
+			}
+
+			if err != nil {
+				result.Status = statusFailed
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[c.name] = result
+			ok = ok && err == nil
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	return results, ok
+}
+
+// newReadyHandler returns a handler which runs the checks with the timeout returned by timeout function
+// and responds with 200 if all checks passed, 503 otherwise.
+func newReadyHandler(checks []check, timeout func() time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout())
+		defer cancel()
+
+		// pass the caller's authorization to the checks which talk to mesos.
+		if token := req.Header.Get("Authorization"); token != "" {
+			header := http.Header{}
+			header.Set("Authorization", token)
+			ctx = nodeutil.NewContextWithHeaders(ctx, header)
+		}
+
+		results, ok := runChecks(ctx, checks)
+
+		resp := healthResponse{
+			Status: statusOK,
+			Checks: results,
+		}
+		code := http.StatusOK
+
+		if !ok {
+			resp.Status = statusFailed
+			code = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, code, resp)
+	})
+}
+
+// blockingCall runs a function which does not accept a context in a goroutine, so the caller can give up once
+// the context is done. At most one call runs at a time, the callers arriving while it runs wait for its result.
+// A hanging function keeps one goroutine instead of leaking one on every timed out check.
+type blockingCall struct {
+	fn func() error
+
+	mu      sync.Mutex
+	running *callResult
+}
+
+type callResult struct {
+	done chan struct{}
+	err  error
+}
+
+func newBlockingCall(fn func() error) *blockingCall {
+	return &blockingCall{fn: fn}
+}
+
+// run returns the result of the running or a new call, or ctx.Err() if the call does not finish before
+// the context is done.
+func (c *blockingCall) run(ctx context.Context) error {
+	c.mu.Lock()
+	r := c.running
+	if r == nil {
+		r = &callResult{done: make(chan struct{})}
+		c.running = r
+
+		go func() {
+			r.err = c.fn()
+
+			c.mu.Lock()
+			c.running = nil
+			c.mu.Unlock()
+			close(r.done)
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func journalCheck() error {
+	j, err := reader.NewReader(nil)
+	if err != nil {
+		return err
+	}
+	return j.Close()
+}
+
+// localChecks returns the checks of the node itself, unlike the mesos checks they do not fail when
+// a remote service is unavailable. They are also used to ping the systemd watchdog.
+func localChecks(nodeInfo nodeutil.NodeInfo) []check {
+	return []check{
+		{
+			name: "journal",
+			fn:   newBlockingCall(journalCheck).run,
+		},
+		{
+			name: "detect_ip",
+			fn: newBlockingCall(func() error {
+				_, err := nodeInfo.DetectIP()
+				return err
+			}).run,
+		},
+	}
+}
+
+// readinessChecks returns the checks used by /ready endpoint.
+func readinessChecks(cfg *config.Config, client *http.Client, nodeInfo nodeutil.NodeInfo) []check {
+	return append(localChecks(nodeInfo), []check{
+		{
+			name: "mesos_id",
+			fn: func(ctx context.Context) error {
+				_, err := nodeInfo.MesosID(ctx)
+				return err
+			},
+		},
+		{
+			name: "files_api",
+			fn: func(ctx context.Context) error {
+				return filesAPICheck(ctx, cfg, client, nodeInfo)
+			},
+		},
+	}...)
+}
+
+// filesAPICheck makes a request to the local mesos files API. Any HTTP response means the API
+// is reachable, the authorization is validated on every user request.
+func filesAPICheck(ctx context.Context, cfg *config.Config, client *http.Client, nodeInfo nodeutil.NodeInfo) error {
+	ip, err := nodeInfo.DetectIP()
+	if err != nil {
+		return err
+	}
+
+	filesURL := url.URL{
+		Scheme: cfg.MesosScheme(),
+		Host:   net.JoinHostPort(ip.String(), strconv.Itoa(cfg.MesosPort())),
+		Path:   "/files/debug",
+	}
+
+	req, err := http.NewRequest("GET", filesURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if header, ok := nodeutil.HeaderFromContext(ctx); ok {
+		req.Header = header
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
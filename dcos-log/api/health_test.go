@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/version"
+)
+
+func serve(t *testing.T, h http.Handler, path string) (*httptest.ResponseRecorder, healthResponse) {
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var resp healthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return w, resp
+}
+
+func fixedTimeout(timeout time.Duration) func() time.Duration {
+	return func() time.Duration {
+		return timeout
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	w, resp := serve(t, http.HandlerFunc(healthHandler), "/health")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200. Got %d", w.Code)
+	}
+
+	if resp.Status != statusOK {
+		t.Fatalf("expect status %s. Got %s", statusOK, resp.Status)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	okCheck := check{name: "ok", fn: func(context.Context) error { return nil }}
+	failedCheck := check{name: "failed", fn: func(context.Context) error { return errors.New("broken") }}
+	slowCheck := check{name: "slow", fn: newBlockingCall(func() error {
+		time.Sleep(time.Second)
+		return nil
+	}).run}
+
+	w, resp := serve(t, newReadyHandler([]check{okCheck}, fixedTimeout(time.Second)), "/ready")
+	if w.Code != http.StatusOK || resp.Status != statusOK {
+		t.Fatalf("expect 200 and status ok. Got %d, %+v", w.Code, resp)
+	}
+
+	w, resp = serve(t, newReadyHandler([]check{okCheck, failedCheck, slowCheck}, fixedTimeout(time.Millisecond*10)), "/ready")
+	if w.Code != http.StatusServiceUnavailable || resp.Status != statusFailed {
+		t.Fatalf("expect 503 and status failed. Got %d, %+v", w.Code, resp)
+	}
+
+	if resp.Checks["ok"].Status != statusOK {
+		t.Fatalf("expect check ok to pass. Got %+v", resp.Checks["ok"])
+	}
+
+	if result := resp.Checks["failed"]; result.Status != statusFailed || result.Error != "broken" {
+		t.Fatalf("expect check failed to fail with error broken. Got %+v", result)
+	}
+
+	if result := resp.Checks["slow"]; result.Status != statusFailed || result.Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("expect check slow to time out. Got %+v", result)
+	}
+}
+
+func TestBlockingCall(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := newBlockingCall(func() error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return errors.New("done")
+	})
+
+	// the timed out checks wait for the same hanging call.
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if err := c.run(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("expect a timeout. Got %v", err)
+		}
+		cancel()
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expect 1 call. Got %d", n)
+	}
+
+	close(release)
+	if err := c.run(context.Background()); err == nil || err.Error() != "done" {
+		t.Fatalf("expect the call result. Got %v", err)
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	req, err := http.NewRequest("GET", "/version", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	versionHandler(w, req)
+
+	var info version.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if info != version.Get() {
+		t.Fatalf("expect %+v. Got %+v", version.Get(), info)
+	}
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dcos/dcos-log/dcos-log/limit"
+)
+
+// newLimitsHandler returns a handler responding with the configured limits and the number of open streams.
+// The endpoint is not authenticated, so the streams per client, keyed by the principals and addresses,
+// are not included.
+func newLimitsHandler(limiter *limit.Limiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		usage := limiter.Usage()
+		usage.Clients = nil
+		writeJSON(w, http.StatusOK, usage)
+	})
+}
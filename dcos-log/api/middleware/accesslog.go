@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is a header used to pass a request ID between a client and dcos-log.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen limits the length of the request ID provided by a client.
+const maxRequestIDLen = 128
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID makes sure the client provided request ID is safe to put in logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the request ID from the request context. An empty string is returned if the request
+// did not pass AccessLog middleware.
+func RequestID(r *http.Request) string {
+	id, _ := FromContextRequestID(r.Context())
+	return id
+}
+
+// AccessLog is a middleware that assigns a request ID and logs every request once it is served.
+// The request ID is taken from X-Request-ID header if a client provided a valid one, otherwise a new one
+// is generated. The ID is available to the handlers via FromContextRequestID and returned to a client in
+// X-Request-ID response header. It must wrap a handler registered with a mux route. The principal verified by
+// the wrapped Principal middleware is logged once the request is served.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		var route string
+		if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+			route, _ = currentRoute.GetPathTemplate()
+		}
+
+		stream := IsStream(r, route)
+		fields := logrus.Fields{
+			"request_id":  requestID,
+			"method":      r.Method,
+			"route":       route,
+			"path":        r.URL.Path,
+			"remote_addr": r.RemoteAddr,
+			"stream":      stream,
+		}
+
+		if stream {
+			logrus.WithFields(fields).Info("stream opened")
+		}
+
+		// the principal is verified by Principal middleware once the request ID is assigned.
+		var principal string
+		ctx := WithRequestIDContext(r.Context(), requestID)
+		ctx = withKeyContext(ctx, accessLogPrincipalKey, &principal)
+
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		// the uid claim of a token which is not verified is logged apart, it may be forged.
+		fields["principal"] = principal
+		if token, err := GetAuthFromRequest(r); err == nil && principal == "" {
+			fields["claimed_principal"] = PrincipalFromToken(token)
+		}
+
+		fields["status"] = rw.Code()
+		fields["bytes"] = rw.Bytes()
+		fields["duration"] = time.Since(start).String()
+
+		entry := logrus.WithFields(fields)
+		msg := "request served"
+		if stream {
+			msg = "stream closed"
+		}
+
+		if rw.Code() >= http.StatusInternalServerError {
+			entry.Warn(msg)
+			return
+		}
+		entry.Info(msg)
+	})
+}
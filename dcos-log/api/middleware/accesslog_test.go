@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func newAccessLogRouter(h http.HandlerFunc) *mux.Router {
+	r := mux.NewRouter()
+	r.Path("/test/{name}").Handler(AccessLog(h))
+	return r
+}
+
+func TestAccessLogRequestID(t *testing.T) {
+	var handlerRequestID string
+	r := newAccessLogRouter(func(w http.ResponseWriter, req *http.Request) {
+		handlerRequestID = RequestID(req)
+	})
+
+	// generated request ID
+	req, err := http.NewRequest("GET", "/test/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	requestID := w.Header().Get(RequestIDHeader)
+	if requestID == "" || requestID != handlerRequestID {
+		t.Fatalf("expect the same request ID in response header and handler. Got %q and %q", requestID,
+			handlerRequestID)
+	}
+
+	// propagated request ID
+	req.Header.Set(RequestIDHeader, "client-id-1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if id := w.Header().Get(RequestIDHeader); id != "client-id-1" || handlerRequestID != "client-id-1" {
+		t.Fatalf("expect client request ID to be propagated. Got %q and %q", id, handlerRequestID)
+	}
+
+	// invalid request ID is replaced
+	req.Header.Set(RequestIDHeader, "bad id")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if id := w.Header().Get(RequestIDHeader); id == "bad id" || id == "" {
+		t.Fatalf("expect invalid request ID to be replaced. Got %q", id)
+	}
+}
+
+func TestAccessLogErrorBody(t *testing.T) {
+	r := newAccessLogRouter(func(w http.ResponseWriter, req *http.Request) {
+		WriteError(w, req, http.StatusInternalServerError, "something went wrong", "")
+	})
+
+	req, err := http.NewRequest("GET", "/test/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(RequestIDHeader, "client-id-2")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expect 500. Got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), `"request_id":"client-id-2"`) {
+		t.Fatalf("expect request ID in error body. Got %s", w.Body.String())
+	}
+}
+
+func TestAccessLogPrincipal(t *testing.T) {
+	verifier, sign, key := newTestIAM(t)
+
+	r := mux.NewRouter()
+	r.Path("/test/{name}").Handler(AccessLog(Principal(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		verifier)))
+
+	buf := &bytes.Buffer{}
+	logrus.SetOutput(buf)
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+	defer func() {
+		logrus.SetOutput(os.Stderr)
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	}()
+
+	// the unverified uid claim is logged apart.
+	forged := "token=eyJhbGciOiJub25lIn0.eyJ1aWQiOiJib290c3RyYXB1c2VyIn0.c2ln"
+
+	for token, expected := range map[string][2]string{
+		sign(key, "bootstrapuser", time.Now().Add(time.Hour)): {"bootstrapuser", ""},
+		forged: {"", "bootstrapuser"},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/test/foo", nil)
+		req.Header.Set("Authorization", token)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		// the last line is the served request.
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		var entry struct {
+			Principal        string `json:"principal"`
+			ClaimedPrincipal string `json:"claimed_principal"`
+		}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+			t.Fatal(err)
+		}
+
+		if entry.Principal != expected[0] || entry.ClaimedPrincipal != expected[1] {
+			t.Fatalf("expect principal %q claimed %q. Got %+v", expected[0], expected[1], entry)
+		}
+	}
+}
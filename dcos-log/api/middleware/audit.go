@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/audit"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// auditResource builds audit.Resource from the mux variables. v1 and v2 endpoints use different
+// variable names for the same entities.
+func auditResource(vars map[string]string) audit.Resource {
+	first := func(names ...string) string {
+		for _, name := range names {
+			if v := vars[name]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	return audit.Resource{
+		FrameworkID: first("framework_id", "frameworkID"),
+		ExecutorID:  first("executor_id", "executorID"),
+		ContainerID: first("container_id", "containerID"),
+		TaskPath:    vars["taskPath"],
+		TaskID:      vars["taskID"],
+		File:        vars["file"],
+		Unit:        vars["name"],
+	}
+}
+
+// Audit is a middleware that emits an audit.Record for every request once the request is served.
+// It must wrap a handler registered with a mux route, the route template and mux variables
+// are used to identify the accessed logs. It must be wrapped by Principal to record the verified principal.
+func Audit(next http.Handler, logger audit.Logger) http.Handler {
+	if logger == nil {
+		panic("audit logger cannot be nil")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newResponseWriter(w)
+
+		next.ServeHTTP(rw, r)
+
+		record := audit.Record{
+			Time:       start,
+			RequestID:  RequestID(r),
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Endpoint:   r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Filters:    r.URL.Query()["filter"],
+			Resource:   auditResource(mux.Vars(r)),
+			Bytes:      rw.Bytes(),
+			Duration:   time.Since(start),
+			Code:       rw.Code(),
+		}
+
+		// the uid claim of a token which is not verified is recorded apart, it may be forged.
+		record.Principal = VerifiedPrincipal(r)
+		if token, err := GetAuthFromRequest(r); err == nil && record.Principal == "" {
+			record.ClaimedPrincipal = PrincipalFromToken(token)
+		}
+
+		if route := mux.CurrentRoute(r); route != nil {
+			record.Route, _ = route.GetPathTemplate()
+		}
+
+		if err := logger.Log(record); err != nil {
+			logrus.Errorf("unable to write audit record %s: %s", record, err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/audit"
+	"github.com/gorilla/mux"
+)
+
+type recordLogger struct {
+	records []audit.Record
+}
+
+func (l *recordLogger) Log(r audit.Record) error {
+	l.records = append(l.records, r)
+	return nil
+}
+
+func (l *recordLogger) Close() error { return nil }
+
+func TestAuditPrincipal(t *testing.T) {
+	verifier, sign, key := newTestIAM(t)
+
+	logger := &recordLogger{}
+	r := mux.NewRouter()
+	r.Path("/test/{name}").Handler(Principal(Audit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		logger), verifier))
+
+	// the unverified uid claim is not trusted.
+	forged := "token=eyJhbGciOiJub25lIn0.eyJ1aWQiOiJib290c3RyYXB1c2VyIn0.c2ln"
+
+	for token, expected := range map[string]audit.Record{
+		sign(key, "bootstrapuser", time.Now().Add(time.Hour)): {Principal: "bootstrapuser"},
+		forged: {ClaimedPrincipal: "bootstrapuser"},
+		"":     {},
+	} {
+		req := httptest.NewRequest("GET", "/test/foo", nil)
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		record := logger.records[len(logger.records)-1]
+		if record.Principal != expected.Principal || record.ClaimedPrincipal != expected.ClaimedPrincipal {
+			t.Fatalf("expect principal %q claimed %q. Got %q and %q", expected.Principal, expected.ClaimedPrincipal,
+				record.Principal, record.ClaimedPrincipal)
+		}
+	}
+}
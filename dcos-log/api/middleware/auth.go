@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -10,15 +12,13 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/dcos/dcos-go/dcos"
 	"github.com/dcos/dcos-go/dcos/nodeutil"
+	"github.com/dcos/dcos-log/dcos-log/config"
 	"github.com/gorilla/mux"
 )
 
 const (
-	sandboxURLScheme  = "https"
 	sandboxPath       = "/files/browse"
-	sandboxBrowsePath = "/var/lib/mesos/slave/slaves"
 	sandboxFrameworks = "frameworks"
 	sandboxExecutors  = "executors"
 	sandboxRuns       = "runs"
@@ -27,12 +27,7 @@ const (
 // ErrMissingToken is returned by GetAuthFromRequest when JWT is missing.
 var ErrMissingToken = errors.New("Missing token in auth request")
 
-func getSandboxURL(nodeInfo nodeutil.NodeInfo, role string) (*url.URL, error) {
-	mesosPort := dcos.PortMesosAgent
-	if role == dcos.RoleMaster {
-		mesosPort = dcos.PortMesosMaster
-	}
-
+func getSandboxURL(nodeInfo nodeutil.NodeInfo, cfg *config.Config) (*url.URL, error) {
 	detectedIP, err := nodeInfo.DetectIP()
 	if err != nil {
 		return nil, err
@@ -40,8 +35,8 @@ func getSandboxURL(nodeInfo nodeutil.NodeInfo, role string) (*url.URL, error) {
 
 	// prepare sandbox URL
 	sandboxBaseURL := &url.URL{
-		Scheme: sandboxURLScheme,
-		Host:   net.JoinHostPort(detectedIP.String(), strconv.Itoa(mesosPort)),
+		Scheme: cfg.MesosScheme(),
+		Host:   net.JoinHostPort(detectedIP.String(), strconv.Itoa(cfg.MesosPort())),
 		Path:   sandboxPath,
 	}
 
@@ -68,8 +63,36 @@ func GetAuthFromRequest(r *http.Request) (string, error) {
 	return "", ErrMissingToken
 }
 
+// PrincipalFromToken returns the `uid` claim of a DC/OS authentication token. The token signature is not
+// verified, the function must only be used to annotate requests which are authorized elsewhere.
+// An empty string is returned if the token cannot be decoded.
+func PrincipalFromToken(token string) string {
+	token = strings.TrimPrefix(token, "token=")
+
+	// JWT is in the format header.payload.signature
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+
+	claims := struct {
+		UID string `json:"uid"`
+	}{}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+
+	return claims.UID
+}
+
 // Auth is a middleware that validates a user has a valid JWT to access the given endpoint.
-func Auth(next http.Handler, client *http.Client, nodeInfo nodeutil.NodeInfo, role string) http.Handler {
+func Auth(next http.Handler, client *http.Client, nodeInfo nodeutil.NodeInfo, cfg *config.Config) http.Handler {
 	if nodeInfo == nil {
 		panic("nodeInfo cannot be nil")
 	}
@@ -79,7 +102,8 @@ func Auth(next http.Handler, client *http.Client, nodeInfo nodeutil.NodeInfo, ro
 		// sandbox with it. We authorize the request if sandbox returns 200.
 		token, err := GetAuthFromRequest(r)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Token error: %s", err.Error()), http.StatusUnauthorized)
+			authFailures.Inc("missing_token")
+			WriteError(w, r, http.StatusUnauthorized, "Token error", err.Error())
 			return
 		}
 
@@ -91,13 +115,15 @@ func Auth(next http.Handler, client *http.Client, nodeInfo nodeutil.NodeInfo, ro
 
 		// if we ended up in this handler without required mux variables, we are doing something wrong.
 		if frameworkID == "" || executorID == "" || containerID == "" {
-			http.Error(w, "Missing mux variables `frameworkID`, `executorID` or `containerID`", http.StatusBadRequest)
+			authFailures.Inc("missing_vars")
+			WriteError(w, r, http.StatusBadRequest, "Missing mux variables `frameworkID`, `executorID` or `containerID`", "")
 			return
 		}
 
-		sandboxBaseURL, err := getSandboxURL(nodeInfo, role)
+		sandboxBaseURL, err := getSandboxURL(nodeInfo, cfg)
 		if err != nil {
-			http.Error(w, "Unable to get sandboxBaseURL: "+err.Error(), http.StatusInternalServerError)
+			authFailures.Inc("sandbox_url")
+			WriteError(w, r, http.StatusInternalServerError, "Unable to get sandboxBaseURL", err.Error())
 			return
 		}
 
@@ -106,18 +132,20 @@ func Auth(next http.Handler, client *http.Client, nodeInfo nodeutil.NodeInfo, ro
 
 		mesosID, err := nodeInfo.MesosID(nodeutil.NewContextWithHeaders(nil, header))
 		if err != nil {
-			http.Error(w, "Unable to get mesosID: "+err.Error(), http.StatusInternalServerError)
+			authFailures.Inc("mesos_id")
+			WriteError(w, r, http.StatusInternalServerError, "Unable to get mesosID", err.Error())
 			return
 		}
 
-		// "/var/lib/mesos/slave/slaves/<mesos_id>/frameworks/<framework_id>/executors/<executor_id>/runs/<container_id>"
-		sandboxPath := filepath.Join(sandboxBrowsePath, mesosID, sandboxFrameworks, frameworkID, sandboxExecutors,
+		// "<sandbox_root>/<mesos_id>/frameworks/<framework_id>/executors/<executor_id>/runs/<container_id>"
+		sandboxPath := filepath.Join(cfg.FlagSandboxRoot, mesosID, sandboxFrameworks, frameworkID, sandboxExecutors,
 			executorID, sandboxRuns, containerID)
 		sandboxBaseURL.RawQuery = "path=" + url.QueryEscape(sandboxPath)
 
 		req, err := http.NewRequest("GET", sandboxBaseURL.String(), nil)
 		if err != nil {
-			http.Error(w, "Invalid request: "+err.Error(), http.StatusInternalServerError)
+			authFailures.Inc("invalid_request")
+			WriteError(w, r, http.StatusInternalServerError, "Invalid request", err.Error())
 			return
 		}
 
@@ -125,7 +153,8 @@ func Auth(next http.Handler, client *http.Client, nodeInfo nodeutil.NodeInfo, ro
 
 		resp, err := client.Do(req)
 		if err != nil {
-			http.Error(w, "Could not make auth request: "+err.Error(), http.StatusInternalServerError)
+			authFailures.Inc("auth_request")
+			WriteError(w, r, http.StatusBadGateway, "Could not make auth request", err.Error())
 			return
 		}
 
@@ -134,7 +163,9 @@ func Auth(next http.Handler, client *http.Client, nodeInfo nodeutil.NodeInfo, ro
 		resp.Body.Close()
 
 		if responseCode != http.StatusOK {
-			http.Error(w, fmt.Sprintf("Auth URL %s. Invalid auth response code: %d", sandboxBaseURL.String(), responseCode), responseCode)
+			authFailures.Inc("denied")
+			WriteError(w, r, responseCode, fmt.Sprintf("Invalid auth response code: %d", responseCode),
+				"Auth URL "+sandboxBaseURL.String())
 			return
 		}
 		next.ServeHTTP(w, r)
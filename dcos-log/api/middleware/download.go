@@ -1,23 +1,64 @@
 package middleware
 
 import (
+	"compress/gzip"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 
-	"compress/gzip"
 	"github.com/gorilla/mux"
 )
 
-// Gzip Compression
+// gzipResponseWriter compresses the response body. The compression is skipped for the responses without
+// a downloadable content, so that a client receives errors as is and not as a gzipped attachment.
 type gzipResponseWriter struct {
-	io.Writer
 	http.ResponseWriter
+
+	gz          *gzip.Writer
+	wroteHeader bool
+	plain       bool
 }
 
-func (w gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
+func (w *gzipResponseWriter) headerWritten() bool {
+	return w.wroteHeader
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code == http.StatusNoContent || code >= http.StatusBadRequest {
+			w.plain = true
+			w.Header().Del("Content-disposition")
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.plain {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.gz == nil {
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	return w.gz.Write(b)
+}
+
+// Close flushes the compressed content. An empty gzip archive is sent if a handler did not write anything.
+func (w *gzipResponseWriter) Close() error {
+	if w.plain {
+		return nil
+	}
+
+	if w.gz == nil {
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	return w.gz.Close()
 }
 
 // DownloadGzippedContent is a middleware which sets Content-disposition header and makes a postfix
@@ -47,9 +88,8 @@ func DownloadGzippedContent(next http.Handler, prefix string, vars ...string) ht
 
 		f := fmt.Sprintf("%s.log.gz", filename)
 		w.Header().Add("Content-disposition", "attachment; filename="+f)
-		gz := gzip.NewWriter(w)
-		defer gz.Close()
-		gzw := gzipResponseWriter{Writer: gz, ResponseWriter: w}
+		gzw := &gzipResponseWriter{ResponseWriter: w}
+		defer gzw.Close()
 		next.ServeHTTP(gzw, r)
 	})
 }
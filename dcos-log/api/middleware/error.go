@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Machine readable error codes returned in ErrorResponse.
+const (
+	ErrCodeMultipleChoices    = "multiple_choices"
+	ErrCodeBadRequest         = "bad_request"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeTooManyRequests    = "too_many_requests"
+	ErrCodeInternal           = "internal_error"
+	ErrCodeBadGateway         = "bad_gateway"
+	ErrCodeServiceUnavailable = "service_unavailable"
+	ErrCodeGatewayTimeout     = "gateway_timeout"
+	ErrCodeUnknown            = "error"
+)
+
+var statusErrCodes = map[int]string{
+	http.StatusMultipleChoices:     ErrCodeMultipleChoices,
+	http.StatusBadRequest:          ErrCodeBadRequest,
+	http.StatusUnauthorized:        ErrCodeUnauthorized,
+	http.StatusForbidden:           ErrCodeForbidden,
+	http.StatusNotFound:            ErrCodeNotFound,
+	http.StatusTooManyRequests:     ErrCodeTooManyRequests,
+	http.StatusInternalServerError: ErrCodeInternal,
+	http.StatusBadGateway:          ErrCodeBadGateway,
+	http.StatusServiceUnavailable:  ErrCodeServiceUnavailable,
+	http.StatusGatewayTimeout:      ErrCodeGatewayTimeout,
+}
+
+// ErrorResponse is a body of every error response returned by dcos-log.
+type ErrorResponse struct {
+	// Code is a machine readable error code, clients should use it instead of matching the message.
+	Code string `json:"code"`
+
+	// Message is a human readable error description.
+	Message string `json:"message"`
+
+	// Details contains optional information about the request or the cause of the error.
+	Details string `json:"details,omitempty"`
+
+	// RequestID identifies the request in dcos-log logs.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrCodeFromStatus returns an error code corresponding to the HTTP status.
+func ErrCodeFromStatus(status int) string {
+	if code, ok := statusErrCodes[status]; ok {
+		return code
+	}
+	return ErrCodeUnknown
+}
+
+// headerWriter is implemented by the response writers which know if the response code was sent.
+type headerWriter interface {
+	headerWritten() bool
+}
+
+// acceptsPlainText returns true if a client explicitly asked for a text/plain response.
+func acceptsPlainText(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Accept"), "text/plain")
+}
+
+// WriteNoContent replies with 204 to the requests which matched no log entries. The response has no body,
+// so the headers describing the content set by a handler are removed.
+func WriteNoContent(w http.ResponseWriter, r *http.Request, reason string) {
+	logrus.WithField("request_id", RequestID(r)).Debugf("no content: %s", reason)
+
+	if hw, ok := w.(headerWriter); ok && hw.headerWritten() {
+		return
+	}
+
+	for _, h := range []string{"Content-Type", "Content-Length", "Content-disposition", "Transfer-Encoding"} {
+		w.Header().Del(h)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WriteError logs the error and replies to the request with ErrorResponse. The response is JSON encoded
+// unless the client asked for text/plain. The error code is derived from the status.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, message, details string) {
+	WriteErrorCode(w, r, status, ErrCodeFromStatus(status), message, details)
+}
+
+// WriteErrorCode is the same as WriteError with a custom error code.
+func WriteErrorCode(w http.ResponseWriter, r *http.Request, status int, code, message, details string) {
+	resp := ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: RequestID(r),
+	}
+
+	entry := logrus.WithFields(logrus.Fields{
+		"request_id": resp.RequestID,
+		"code":       resp.Code,
+		"status":     status,
+		"path":       r.URL.Path,
+	})
+	if details != "" {
+		entry = entry.WithField("details", details)
+	}
+
+	if status >= http.StatusInternalServerError {
+		entry.Error(message)
+	} else {
+		entry.Warn(message)
+	}
+
+	// once a handler started streaming the response, the status cannot be changed and writing the error
+	// would corrupt the content. Logging the error is all we can do.
+	if hw, ok := w.(headerWriter); ok && hw.headerWritten() {
+		return
+	}
+
+	// the headers set by a handler for the successful response are not valid anymore.
+	w.Header().Del("Content-Length")
+	w.Header().Del("Content-disposition")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if acceptsPlainText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+
+		msg := fmt.Sprintf("%s: %s", resp.Code, resp.Message)
+		if resp.Details != "" {
+			msg += ". " + resp.Details
+		}
+		if resp.RequestID != "" {
+			msg += fmt.Sprintf(" [request_id: %s]", resp.RequestID)
+		}
+		fmt.Fprintln(w, msg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logrus.Errorf("unable to encode error response: %s", err)
+	}
+}
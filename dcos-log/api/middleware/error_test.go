@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteErrorJSON(t *testing.T) {
+	req, err := http.NewRequest("GET", "/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(WithRequestIDContext(req.Context(), "id-1"))
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-disposition", "attachment; filename=test.log.gz")
+	WriteError(w, req, http.StatusNotFound, "File not found", "path /tmp/stdout")
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404. Got %d", w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expect application/json content type. Got %s", ct)
+	}
+
+	if cd := w.Header().Get("Content-disposition"); cd != "" {
+		t.Fatalf("expect Content-disposition to be removed. Got %s", cd)
+	}
+
+	resp := ErrorResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ErrorResponse{
+		Code:      ErrCodeNotFound,
+		Message:   "File not found",
+		Details:   "path /tmp/stdout",
+		RequestID: "id-1",
+	}
+	if resp != expected {
+		t.Fatalf("expect %+v. Got %+v", expected, resp)
+	}
+}
+
+func TestWriteErrorPlainText(t *testing.T) {
+	req, err := http.NewRequest("GET", "/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/plain")
+
+	w := httptest.NewRecorder()
+	WriteError(w, req, http.StatusBadRequest, "invalid limit", "")
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expect text/plain content type. Got %s", w.Header().Get("Content-Type"))
+	}
+
+	if body := w.Body.String(); body != "bad_request: invalid limit\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestWriteErrorAfterStreaming(t *testing.T) {
+	req, err := http.NewRequest("GET", "/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	w := newResponseWriter(recorder)
+	w.Write([]byte("log line\n"))
+	WriteError(w, req, http.StatusInternalServerError, "unable to read the journal", "")
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expect 200. Got %d", recorder.Code)
+	}
+
+	if body := recorder.Body.String(); body != "log line\n" {
+		t.Fatalf("expect the streamed content only. Got %q", body)
+	}
+}
+
+func TestDownloadGzippedContentError(t *testing.T) {
+	h := DownloadGzippedContent(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		WriteError(w, req, http.StatusBadRequest, "invalid cursor", "")
+	}), "test")
+
+	req, err := http.NewRequest("GET", "/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if cd := w.Header().Get("Content-disposition"); cd != "" {
+		t.Fatalf("expect no attachment for an error response. Got %s", cd)
+	}
+
+	resp := ErrorResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("expect uncompressed JSON error: %s", err)
+	}
+
+	if resp.Code != ErrCodeBadRequest {
+		t.Fatalf("expect code %s. Got %s", ErrCodeBadRequest, resp.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/limit"
+	"github.com/dcos/dcos-log/dcos-log/metrics"
+	"github.com/gorilla/mux"
+)
+
+// streamRetryAfter is a Retry-After value returned if no stream slot is available.
+const streamRetryAfter = 5 * time.Second
+
+var limitRejections = metrics.NewCounter("dcos_log_limit_rejections_total",
+	"Total number of requests rejected with 429 by reason.", "reason")
+
+// limitClient returns a key identifying the client for the limits, the principal if the request has
+// a verified authentication token, otherwise the remote IP address. The unverified uid claim is not used,
+// a client would get a new key with every forged token.
+func limitClient(r *http.Request) string {
+	if principal := VerifiedPrincipal(r); principal != "" {
+		return "principal:" + principal
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// tooManyRequests replies with 429 and sets Retry-After header in seconds, rounded up.
+func tooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, reason, msg string) {
+	limitRejections.Inc(reason)
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	WriteError(w, r, http.StatusTooManyRequests, msg, "")
+}
+
+// streamContext returns the request context which is also cancelled on server shutdown, so a throttled write
+// does not keep the stream open once the client disconnects or the server stops.
+func streamContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+
+	shutdown, ok := FromContextShutdown(ctx)
+	if !ok {
+		return ctx, cancel
+	}
+
+	go func() {
+		select {
+		case <-shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// throttledResponseWriter passes the response body through limit.ThrottledWriter. It keeps http.Flusher and
+// http.CloseNotifier available to the streaming handlers.
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	throttled *limit.ThrottledWriter
+}
+
+func (w *throttledResponseWriter) Write(b []byte) (int, error) {
+	return w.throttled.Write(b)
+}
+
+func (w *throttledResponseWriter) headerWritten() bool {
+	hw, ok := w.ResponseWriter.(headerWriter)
+	return ok && hw.headerWritten()
+}
+
+// Flush implements http.Flusher.
+func (w *throttledResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify implements http.CloseNotifier.
+func (w *throttledResponseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// Limit is a middleware that enforces the request rate and the number of concurrent streams per client
+// and in total. Rejected requests get 429 with Retry-After header. The streams are throttled to the
+// configured bytes per second. It must wrap a handler registered with a mux route.
+func Limit(next http.Handler, limiter *limit.Limiter) http.Handler {
+	if limiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := limitClient(r)
+
+		if ok, retryAfter := limiter.AllowRequest(client); !ok {
+			tooManyRequests(w, r, retryAfter, "rate", "request rate limit exceeded")
+			return
+		}
+
+		var route string
+		if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+			route, _ = currentRoute.GetPathTemplate()
+		}
+
+		if !IsStream(r, route) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		release, reason := limiter.AcquireStream(client)
+		if release == nil {
+			tooManyRequests(w, r, streamRetryAfter, string(reason),
+				fmt.Sprintf("too many open log streams: %s limit reached", reason))
+			return
+		}
+		defer release()
+
+		if bps := limiter.StreamBytesPerSecond(); bps > 0 {
+			ctx, cancel := streamContext(r)
+			defer cancel()
+
+			w = &throttledResponseWriter{
+				ResponseWriter: w,
+				throttled:      limit.NewThrottledWriter(ctx, w, bps),
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/limit"
+	"github.com/gorilla/mux"
+)
+
+func TestLimitStreams(t *testing.T) {
+	limiter := limit.New(limit.Config{MaxStreamsPerClient: 1})
+
+	// acquired by an open stream
+	release, _ := limiter.AcquireStream("ip:10.0.0.1")
+	defer release()
+
+	r := mux.NewRouter()
+	r.Path("/v1/stream/").Handler(Limit(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}), limiter))
+	r.Path("/v1/range/").Handler(Limit(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}), limiter))
+
+	for _, tc := range []struct {
+		path       string
+		remoteAddr string
+		code       int
+	}{
+		{path: "/v1/stream/", remoteAddr: "10.0.0.1:1234", code: http.StatusTooManyRequests},
+		{path: "/v1/stream/", remoteAddr: "10.0.0.2:1234", code: http.StatusOK},
+		{path: "/v1/range/", remoteAddr: "10.0.0.1:1234", code: http.StatusOK},
+	} {
+		req, err := http.NewRequest("GET", tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = tc.remoteAddr
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Fatalf("%s from %s: expect %d. Got %d", tc.path, tc.remoteAddr, tc.code, w.Code)
+		}
+
+		if tc.code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Fatal("expect Retry-After header")
+		}
+	}
+
+	// the stream slot is released once the request is served.
+	if usage := limiter.Usage(); usage.Streams != 1 {
+		t.Fatalf("expect 1 open stream. Got %d", usage.Streams)
+	}
+}
+
+func TestLimitRate(t *testing.T) {
+	limiter := limit.New(limit.Config{RequestsPerSecond: 0.1, RequestBurst: 1})
+	h := Limit(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}), limiter)
+
+	req, err := http.NewRequest("GET", "/v1/range/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200. Got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expect 429. Got %d", w.Code)
+	}
+
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "10" {
+		t.Fatalf("expect Retry-After 10. Got %s", retryAfter)
+	}
+}
+
+func TestLimitClient(t *testing.T) {
+	verifier, sign, key := newTestIAM(t)
+
+	var client string
+	h := Principal(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		client = limitClient(req)
+	}), verifier)
+
+	for token, expected := range map[string]string{
+		sign(key, "bootstrapuser", time.Now().Add(time.Hour)): "principal:bootstrapuser",
+		// a forged token with a random uid does not get its own bucket.
+		"token=eyJhbGciOiJub25lIn0.eyJ1aWQiOiJyYW5kb20ifQ.c2ln": "ip:10.0.0.1",
+		"": "ip:10.0.0.1",
+	} {
+		req := httptest.NewRequest("GET", "/v1/range/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if client != expected {
+			t.Fatalf("expect %s. Got %s", expected, client)
+		}
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/sirupsen/logrus"
 	"github.com/dcos/dcos-go/dcos/nodeutil"
 	"github.com/dcos/dcos-log/dcos-log/config"
+	"github.com/dcos/dcos-log/dcos-log/redact"
+	"github.com/sirupsen/logrus"
 )
 
 type key int
@@ -16,6 +17,11 @@ const (
 	httpClientKey
 	nodeInfoKey
 	tokenKey
+	redactorKey
+	requestIDKey
+	shutdownKey
+	principalKey
+	accessLogPrincipalKey
 )
 
 // withKeyContext returns a context with an encapsulated object by a key.
@@ -77,6 +83,55 @@ func FromContextNodeInfo(ctx context.Context) (nodeInfo nodeutil.NodeInfo, ok bo
 	return nodeInfo, ok
 }
 
+// WithRedactorContext wraps a *redact.Redactor object into context.
+func WithRedactorContext(ctx context.Context, redactor *redact.Redactor) context.Context {
+	return withKeyContext(ctx, redactorKey, redactor)
+}
+
+// FromContextRedactor returns a *redact.Redactor object from a context. If the request must not be
+// redacted, ok is false.
+func FromContextRedactor(ctx context.Context) (redactor *redact.Redactor, ok bool) {
+	instance, ok := fromContextByKey(ctx, redactorKey)
+	if !ok {
+		return nil, ok
+	}
+
+	redactor, ok = instance.(*redact.Redactor)
+	return redactor, ok
+}
+
+// WithRequestIDContext wraps a request ID into context.
+func WithRequestIDContext(ctx context.Context, requestID string) context.Context {
+	return withKeyContext(ctx, requestIDKey, requestID)
+}
+
+// FromContextRequestID returns a request ID from a context.
+func FromContextRequestID(ctx context.Context) (requestID string, ok bool) {
+	instance, ok := fromContextByKey(ctx, requestIDKey)
+	if !ok {
+		return "", ok
+	}
+
+	requestID, ok = instance.(string)
+	return requestID, ok
+}
+
+// WithShutdownContext wraps a channel closed on server shutdown into context.
+func WithShutdownContext(ctx context.Context, shutdown <-chan struct{}) context.Context {
+	return withKeyContext(ctx, shutdownKey, shutdown)
+}
+
+// FromContextShutdown returns a channel closed on server shutdown from a context.
+func FromContextShutdown(ctx context.Context) (shutdown <-chan struct{}, ok bool) {
+	instance, ok := fromContextByKey(ctx, shutdownKey)
+	if !ok {
+		return nil, ok
+	}
+
+	shutdown, ok = instance.(<-chan struct{})
+	return shutdown, ok
+}
+
 // FromContextToken returns a token string from a context if available.
 func FromContextToken(ctx context.Context) (string, bool) {
 	instance, ok := fromContextByKey(ctx, tokenKey)
@@ -101,7 +156,7 @@ func Wrapped(next http.Handler, cfg *config.Config, client *http.Client, nodeInf
 		if err == nil && token != "" {
 			ctx = withKeyContext(ctx, tokenKey, &token)
 		} else {
-			logrus.Warnf("Authorization token not found: %s", err)
+			logrus.WithField("request_id", RequestID(r)).Warnf("Authorization token not found: %s", err)
 		}
 
 		next.ServeHTTP(w, r.WithContext(ctx))
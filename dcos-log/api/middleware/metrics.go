@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/metrics"
+	"github.com/gorilla/mux"
+)
+
+var (
+	requestsTotal = metrics.NewCounter("dcos_log_http_requests_total",
+		"Total number of HTTP requests by route, method and response code.", "route", "method", "code")
+	requestDuration = metrics.NewHistogram("dcos_log_http_request_duration_seconds",
+		"HTTP request latency by route. Streaming requests are observed when the client disconnects.", nil,
+		"route", "stream")
+	responseBytes = metrics.NewCounter("dcos_log_http_response_bytes_total",
+		"Total number of bytes served by route.", "route")
+	activeStreams = metrics.NewGauge("dcos_log_active_streams",
+		"Number of currently open log streams by route.", "route")
+	authFailures = metrics.NewCounter("dcos_log_auth_failures_total",
+		"Total number of requests rejected by the auth middleware by reason.", "reason")
+)
+
+// IsStream returns true if the request opens a log stream, either a v1 /stream/ or v2 /stream/ endpoint
+// or an endpoint requested with Accept: text/event-stream.
+func IsStream(r *http.Request, route string) bool {
+	return r.Header.Get("Accept") == "text/event-stream" || strings.HasPrefix(route, "/v1/stream/") ||
+		strings.HasPrefix(route, "/v2/stream/")
+}
+
+// Metrics is a middleware that records the request count, latency, response size and the number of
+// active streams. It must wrap a handler registered with a mux route, the route template is used as a label.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var route string
+		if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+			route, _ = currentRoute.GetPathTemplate()
+		}
+
+		stream := IsStream(r, route)
+		if stream {
+			activeStreams.Inc(route)
+			defer activeStreams.Dec(route)
+		}
+
+		start := time.Now()
+		rw := newResponseWriter(w)
+
+		next.ServeHTTP(rw, r)
+
+		requestsTotal.Inc(route, r.Method, strconv.Itoa(rw.Code()))
+		requestDuration.Observe(time.Since(start).Seconds(), route, strconv.FormatBool(stream))
+		responseBytes.Add(float64(rw.Bytes()), route)
+	})
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// jwksRefreshInterval is an interval the key set is fetched again to pick up the rotated keys.
+	jwksRefreshInterval = time.Hour
+
+	// jwksMinRefreshInterval limits how often a token signed with an unknown key triggers a key set fetch.
+	jwksMinRefreshInterval = time.Minute
+
+	// tokenLeeway is the clock skew allowed when the token expiration is checked.
+	tokenLeeway = time.Minute
+)
+
+var (
+	// ErrUnknownKey is returned by TokenVerifier if the token is not signed with a key of the key set.
+	ErrUnknownKey = errors.New("token is signed with an unknown key")
+
+	// ErrMissingPrincipal is returned by TokenVerifier if the token has no uid or expiration claim.
+	ErrMissingPrincipal = errors.New("token has no uid or exp claim")
+)
+
+// TokenVerifier verifies the signature and expiration of DC/OS authentication tokens with the keys of the IAM
+// JSON web key set.
+type TokenVerifier struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    *jose.JSONWebKeySet
+	fetched time.Time
+}
+
+// NewTokenVerifier returns a verifier using the key set at jwksURL. If jwksURL is empty, nil is returned and
+// no token is verified.
+func NewTokenVerifier(jwksURL string, client *http.Client) *TokenVerifier {
+	if jwksURL == "" {
+		return nil
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &TokenVerifier{url: jwksURL, client: client}
+}
+
+// Verify returns the uid claim of a valid token.
+func (v *TokenVerifier) Verify(ctx context.Context, token string) (string, error) {
+	parsed, err := jwt.ParseSigned(strings.TrimPrefix(token, "token="))
+	if err != nil {
+		return "", err
+	}
+
+	if len(parsed.Headers) != 1 {
+		return "", fmt.Errorf("token must have one signature. Got %d", len(parsed.Headers))
+	}
+
+	key, err := v.key(ctx, parsed.Headers[0].KeyID)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		claims jwt.Claims
+		custom struct {
+			UID string `json:"uid"`
+		}
+	)
+	if err := parsed.Claims(key.Key, &claims, &custom); err != nil {
+		return "", err
+	}
+
+	if custom.UID == "" || claims.Expiry == 0 {
+		return "", ErrMissingPrincipal
+	}
+
+	if err := claims.ValidateWithLeeway(jwt.Expected{Time: time.Now()}, tokenLeeway); err != nil {
+		return "", err
+	}
+	return custom.UID, nil
+}
+
+// key returns the key with the ID. The key set is fetched again once it's old or if a key is not found.
+func (v *TokenVerifier) key(ctx context.Context, kid string) (jose.JSONWebKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.keys == nil || time.Since(v.fetched) > jwksRefreshInterval {
+		if err := v.fetch(ctx); err != nil && v.keys == nil {
+			return jose.JSONWebKey{}, err
+		}
+	}
+
+	keys := v.keys.Key(kid)
+	if len(keys) == 0 && time.Since(v.fetched) > jwksMinRefreshInterval {
+		if err := v.fetch(ctx); err != nil {
+			return jose.JSONWebKey{}, err
+		}
+		keys = v.keys.Key(kid)
+	}
+
+	// the keys used for signing the tokens are public keys in the key set.
+	for _, key := range keys {
+		if key.Valid() && key.IsPublic() {
+			return key, nil
+		}
+	}
+	return jose.JSONWebKey{}, ErrUnknownKey
+}
+
+// fetch reads the key set, it must be called with the lock held. A failed fetch keeps the previous keys.
+func (v *TokenVerifier) fetch(ctx context.Context) error {
+	v.fetched = time.Now()
+
+	req, err := http.NewRequest("GET", v.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("unable to get the key set: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to get the key set: bad status %d. URL %s", resp.StatusCode, v.url)
+	}
+
+	keys := &jose.JSONWebKeySet{}
+	if err := json.NewDecoder(resp.Body).Decode(keys); err != nil {
+		return fmt.Errorf("unable to decode the key set: %s", err)
+	}
+
+	v.keys = keys
+	return nil
+}
+
+// Principal is a middleware that verifies the authentication token and puts its uid into the request context.
+// The requests with an invalid token are served, the handlers which need an identity use VerifiedPrincipal.
+// If the verifier is nil, no request has a verified principal.
+func Principal(next http.Handler, verifier *TokenVerifier) http.Handler {
+	if verifier == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := GetAuthFromRequest(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := verifier.Verify(r.Context(), token)
+		if err != nil {
+			logrus.WithField("request_id", RequestID(r)).Warnf("unable to verify the authentication token: %s", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// AccessLog wraps Principal, it logs the verified principal once the request is served.
+		if verified, ok := r.Context().Value(accessLogPrincipalKey).(*string); ok {
+			*verified = principal
+		}
+
+		next.ServeHTTP(w, r.WithContext(withKeyContext(r.Context(), principalKey, principal)))
+	})
+}
+
+// VerifiedPrincipal returns the uid of the verified authentication token, an empty string if the request has
+// no valid token.
+func VerifiedPrincipal(r *http.Request) string {
+	principal, _ := r.Context().Value(principalKey).(string)
+	return principal
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/redact"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// newTestIAM returns a verifier of the tokens signed by the returned function with the key set key.
+func newTestIAM(t *testing.T) (*TokenVerifier, func(key *rsa.PrivateKey, uid string, exp time.Time) string, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "secret", Algorithm: "RS256"}}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keys)
+	}))
+	t.Cleanup(ts.Close)
+
+	sign := func(signingKey *rsa.PrivateKey, uid string, exp time.Time) string {
+		signer, err := jose.NewSigner(jose.SigningKey{
+			Algorithm: jose.RS256,
+			Key:       jose.JSONWebKey{Key: signingKey, KeyID: "secret"},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := jwt.Signed(signer).Claims(map[string]interface{}{
+			"uid": uid,
+			"exp": exp.Unix(),
+		}).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "token=" + token
+	}
+
+	return NewTokenVerifier(ts.URL, ts.Client()), sign, key
+}
+
+func TestTokenVerifier(t *testing.T) {
+	verifier, sign, key := newTestIAM(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := verifier.Verify(context.Background(), sign(key, "bootstrapuser", time.Now().Add(time.Hour)))
+	if err != nil || principal != "bootstrapuser" {
+		t.Fatalf("expect bootstrapuser. Got %q, %v", principal, err)
+	}
+
+	for name, token := range map[string]string{
+		"expired":     sign(key, "bootstrapuser", time.Now().Add(-time.Hour)),
+		"other key":   sign(otherKey, "bootstrapuser", time.Now().Add(time.Hour)),
+		"no uid":      sign(key, "", time.Now().Add(time.Hour)),
+		"not a token": "token=eyJhbGciOiJub25lIn0.eyJ1aWQiOiJib290c3RyYXB1c2VyIn0.",
+	} {
+		if principal, err := verifier.Verify(context.Background(), token); err == nil {
+			t.Fatalf("%s: expect an error. Got %s", name, principal)
+		}
+	}
+}
+
+func TestNewTokenVerifierDisabled(t *testing.T) {
+	if verifier := NewTokenVerifier("", nil); verifier != nil {
+		t.Fatal("expect no verifier without the key set URL")
+	}
+}
+
+func TestRedactBypass(t *testing.T) {
+	verifier, sign, key := newTestIAM(t)
+
+	redactor, err := redact.New([]redact.Rule{{Pattern: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := NewRedactPolicy(redactor, []string{"bootstrapuser"})
+	h := Principal(Redact(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), policy), verifier)
+
+	// the unverified uid claim is not trusted.
+	forged := "token=eyJhbGciOiJub25lIn0.eyJ1aWQiOiJib290c3RyYXB1c2VyIn0.c2ln"
+
+	for token, expected := range map[string]int{
+		sign(key, "bootstrapuser", time.Now().Add(time.Hour)): http.StatusOK,
+		sign(key, "alice", time.Now().Add(time.Hour)):         http.StatusForbidden,
+		forged: http.StatusForbidden,
+		"":     http.StatusForbidden,
+	} {
+		req := httptest.NewRequest("GET", "/v1/range/?redact=false", nil)
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Fatalf("%s: expect %d. Got %d", PrincipalFromToken(token), expected, w.Code)
+		}
+	}
+}
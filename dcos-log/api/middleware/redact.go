@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/dcos/dcos-log/dcos-log/redact"
+)
+
+const redactParam = "redact"
+
+// RedactPolicy is a redactor and a list of principals allowed to bypass it. The policy can be replaced
+// while the server is running, the requests in flight keep using the previous one.
+type RedactPolicy struct {
+	mu       sync.RWMutex
+	redactor *redact.Redactor
+	bypass   map[string]bool
+}
+
+// NewRedactPolicy returns a new RedactPolicy.
+func NewRedactPolicy(redactor *redact.Redactor, bypassPrincipals []string) *RedactPolicy {
+	p := &RedactPolicy{}
+	p.Set(redactor, bypassPrincipals)
+	return p
+}
+
+// Set replaces the redactor and the principals allowed to bypass it.
+func (p *RedactPolicy) Set(redactor *redact.Redactor, bypassPrincipals []string) {
+	bypass := make(map[string]bool, len(bypassPrincipals))
+	for _, principal := range bypassPrincipals {
+		bypass[principal] = true
+	}
+
+	p.mu.Lock()
+	p.redactor = redactor
+	p.bypass = bypass
+	p.mu.Unlock()
+}
+
+func (p *RedactPolicy) get() (*redact.Redactor, map[string]bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.redactor, p.bypass
+}
+
+// Redact is a middleware that puts the redactor into the request context. The handlers must apply
+// the redactor from the context to the served content. If the policy has no redactor, requests are
+// passed as is.
+//
+// A client may ask for unredacted logs with ?redact=false. Such requests are only allowed for principals
+// listed in the policy, all others get 403. The principal is taken from the authentication token verified
+// by the Principal middleware, so the bypass is denied if the tokens are not verified.
+func Redact(next http.Handler, policy *RedactPolicy) http.Handler {
+	if policy == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redactor, allowed := policy.get()
+		if redactor == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		useRedactor := true
+		if redactStr := r.URL.Query().Get(redactParam); redactStr != "" {
+			var err error
+			useRedactor, err = strconv.ParseBool(redactStr)
+			if err != nil {
+				WriteError(w, r, http.StatusBadRequest, "unable to parse redact parameter", err.Error())
+				return
+			}
+		}
+
+		if !useRedactor {
+			principal := VerifiedPrincipal(r)
+			if principal == "" || !allowed[principal] {
+				WriteError(w, r, http.StatusForbidden,
+					fmt.Sprintf("principal %q is not allowed to read unredacted logs", principal), "")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithRedactorContext(r.Context(), redactor)))
+	})
+}
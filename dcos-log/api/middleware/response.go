@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// responseWriter wraps http.ResponseWriter and records the response code and the number of bytes
+// written to a client. It keeps http.Flusher and http.CloseNotifier available to the wrapped handlers,
+// streaming endpoints rely on both.
+type responseWriter struct {
+	http.ResponseWriter
+
+	code  int
+	bytes int64
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+	}
+}
+
+// Code returns the response code sent to a client. If the handler did not call WriteHeader
+// explicitly, 200 is returned.
+func (w *responseWriter) Code() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+// headerWritten returns true if the response code was already sent to a client.
+func (w *responseWriter) headerWritten() bool {
+	return w.code != 0
+}
+
+// Bytes returns the number of bytes written to a client.
+func (w *responseWriter) Bytes() int64 {
+	return w.bytes
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher.
+func (w *responseWriter) Flush() {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify implements http.CloseNotifier.
+func (w *responseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+
+	// the underlying writer is unable to notify, return a channel which is never closed.
+	return make(chan bool)
+}
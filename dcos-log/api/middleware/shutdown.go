@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ShutdownEvent is a name of the server sent event written to streaming clients before the stream is closed
+// on server shutdown.
+const ShutdownEvent = "shutdown"
+
+// Shutdown is a middleware that makes the shutdown channel available to the handlers via FromContextShutdown.
+// The channel must be closed once the server stops accepting new connections, the streaming handlers are
+// expected to end the streams and release the readers.
+func Shutdown(next http.Handler, shutdown <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithShutdownContext(r.Context(), shutdown)))
+	})
+}
+
+// WriteShutdownEvent writes the shutdown event with the cursor of the last entry sent to a client.
+// The cursor is also set as the event ID, so a browser resumes the stream from it once it reconnects.
+func WriteShutdownEvent(w io.Writer, cursor string) error {
+	data, err := json.Marshal(struct {
+		Cursor string `json:"cursor"`
+	}{
+		Cursor: cursor,
+	})
+	if err != nil {
+		return err
+	}
+
+	event := fmt.Sprintf("event: %s\n", ShutdownEvent)
+	if cursor != "" {
+		event += fmt.Sprintf("id: %s\n", cursor)
+	}
+	event += fmt.Sprintf("data: %s\n\n", data)
+
+	_, err = io.WriteString(w, event)
+	return err
+}
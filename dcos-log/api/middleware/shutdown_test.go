@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteShutdownEvent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteShutdownEvent(buf, "s=123;i=1"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "event: shutdown\nid: s=123;i=1\ndata: {\"cursor\":\"s=123;i=1\"}\n\n"
+	if buf.String() != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf.String())
+	}
+
+	// no id field without a cursor, so a client does not reset its last event ID.
+	buf.Reset()
+	if err := WriteShutdownEvent(buf, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "event: shutdown\ndata: {\"cursor\":\"\"}\n\n"
+	if buf.String() != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf.String())
+	}
+}
+
+func TestShutdownContext(t *testing.T) {
+	done := make(chan struct{})
+	closed := false
+
+	h := Shutdown(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shutdown, ok := FromContextShutdown(r.Context())
+		if !ok {
+			t.Fatal("expect shutdown channel in the context")
+		}
+
+		select {
+		case <-shutdown:
+			closed = true
+		default:
+		}
+	}), done)
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if closed {
+		t.Fatal("expect shutdown channel to be open")
+	}
+
+	close(done)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !closed {
+		t.Fatal("expect shutdown channel to be closed")
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/api/middleware"
+	"github.com/dcos/dcos-log/dcos-log/config"
+	"github.com/dcos/dcos-log/dcos-log/limit"
+	"github.com/dcos/dcos-log/dcos-log/redact"
+	"github.com/sirupsen/logrus"
+)
+
+// liveConfig holds the effective config of the running server. When the config is reloaded, the reloadable
+// settings are applied without a restart: log level, request timeouts, limits and redaction policy.
+type liveConfig struct {
+	mu  sync.RWMutex
+	cfg *config.Config
+
+	// transport is nil if the server does not make outgoing requests, e.g. in tests.
+	transport    *timeoutTransport
+	limiter      *limit.Limiter
+	redactPolicy *middleware.RedactPolicy
+}
+
+func limitConfig(cfg *config.Config) limit.Config {
+	return limit.Config{
+		MaxStreams:           cfg.FlagMaxStreams,
+		MaxStreamsPerClient:  cfg.FlagMaxStreamsPerClient,
+		RequestsPerSecond:    cfg.FlagRateLimit,
+		RequestBurst:         cfg.FlagRateLimitBurst,
+		StreamBytesPerSecond: cfg.FlagStreamRateLimit,
+	}
+}
+
+func newLiveConfig(cfg *config.Config, transport *timeoutTransport) (*liveConfig, error) {
+	redactor, err := redact.New(cfg.FlagRedactRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &liveConfig{
+		cfg:          cfg,
+		transport:    transport,
+		limiter:      limit.New(limitConfig(cfg)),
+		redactPolicy: middleware.NewRedactPolicy(redactor, cfg.FlagRedactBypassPrincipals),
+	}, nil
+}
+
+// get returns the current config. The returned config must not be modified.
+func (l *liveConfig) get() *config.Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.cfg
+}
+
+// requestTimeout returns the timeout of the requests made by dcos-log.
+func (l *liveConfig) requestTimeout() time.Duration {
+	timeout, err := time.ParseDuration(l.get().FlagGetRequestTimeout)
+	if err != nil {
+		logrus.Errorf("invalid timeout %s: %s", l.get().FlagGetRequestTimeout, err)
+	}
+	return timeout
+}
+
+// reload reads the config again and applies the changed reloadable settings. If any of the new values
+// is invalid, nothing is applied.
+func (l *liveConfig) reload() error {
+	cfg, changed, restartRequired, err := l.get().Reload()
+	if err != nil {
+		return err
+	}
+
+	if len(restartRequired) > 0 {
+		logrus.Warnf("Settings %s changed, restart dcos-log to apply", strings.Join(restartRequired, ", "))
+	}
+
+	if len(changed) == 0 {
+		logrus.Info("Config reloaded, no changes")
+		return nil
+	}
+
+	// validate everything before applying.
+	timeout, err := time.ParseDuration(cfg.FlagGetRequestTimeout)
+	if err != nil {
+		return err
+	}
+
+	if _, err := time.ParseDuration(cfg.FlagShutdownTimeout); err != nil {
+		return err
+	}
+
+	redactor, err := redact.New(cfg.FlagRedactRules)
+	if err != nil {
+		return err
+	}
+
+	level := logrus.InfoLevel
+	if cfg.FlagVerbose {
+		level = logrus.DebugLevel
+	}
+	logrus.SetLevel(level)
+
+	if l.transport != nil {
+		l.transport.SetTimeout(timeout)
+	}
+	l.limiter.SetConfig(limitConfig(cfg))
+	l.redactPolicy.Set(redactor, cfg.FlagRedactBypassPrincipals)
+
+	l.mu.Lock()
+	l.cfg = cfg
+	l.mu.Unlock()
+
+	logrus.Infof("Config reloaded, applied %s", strings.Join(changed, ", "))
+	return nil
+}
+
+// configHandler responds with the effective config settings and their sources.
+func (l *liveConfig) configHandler(w http.ResponseWriter, req *http.Request) {
+	settings, err := l.get().Settings()
+	if err != nil {
+		middleware.WriteError(w, req, http.StatusInternalServerError, "unable to get config settings", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, settings)
+}
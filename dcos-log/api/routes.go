@@ -4,13 +4,36 @@ import (
 	"net/http"
 
 	"github.com/dcos/dcos-go/dcos/nodeutil"
+	"github.com/dcos/dcos-log/dcos-log/api/middleware"
 	"github.com/dcos/dcos-log/dcos-log/api/v1"
 	"github.com/dcos/dcos-log/dcos-log/api/v2"
-	"github.com/dcos/dcos-log/dcos-log/config"
+	"github.com/dcos/dcos-log/dcos-log/audit"
+	"github.com/dcos/dcos-log/dcos-log/metrics"
 	"github.com/gorilla/mux"
 )
 
-func newAPIRouter(cfg *config.Config, client *http.Client, nodeInfo nodeutil.NodeInfo) (*mux.Router, error) {
+// wrapRoutes applies the middleware to every route registered in the router. Unlike wrapping the router itself,
+// the middleware is executed after a route is matched, so it has access to mux variables and the current route.
+func wrapRoutes(r *mux.Router, mw func(http.Handler) http.Handler) error {
+	return r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		h := route.GetHandler()
+		if h == nil {
+			return nil
+		}
+
+		// subrouters are walked separately.
+		if _, ok := h.(*mux.Router); ok {
+			return nil
+		}
+
+		route.Handler(mw(h))
+		return nil
+	})
+}
+
+func newAPIRouter(live *liveConfig, client *http.Client, nodeInfo nodeutil.NodeInfo,
+	auditLogger audit.Logger, shutdown <-chan struct{}) (*mux.Router, error) {
+	cfg := live.get()
 	r := mux.NewRouter()
 
 	// define top level subrouter for base endpoint /v1
@@ -20,5 +43,58 @@ func newAPIRouter(cfg *config.Config, client *http.Client, nodeInfo nodeutil.Nod
 	v2Subrouter := r.PathPrefix("/v2").Subrouter()
 	v2.InitRoutes(v2Subrouter, cfg, client, nodeInfo)
 
+	if err := wrapRoutes(r, func(h http.Handler) http.Handler {
+		return middleware.Limit(h, live.limiter)
+	}); err != nil {
+		return nil, err
+	}
+
+	// the throttled streams are also ended on shutdown.
+	if err := wrapRoutes(r, func(h http.Handler) http.Handler {
+		return middleware.Shutdown(h, shutdown)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := wrapRoutes(r, func(h http.Handler) http.Handler {
+		return middleware.Redact(h, live.redactPolicy)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := wrapRoutes(r, middleware.Metrics); err != nil {
+		return nil, err
+	}
+
+	// audit middleware must wrap the other middlewares to record all responses.
+	if auditLogger != nil {
+		if err := wrapRoutes(r, func(h http.Handler) http.Handler {
+			return middleware.Audit(h, auditLogger)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// the verified principal is used by the audit records, the limits and the redaction bypass.
+	verifier := middleware.NewTokenVerifier(cfg.FlagAuthJWKSURL, client)
+	if err := wrapRoutes(r, func(h http.Handler) http.Handler {
+		return middleware.Principal(h, verifier)
+	}); err != nil {
+		return nil, err
+	}
+
+	// access log middleware is the outermost one, it assigns the request ID used by all others.
+	if err := wrapRoutes(r, middleware.AccessLog); err != nil {
+		return nil, err
+	}
+
+	// service endpoints are registered after the routes are wrapped, they are not audited nor instrumented.
+	r.Path("/metrics").Handler(metrics.Handler()).Methods("GET")
+	r.Path("/health").HandlerFunc(healthHandler).Methods("GET")
+	r.Path("/ready").Handler(newReadyHandler(readinessChecks(cfg, client, nodeInfo), live.requestTimeout)).Methods("GET")
+	r.Path("/version").HandlerFunc(versionHandler).Methods("GET")
+	r.Path("/limits").Handler(newLimitsHandler(live.limiter)).Methods("GET")
+	r.Path("/config").HandlerFunc(live.configHandler).Methods("GET")
+
 	return r, nil
 }
@@ -1,36 +1,29 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
-	"net/url"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
-	"github.com/sirupsen/logrus"
 	"github.com/coreos/go-systemd/activation"
-	"github.com/dcos/dcos-go/dcos"
+	"github.com/coreos/go-systemd/daemon"
 	"github.com/dcos/dcos-go/dcos/http/transport"
 	"github.com/dcos/dcos-go/dcos/nodeutil"
+	"github.com/dcos/dcos-log/dcos-log/audit"
 	"github.com/dcos/dcos-log/dcos-log/config"
+	"github.com/sirupsen/logrus"
 )
 
-// override the defaultStateURL to use https scheme
-var defaultStateURL = url.URL{
-	Scheme: "https",
-	Host:   net.JoinHostPort(dcos.DNSRecordLeader, strconv.Itoa(dcos.PortMesosMaster)),
-	Path:   "/state",
-}
-
 func newNodeInfo(cfg *config.Config, client *http.Client) (nodeutil.NodeInfo, error) {
-	stateURL := defaultStateURL
-	if !cfg.FlagAuth {
-		stateURL.Scheme = "http"
-	}
-
 	// if auth is enabled we will also make requests to mesos via https.
-	nodeInfo, err := nodeutil.NewNodeInfo(client, cfg.FlagRole, nodeutil.OptionMesosStateURL(stateURL.String()))
+	nodeInfo, err := nodeutil.NewNodeInfo(client, cfg.FlagRole, nodeutil.OptionMesosStateURL(cfg.MesosStateURL()))
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +31,65 @@ func newNodeInfo(cfg *config.Config, client *http.Client) (nodeutil.NodeInfo, er
 	return nodeInfo, nil
 }
 
+func newAuditLogger(cfg *config.Config) (audit.Logger, error) {
+	if !cfg.FlagAudit {
+		return nil, nil
+	}
+
+	if cfg.FlagAuditFile != "" {
+		logrus.Infof("Writing audit records to %s", cfg.FlagAuditFile)
+		return audit.NewFileLogger(cfg.FlagAuditFile, int64(cfg.FlagAuditFileMaxSize)<<20, cfg.FlagAuditFileMaxBackups)
+	}
+
+	logrus.Infof("Writing audit records to the journal with SYSLOG_IDENTIFIER=%s", audit.SyslogIdentifier)
+	return audit.NewJournalLogger()
+}
+
+// notifySystemd tells systemd the service has started. If the unit has WatchdogSec set, the watchdog
+// is pinged at half of the interval while the checks pass, so systemd restarts a process which is alive
+// but unable to read the logs.
+func notifySystemd(checks []check) {
+	if _, err := daemon.SdNotify("READY=1"); err != nil {
+		logrus.Errorf("unable to notify systemd: %s", err)
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+
+	interval := time.Duration(usec) * time.Microsecond / 2
+	logrus.Infof("Pinging systemd watchdog every %s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if !watchdogChecksPass(checks, interval) {
+				continue
+			}
+
+			if _, err := daemon.SdNotify("WATCHDOG=1"); err != nil {
+				logrus.Errorf("unable to ping systemd watchdog: %s", err)
+			}
+		}
+	}()
+}
+
+// watchdogChecksPass runs the checks with the timeout and logs the failed ones.
+func watchdogChecksPass(checks []check, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	results, ok := runChecks(ctx, checks)
+	for name, result := range results {
+		if result.Status != statusOK {
+			logrus.Errorf("Check %s failed, not pinging systemd watchdog: %s", name, result.Error)
+		}
+	}
+	return ok
+}
+
 // StartServer is an entry point to dcos-log service.
 func StartServer(cfg *config.Config) error {
 	transportOptions := []transport.OptionTransportFunc{}
@@ -56,9 +108,10 @@ func StartServer(cfg *config.Config) error {
 		return err
 	}
 
+	// the timeout is enforced by the transport, so it can be changed on config reload.
+	timeoutTr := newTimeoutTransport(tr, timeout)
 	client := &http.Client{
-		Timeout:   timeout,
-		Transport: tr,
+		Transport: timeoutTr,
 	}
 
 	// pass a copy of client because newNodeInfo may modify Transport.
@@ -67,22 +120,134 @@ func StartServer(cfg *config.Config) error {
 		return err
 	}
 
-	router, err := newAPIRouter(cfg, client, nodeInfo)
+	auditLogger, err := newAuditLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("Unable to initialize audit logger: %s", err)
+	}
+
+	if auditLogger != nil {
+		defer auditLogger.Close()
+	}
+
+	if _, err := time.ParseDuration(cfg.FlagShutdownTimeout); err != nil {
+		return err
+	}
+
+	if _, err := time.ParseDuration(cfg.FlagMesosTimeout); err != nil {
+		return err
+	}
+
+	live, err := newLiveConfig(cfg, timeoutTr)
+	if err != nil {
+		return err
+	}
+
+	// shutdown channel is closed when the server stops accepting new connections, the streaming handlers
+	// end the streams once it's closed.
+	shutdown := make(chan struct{})
+	router, err := newAPIRouter(live, client, nodeInfo, auditLogger, shutdown)
 	if err != nil {
 		return err
 	}
 
+	listener, err := newListener(cfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler: router,
+	}
+	srv.RegisterOnShutdown(func() {
+		close(shutdown)
+	})
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+	notifySystemd(localChecks(nodeInfo))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	for {
+		select {
+		case err := <-serveErr:
+			return err
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				logrus.Info("Received SIGHUP, reloading config")
+				if err := live.reload(); err != nil {
+					logrus.Errorf("Unable to reload config, keeping the current one: %s", err)
+				}
+				continue
+			}
+
+			logrus.Infof("Received %s, shutting down", sig)
+			// the value was validated on load.
+			shutdownTimeout, _ := time.ParseDuration(live.get().FlagShutdownTimeout)
+			return shutdownServer(srv, shutdownTimeout)
+		}
+	}
+}
+
+// newListener returns a socket passed by systemd if the service is socket activated, otherwise
+// a TCP listener on the configured port. If the server certificate is configured, the listener serves TLS.
+func newListener(cfg *config.Config) (net.Listener, error) {
 	listeners, err := activation.Listeners(true)
 	if err != nil {
-		return fmt.Errorf("Unable to get listeners: %s", err)
+		return nil, fmt.Errorf("Unable to get listeners: %s", err)
 	}
 
+	var listener net.Listener
+
 	// Listen on unix socket
 	if len(listeners) == 1 {
 		logrus.Infof("Listen on %s", listeners[0].Addr().String())
-		return http.Serve(listeners[0], router)
+		listener = listeners[0]
+	} else {
+		logrus.Infof("Starting web server on %d", cfg.FlagPort)
+		listener, err = net.Listen("tcp", fmt.Sprintf(":%d", cfg.FlagPort))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.FlagTLSCertFile == "" {
+		return listener, nil
+	}
+
+	reloader, err := newCertReloader(cfg.FlagTLSCertFile, cfg.FlagTLSKeyFile, cfg.FlagTLSClientCAFile)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	if cfg.FlagTLSClientCAFile != "" {
+		logrus.Infof("Serving HTTPS, client certificates are verified with %s", cfg.FlagTLSClientCAFile)
+	} else {
+		logrus.Info("Serving HTTPS")
+	}
+	return tls.NewListener(listener, reloader.tlsConfig()), nil
+}
+
+// shutdownServer stops accepting new connections and waits for the open requests to finish. The streaming
+// handlers are notified and send the shutdown event to the clients. The connections still open after
+// the timeout are closed.
+func shutdownServer(srv *http.Server, timeout time.Duration) error {
+	if _, err := daemon.SdNotify("STOPPING=1"); err != nil {
+		logrus.Errorf("unable to notify systemd: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logrus.Errorf("Unable to drain open connections in %s: %s", timeout, err)
+		return srv.Close()
 	}
 
-	logrus.Infof("Starting web server on %d", cfg.FlagPort)
-	return http.ListenAndServe(fmt.Sprintf(":%d", cfg.FlagPort), router)
+	logrus.Info("Server stopped")
+	return nil
 }
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// certReloadInterval is how often the certificate files are checked for changes.
+const certReloadInterval = 10 * time.Second
+
+// certReloader holds the server certificate and the client CA pool. The files are checked for changes on
+// incoming TLS handshakes, at most once in certReloadInterval, and reloaded if modified. If a reload fails,
+// the previously loaded certificates are kept.
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	interval     time.Duration
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile, keyFile, clientCAFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("certificate and key files must be set")
+	}
+
+	c := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		interval:     certReloadInterval,
+	}
+
+	modTimes, err := c.stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.load(modTimes); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *certReloader) files() []string {
+	files := []string{c.certFile, c.keyFile}
+	if c.clientCAFile != "" {
+		files = append(files, c.clientCAFile)
+	}
+	return files
+}
+
+func (c *certReloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, f := range c.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[f] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (c *certReloader) load(modTimes map[string]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load server certificate: %s", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if c.clientCAFile != "" {
+		caPEM, err := ioutil.ReadFile(c.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("unable to read client CA: %s", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in %s", c.clientCAFile)
+		}
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.modTimes = modTimes
+	c.lastCheck = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// reloadIfModified reloads the certificates if any of the files has changed since the last load.
+func (c *certReloader) reloadIfModified() {
+	c.mu.Lock()
+	if time.Since(c.lastCheck) < c.interval {
+		c.mu.Unlock()
+		return
+	}
+	c.lastCheck = time.Now()
+	current := c.modTimes
+	c.mu.Unlock()
+
+	modTimes, err := c.stat()
+	if err != nil {
+		logrus.Errorf("unable to check certificate files: %s", err)
+		return
+	}
+
+	modified := false
+	for f, modTime := range modTimes {
+		if !modTime.Equal(current[f]) {
+			modified = true
+			break
+		}
+	}
+
+	if !modified {
+		return
+	}
+
+	if err := c.load(modTimes); err != nil {
+		logrus.Errorf("unable to reload certificates, using the previous ones: %s", err)
+		return
+	}
+	logrus.Infof("Reloaded TLS certificates from %v", c.files())
+}
+
+// getConfigForClient implements tls.Config GetConfigForClient, it returns a config with the current certificates.
+func (c *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	c.reloadIfModified()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*c.cert},
+	}
+
+	if c.clientCAs != nil {
+		cfg.ClientCAs = c.clientCAs
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// tlsConfig returns the server TLS config.
+func (c *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: c.getConfigForClient,
+	}
+}
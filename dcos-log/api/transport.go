@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/mesos/files/reader"
+)
+
+// timeoutTransport limits the time of a request including reading the response body. Unlike http.Client
+// Timeout, the timeout can be changed while the client is in use.
+type timeoutTransport struct {
+	base http.RoundTripper
+
+	// timeout in nanoseconds, accessed atomically.
+	timeout int64
+}
+
+func newTimeoutTransport(base http.RoundTripper, timeout time.Duration) *timeoutTransport {
+	return &timeoutTransport{
+		base:    base,
+		timeout: int64(timeout),
+	}
+}
+
+// Timeout returns the current request timeout.
+func (t *timeoutTransport) Timeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.timeout))
+}
+
+// SetTimeout changes the timeout of the new requests.
+func (t *timeoutTransport) SetTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&t.timeout, int64(timeout))
+}
+
+// RoundTrip implements http.RoundTripper. The requests with a deadline already set in the context, e.g.
+// the requests to mesos files API, are not limited by the transport timeout. The requests with a streamed
+// body, e.g. the file downloads, are only limited until the response headers are received.
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timeout := t.Timeout()
+	if _, ok := req.Context().Deadline(); ok || timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(timeout, cancel)
+	release := func() {
+		timer.Stop()
+		cancel()
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// the body of a streamed response is read as long as the request context lasts.
+	if reader.StreamedBody(req.Context()) {
+		timer.Stop()
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: release}
+	return resp, nil
+}
+
+// cancelBody releases the request context once the response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
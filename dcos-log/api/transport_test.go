@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/mesos/files/reader"
+)
+
+func TestTimeoutTransport(t *testing.T) {
+	// the headers are sent at once, the body after 100ms.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: newTimeoutTransport(http.DefaultTransport, 20*time.Millisecond)}
+
+	for _, tc := range []struct {
+		ctx      context.Context
+		expected bool
+	}{
+		{ctx: context.Background(), expected: false},
+		{ctx: reader.WithStreamedBody(context.Background()), expected: true},
+	} {
+		req, err := http.NewRequest("GET", ts.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Do(req.WithContext(tc.ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if (err == nil && string(body) == "body") != tc.expected {
+			t.Fatalf("streamed body %t: unexpected body %q, %v", reader.StreamedBody(tc.ctx), body, err)
+		}
+	}
+}
@@ -11,9 +11,11 @@ import (
 	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/dcos/dcos-log/dcos-log/api/middleware"
 	"github.com/dcos/dcos-log/dcos-log/journal/reader"
+	"github.com/dcos/dcos-log/dcos-log/multiline"
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 // AllowedFields contain `Journald Container Logger module` fields except ExecutorInfo.
@@ -22,12 +24,14 @@ var AllowedFields = []string{"FRAMEWORK_ID", "AGENT_ID", "EXECUTOR_ID", "CONTAIN
 
 // Constants used as request valid GET parameters. All other parameter is ignored.
 const (
-	getParamLimit       getParam = "limit"
-	getParamSkipNext    getParam = "skip_next"
-	getParamSkipPrev    getParam = "skip_prev"
-	getParamFilter      getParam = "filter"
-	getParamCursor      getParam = "cursor"
-	getParamReadReverse getParam = "read_reverse"
+	getParamLimit          getParam = "limit"
+	getParamSkipNext       getParam = "skip_next"
+	getParamSkipPrev       getParam = "skip_prev"
+	getParamFilter         getParam = "filter"
+	getParamCursor         getParam = "cursor"
+	getParamReadReverse    getParam = "read_reverse"
+	getParamMultiline      getParam = "multiline"
+	getParamMultilineStart getParam = "multiline_start"
 )
 
 type getParam string
@@ -37,10 +41,21 @@ func (g getParam) String() string {
 }
 
 func httpError(w http.ResponseWriter, msg string, code int, req *http.Request) {
-	debugString := fmt.Sprintf("Message: %s [request URI: %s; remote address: %s; Accept: %s; Proto: %s]", msg,
+	details := fmt.Sprintf("request URI: %s; remote address: %s; Accept: %s; Proto: %s",
 		req.RequestURI, req.RemoteAddr, req.Header.Get("Accept"), req.Proto)
-	logrus.Error(debugString)
-	http.Error(w, debugString, code)
+	middleware.WriteError(w, req, code, msg, details)
+}
+
+// writeShutdownEvent sends the shutdown event with the cursor of the current journal entry.
+func writeShutdownEvent(w io.Writer, j *reader.Reader) {
+	cursor, err := j.Journal.GetCursor()
+	if err != nil {
+		logrus.Errorf("unable to get journal cursor: %s", err)
+	}
+
+	if err := middleware.WriteShutdownEvent(w, cursor); err != nil {
+		logrus.Errorf("unable to write shutdown event: %s", err)
+	}
 }
 
 // Cursor string contains special characters we have to escape. This function returns un-escaped cursor.
@@ -132,6 +147,11 @@ func getReadReverse(req *http.Request, stream bool) (bool, error) {
 	return strconv.ParseBool(readReverse)
 }
 
+func getMultiline(req *http.Request) (*multiline.Pattern, error) {
+	return multiline.Parse(req.URL.Query().Get(getParamMultiline.String()),
+		req.URL.Query().Get(getParamMultilineStart.String()))
+}
+
 func pathMatches(req *http.Request) []reader.JournalEntryMatch {
 	var matches []reader.JournalEntryMatch
 
@@ -168,6 +188,9 @@ func readJournalHandler(w http.ResponseWriter, req *http.Request) {
 
 	// for streaming endpoints and SSE logs format we include id: CursorID before each log entry.
 	entryFormatter := reader.NewEntryFormatter(req.Header.Get("Accept"), stream)
+	if redactor, ok := middleware.FromContextRedactor(req.Context()); ok {
+		entryFormatter = reader.NewRedactedFormatter(entryFormatter, redactor)
+	}
 
 	// get a list of matches from request path
 	matches := pathMatches(req)
@@ -212,6 +235,13 @@ func readJournalHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Read `multiline` and `multiline_start` parameters.
+	pattern, err := getMultiline(req)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest, req)
+		return
+	}
+
 	// Last-Event-ID is a value that contains a cursor. If the header is in the request, we should take
 	// the value and override the cursor parameter. This will work for streaming endpoints only.
 	// https://www.html5rocks.com/en/tutorials/eventsource/basics/#toc-lastevent-id
@@ -235,7 +265,8 @@ func readJournalHandler(w http.ResponseWriter, req *http.Request) {
 		reader.OptionLimit(limit),
 		reader.OptionSkipNext(skipNext),
 		reader.OptionSkipPrev(skipPrev),
-		reader.OptionReadReverse(readReverse))
+		reader.OptionReadReverse(readReverse),
+		reader.OptionMultiline(pattern))
 	if err != nil {
 		httpError(w, fmt.Sprintf("Error opening journal reader: %s", err), http.StatusInternalServerError, req)
 		return
@@ -272,7 +303,7 @@ func readJournalHandler(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 		if b == 0 {
-			httpError(w, "No match found", http.StatusNoContent, req)
+			middleware.WriteNoContent(w, req, "no match found")
 		}
 		return
 	}
@@ -280,6 +311,7 @@ func readJournalHandler(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("X-Accel-Buffering", "no")
 	f := w.(http.Flusher)
 	notify := w.(http.CloseNotifier).CloseNotify()
+	shutdown, _ := middleware.FromContextShutdown(req.Context())
 
 	f.Flush()
 	for {
@@ -289,8 +321,16 @@ func readJournalHandler(w http.ResponseWriter, req *http.Request) {
 				logrus.Debugf("Closing a client connection.Request URI: %s", req.RequestURI)
 				return
 			}
+		case <-shutdown:
+			{
+				if entryFormatter.GetContentType() == reader.ContentTypeEventStream {
+					writeShutdownEvent(w, j)
+					f.Flush()
+				}
+				return
+			}
 		case <-time.After(time.Second):
-			err := j.Follow(time.Millisecond * 100, w)
+			err := j.Follow(time.Millisecond*100, w)
 			if err != nil {
 				logrus.Errorf("error reading journal %s", err)
 				return
@@ -331,8 +371,7 @@ func fieldHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if len(values) == 0 {
-		msg := fmt.Sprintf("Field %s not found", field)
-		httpError(w, msg, http.StatusNoContent, req)
+		middleware.WriteNoContent(w, req, fmt.Sprintf("field %s not found", field))
 		return
 	}
 
@@ -146,3 +146,27 @@ func TestGetMatches(t *testing.T) {
 		t.Fatalf("Expecting FOO=bar match. Got %+v", matches[1])
 	}
 }
+
+func TestGetMultiline(t *testing.T) {
+	for query, valid := range map[string]bool{
+		"":                                    true,
+		"?multiline=java":                     true,
+		"?multiline_start=%5E%5C%5B":          true,
+		"?multiline=cobol":                    false,
+		"?multiline=java&multiline_start=%5E": false,
+	} {
+		r, err := http.NewRequest("GET", query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pattern, err := getMultiline(r)
+		if valid != (err == nil) {
+			t.Fatalf("%q: expect valid %t. Got %v", query, valid, err)
+		}
+
+		if valid && (pattern != nil) != (query != "") {
+			t.Fatalf("%q: unexpected pattern %v", query, pattern)
+		}
+	}
+}
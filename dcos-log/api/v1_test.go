@@ -210,7 +210,12 @@ func newRequest(path string, headers map[string]string, method, role string) (*h
 		return nil, err
 	}
 
-	r, err := newAPIRouter(cfg, nil, nil)
+	live, err := newLiveConfig(cfg, nil)
+	if err != nil {
+		return w, err
+	}
+
+	r, err := newAPIRouter(live, nil, nil, nil, nil)
 	if err != nil {
 		return w, err
 	}
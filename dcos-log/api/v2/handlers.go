@@ -9,16 +9,24 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dcos/dcos-go/dcos"
 	"github.com/dcos/dcos-go/dcos/nodeutil"
 	"github.com/dcos/dcos-log/dcos-log/api/middleware"
+	"github.com/dcos/dcos-log/dcos-log/cluster"
+	"github.com/dcos/dcos-log/dcos-log/config"
 	jr "github.com/dcos/dcos-log/dcos-log/journal/reader"
 	"github.com/dcos/dcos-log/dcos-log/mesos/files/reader"
+	"github.com/dcos/dcos-log/dcos-log/mesos/sandbox"
+	"github.com/dcos/dcos-log/dcos-log/metrics"
+	"github.com/dcos/dcos-log/dcos-log/multiline"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
@@ -32,15 +40,65 @@ const (
 	cursorParam = "cursor"
 	limitParam  = "limit"
 	filterParam = "filter"
+	parseParam  = "parse"
+	sinceParam  = "since"
+	untilParam  = "until"
+
+	multilineParam      = "multiline"
+	multilineStartParam = "multiline_start"
+
+	recursiveParam = "recursive"
+	depthParam     = "depth"
+	globParam      = "glob"
+
+	pathParam   = "path"
+	formatParam = "format"
+
+	nodeParam      = "node"
+	completedParam = "completed"
+
+	windowParam = "window"
+	allParam    = "all"
+
+	parseJSON = "json"
 
 	cursorEndParam = "END"
 	cursorBegParam = "BEG"
 )
 
+const (
+	// agentLogsPrefix is a path of dcos-log in admin router on the agents.
+	agentLogsPrefix = "/system/v1/logs"
+
+	// defaultSearchLimit is a number of entries requested from every agent if the search has no limit.
+	defaultSearchLimit = 100
+
+	// dcosUnitPrefix is a prefix of DC/OS systemd units, the components listed by default.
+	dcosUnitPrefix = "dcos-"
+
+	// defaultComponentWindow is a window the component entries are counted in if not set, maxComponentWindow
+	// limits the number of journal entries read by a request.
+	defaultComponentWindow = time.Hour
+	maxComponentWindow     = 6 * time.Hour
+
+	// componentsCacheTTL is a time the components are served from the cache instead of reading the journal.
+	componentsCacheTTL = 30 * time.Second
+
+	// componentsReadTimeout limits a read of the components, it's not cancelled by the requests waiting for it.
+	componentsReadTimeout = time.Minute
+)
+
 const (
 	eventStreamContentType = "text/event-stream"
+	jsonContentType        = "application/json"
 )
 
+// recentComponents caches the components listed by componentsHandler.
+var recentComponents = newComponentsCache(jr.Components)
+
+var setupFilesAPIReaderErrors = metrics.NewCounter("dcos_log_files_api_reader_setup_errors_total",
+	"Total number of errors initializing mesos files API reader by response code.", "code")
+
 type errSetupFilesAPIReader struct {
 	msg  string
 	code int
@@ -51,11 +109,59 @@ func (e errSetupFilesAPIReader) Error() string {
 }
 
 func logError(w http.ResponseWriter, req *http.Request, msg string, code int) {
-	http.Error(w, msg, code)
-	logrus.Errorf("%s; http code: %d, request %s", msg, code, req.URL)
+	middleware.WriteError(w, req, code, msg, "")
+}
+
+// agentUnavailable replies with 503 if the requests to mesos agent are failing fast. The client may retry
+// once the agent is probed again.
+func agentUnavailable(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(reader.BreakerOpenTimeout.Seconds())))
+	logError(w, req, reader.ErrAgentUnavailable.Error(), http.StatusServiceUnavailable)
+}
+
+// errorEvent is a name of the server sent event written before a stream is ended because of an error.
+const errorEvent = "error"
+
+// writeAgentUnavailableEvent writes the error event once the stream has started and the requests to mesos agent
+// are failing fast. The retry field delays the browser reconnect until the agent is probed again, the cursor
+// is the event ID, so the stream resumes from the last sent line.
+func writeAgentUnavailableEvent(w io.Writer, cursor string) error {
+	retryAfter := int(reader.BreakerOpenTimeout.Seconds())
+	data, err := json.Marshal(struct {
+		Error      string `json:"error"`
+		Cursor     string `json:"cursor"`
+		RetryAfter int    `json:"retry_after"`
+	}{
+		Error:      reader.ErrAgentUnavailable.Error(),
+		Cursor:     cursor,
+		RetryAfter: retryAfter,
+	})
+	if err != nil {
+		return err
+	}
+
+	event := fmt.Sprintf("event: %s\nid: %s\nretry: %d\ndata: %s\n\n", errorEvent, cursor,
+		reader.BreakerOpenTimeout/time.Millisecond, data)
+	_, err = io.WriteString(w, event)
+	return err
 }
 
 func setupFilesAPIReader(req *http.Request, urlPath string, opts ...reader.Option) (r *reader.ReadManager, err error) {
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		code := http.StatusInternalServerError
+		if e, ok := err.(errSetupFilesAPIReader); ok {
+			code = e.code
+		} else if err == reader.ErrFileNotFound {
+			code = http.StatusNotFound
+		} else if err == reader.ErrAgentUnavailable {
+			code = http.StatusServiceUnavailable
+		}
+		setupFilesAPIReaderErrors.Inc(strconv.Itoa(code))
+	}()
 
 	cfg, ok := middleware.FromContextConfig(req.Context())
 	if !ok {
@@ -81,11 +187,6 @@ func setupFilesAPIReader(req *http.Request, urlPath string, opts ...reader.Optio
 		}
 	}
 
-	scheme := "http"
-	if cfg.FlagAuth {
-		scheme = "https"
-	}
-
 	token, ok := middleware.FromContextToken(req.Context())
 	if !ok {
 		return nil, errSetupFilesAPIReader{
@@ -104,10 +205,17 @@ func setupFilesAPIReader(req *http.Request, urlPath string, opts ...reader.Optio
 	header := http.Header{}
 	header.Set("Authorization", token)
 
-	newOpts := []reader.Option{reader.OptHeaders(header)}
+	newOpts := []reader.Option{
+		reader.OptSandboxRoot(cfg.FlagSandboxRoot),
+		reader.OptTimeout(cfg.MesosTimeout()),
+		reader.OptChunkSize(cfg.FlagMesosChunkSize),
+		reader.OptRetries(cfg.FlagMesosRetries),
+		reader.OptMaxLineSize(cfg.FlagMesosMaxLineSize),
+		reader.OptHeaders(header),
+	}
 	newOpts = append(newOpts, opts...)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(req.Context(), cfg.MesosTimeout())
 	defer cancel()
 
 	mesosID, err := nodeInfo.MesosID(nodeutil.NewContextWithHeaders(ctx, header))
@@ -118,6 +226,12 @@ func setupFilesAPIReader(req *http.Request, urlPath string, opts ...reader.Optio
 		}
 	}
 
+	// mesos agent forgets the completed executors, their sandboxes are read from the disk until removed.
+	if cfg.FlagRole != dcos.RoleMaster && localSandboxAllowed(req, cfg) {
+		client = localFallbackClient(client, cfg.FlagSandboxRoot)
+		mesosID = sandboxAgentID(cfg.FlagSandboxRoot, mesosID, frameworkID, executorID, containerID)
+	}
+
 	ip, err := nodeInfo.DetectIP()
 	if err != nil {
 		return nil, errSetupFilesAPIReader{
@@ -127,20 +241,68 @@ func setupFilesAPIReader(req *http.Request, urlPath string, opts ...reader.Optio
 	}
 
 	masterURL := &url.URL{
-		Host:   net.JoinHostPort(ip.String(), strconv.Itoa(dcos.PortMesosAgent)),
-		Scheme: scheme,
+		Host:   net.JoinHostPort(ip.String(), strconv.Itoa(cfg.FlagMesosAgentPort)),
+		Scheme: cfg.MesosScheme(),
 		Path:   urlPath,
 	}
 
 	formatter := reader.LineFormat
-	if req.Header.Get("Accept") == eventStreamContentType {
+	switch req.Header.Get("Accept") {
+	case eventStreamContentType:
 		formatter = reader.SSEFormat
+	case jsonContentType:
+		formatter = reader.JSONFormat
+	}
+
+	if redactor, ok := middleware.FromContextRedactor(req.Context()); ok {
+		formatter = reader.Redacted(formatter, redactor)
 	}
 
-	return reader.NewLineReader(client, *masterURL, mesosID, frameworkID, executorID, containerID, taskPath, file, formatter,
+	return reader.NewLineReader(req.Context(), client, *masterURL, mesosID, frameworkID, executorID, containerID, taskPath, file, formatter,
 		newOpts...)
 }
 
+// localSandboxAllowed returns true if the request may read the sandboxes mesos agent no longer knows from
+// the disk. Mesos can't authorize these reads, only the verified principals listed in the config are allowed.
+func localSandboxAllowed(req *http.Request, cfg *config.Config) bool {
+	principal := middleware.VerifiedPrincipal(req)
+	if principal == "" {
+		return false
+	}
+
+	for _, allowed := range cfg.FlagLocalSandboxPrincipals {
+		if allowed == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// localFallbackClient returns a copy of the client which reads the files mesos agent does not know from the disk.
+func localFallbackClient(client *http.Client, sandboxRoot string) *http.Client {
+	fallback := *client
+	fallback.Transport = &sandbox.FallbackTransport{
+		Base:  client.Transport,
+		Local: &sandbox.LocalFiles{Root: sandboxRoot},
+	}
+	return &fallback
+}
+
+// sandboxAgentID returns the ID of the agent the sandbox is stored under. The sandboxes of the previous agent runs
+// are kept under the previous agent ID.
+func sandboxAgentID(sandboxRoot, agentID, frameworkID, executorID, containerID string) string {
+	runDir := filepath.Join(sandboxRoot, agentID, "frameworks", frameworkID, "executors", executorID, "runs", containerID)
+	if _, err := os.Stat(runDir); !os.IsNotExist(err) {
+		return agentID
+	}
+
+	previousID, err := sandbox.FindAgentID(sandboxRoot, frameworkID, executorID, containerID)
+	if err != nil {
+		return agentID
+	}
+	return previousID
+}
+
 func optLimit(limitStr string) ([]reader.Option, error) {
 	// return early on empty parameter
 	if limitStr == "" {
@@ -234,6 +396,7 @@ func buildOpts(req *http.Request) ([]reader.Option, error) {
 		{fn: optCursor, param: req.URL.Query().Get(cursorParam)},
 		{fn: optSkip, param: req.URL.Query().Get(skipParam)},
 		{fn: optLimit, param: req.URL.Query().Get(limitParam)},
+		{fn: optSince, param: req.URL.Query().Get(sinceParam)},
 	} {
 		opts, err := paramFn.fn(paramFn.param)
 		if err != nil {
@@ -246,6 +409,143 @@ func buildOpts(req *http.Request) ([]reader.Option, error) {
 	return collectedOpts, nil
 }
 
+// fieldMapping returns the JSON line keys from the config, the defaults are used for the keys not set.
+func fieldMapping(cfg *config.Config) reader.FieldMapping {
+	mapping := reader.DefaultFieldMapping
+	if cfg == nil || cfg.FlagJSONFieldKeys == nil {
+		return mapping
+	}
+
+	if keys := cfg.FlagJSONFieldKeys.Level; len(keys) > 0 {
+		mapping.Level = keys
+	}
+	if keys := cfg.FlagJSONFieldKeys.Timestamp; len(keys) > 0 {
+		mapping.Timestamp = keys
+	}
+	if keys := cfg.FlagJSONFieldKeys.Message; len(keys) > 0 {
+		mapping.Message = keys
+	}
+	return mapping
+}
+
+func optParse(parseStr string, mapping reader.FieldMapping) ([]reader.Option, error) {
+	switch parseStr {
+	case "":
+		return nil, nil
+	case parseJSON:
+		return []reader.Option{reader.OptParseJSON(mapping)}, nil
+	}
+	return nil, fmt.Errorf("unable to parse parse parameter. Supported values: %s. Got %s", parseJSON, parseStr)
+}
+
+// parseTime parses a time parameter in RFC3339 format.
+func parseTime(name, timeStr string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, timeStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse %s parameter. %s not in RFC3339 format", name, timeStr)
+	}
+	return t, nil
+}
+
+func optSince(sinceStr string) ([]reader.Option, error) {
+	// return early on empty parameter
+	if sinceStr == "" {
+		return nil, nil
+	}
+
+	since, err := parseTime(sinceParam, sinceStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return []reader.Option{reader.OptSince(since)}, nil
+}
+
+func optUntil(untilStr string) ([]reader.Option, error) {
+	// return early on empty parameter
+	if untilStr == "" {
+		return nil, nil
+	}
+
+	until, err := parseTime(untilParam, untilStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return []reader.Option{reader.OptUntil(until)}, nil
+}
+
+// multilinePattern returns the multiline grouping pattern from a preset name or a start regex, nil if none is set.
+func multilinePattern(req *http.Request) (*multiline.Pattern, error) {
+	return multiline.Parse(req.URL.Query().Get(multilineParam), req.URL.Query().Get(multilineStartParam))
+}
+
+// optTimestampLayouts returns the timestamp layouts from the config, the defaults are used if not set.
+func optTimestampLayouts(cfg *config.Config) []reader.Option {
+	if cfg == nil || len(cfg.FlagTimestampLayouts) == 0 {
+		return nil
+	}
+	return []reader.Option{reader.OptTimestampLayouts(cfg.FlagTimestampLayouts)}
+}
+
+func optFilter(filters []string) ([]reader.Option, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	var matches []reader.Match
+	for _, filter := range filters {
+		// the value may contain colons, e.g. a URL.
+		filterArray := strings.SplitN(filter, ":", 2)
+		if len(filterArray) != 2 {
+			return nil, fmt.Errorf("incorrect filter parameter format, must be ?filter=key:value. Got %s", filter)
+		}
+
+		// all matches must uppercase
+		matches = append(matches, reader.Match{
+			Field: strings.ToUpper(filterArray[0]),
+			Value: filterArray[1],
+		})
+	}
+
+	return []reader.Option{reader.OptMatch(matches)}, nil
+}
+
+// contentOpts returns the options selecting the content of the lines. Unlike the position options,
+// they apply when the client reconnects with Last-Event-ID.
+func contentOpts(req *http.Request) ([]reader.Option, error) {
+	cfg, _ := middleware.FromContextConfig(req.Context())
+	mapping := fieldMapping(cfg)
+
+	opts := []reader.Option{reader.OptFieldMapping(mapping)}
+	opts = append(opts, optTimestampLayouts(cfg)...)
+
+	parseOpts, err := optParse(req.URL.Query().Get(parseParam), mapping)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, parseOpts...)
+
+	filterOpts, err := optFilter(req.URL.Query()[filterParam])
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, filterOpts...)
+
+	untilOpts, err := optUntil(req.URL.Query().Get(untilParam))
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, untilOpts...)
+
+	pattern, err := multilinePattern(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(opts, reader.OptMultiline(pattern)), nil
+}
+
 func filesAPIHandler(w http.ResponseWriter, req *http.Request) {
 	opts, err := buildOpts(req)
 	if err != nil {
@@ -253,16 +553,30 @@ func filesAPIHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	moreOpts, err := contentOpts(req)
+	if err != nil {
+		logError(w, req, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts = append(opts, moreOpts...)
+
 	if req.Header.Get("Accept") == eventStreamContentType {
 		opts = append(opts, reader.OptStream(true))
 	}
 
 	r, err := setupFilesAPIReader(req, "/files/read", opts...)
+	if r != nil {
+		defer r.Close()
+	}
+
 	switch err {
 	case nil:
 		break
 	case reader.ErrFileNotFound:
-		logError(w, req, "File not found", http.StatusNoContent)
+		logError(w, req, "File not found", http.StatusNotFound)
+		return
+	case reader.ErrAgentUnavailable:
+		agentUnavailable(w, req)
 		return
 	default:
 		e, ok := err.(errSetupFilesAPIReader)
@@ -276,6 +590,10 @@ func filesAPIHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if req.Header.Get("Accept") != eventStreamContentType {
+		if req.Header.Get("Accept") == jsonContentType {
+			w.Header().Set("Content-Type", jsonContentType)
+		}
+
 		for {
 			_, err := io.Copy(w, r)
 			switch err {
@@ -286,8 +604,12 @@ func filesAPIHandler(w http.ResponseWriter, req *http.Request) {
 			case reader.ErrFileNotFound:
 				logError(w, req, "File not found", http.StatusNotFound)
 				return
+			case reader.ErrAgentUnavailable:
+				agentUnavailable(w, req)
+				return
 			default:
-				logError(w, req, fmt.Sprintf("unexpected error while reading the logs: %s. Request: %s", err, req.RequestURI), http.StatusInternalServerError)
+				middleware.WriteError(w, req, http.StatusInternalServerError, "unexpected error while reading the logs",
+					err.Error())
 				return
 			}
 		}
@@ -308,6 +630,7 @@ func filesAPIHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	notify := w.(http.CloseNotifier).CloseNotify()
+	shutdown, _ := middleware.FromContextShutdown(req.Context())
 
 	f.Flush()
 	for {
@@ -317,12 +640,33 @@ func filesAPIHandler(w http.ResponseWriter, req *http.Request) {
 				logrus.Debugf("Closing a client connection. Request URI: %s", req.RequestURI)
 				return
 			}
+		case <-req.Context().Done():
+			{
+				logrus.Debugf("Request context done: %s. Request URI: %s", req.Context().Err(), req.RequestURI)
+				return
+			}
+		case <-shutdown:
+			{
+				if err := middleware.WriteShutdownEvent(w, strconv.Itoa(r.Cursor())); err != nil {
+					logrus.Errorf("unable to write shutdown event: %s", err)
+				}
+				f.Flush()
+				return
+			}
 		case <-time.After(time.Microsecond * 100):
 			{
 				// TODO(rgoegge): This is a temporary fix.
 				// Not ideal, but will feel responsive enough to the enduser for now.
 				// The right fix should be a blocking io.Copy() call until there is data to read.
 				bytes, err := io.Copy(w, r)
+				if err == reader.ErrAgentUnavailable {
+					if err := writeAgentUnavailableEvent(w, strconv.Itoa(r.Cursor())); err != nil {
+						logrus.Errorf("unable to write error event: %s", err)
+					}
+					f.Flush()
+					return
+				}
+
 				if bytes == 0 {
 					time.Sleep(time.Second)
 				}
@@ -335,11 +679,14 @@ func filesAPIHandler(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func redirectURL(id *nodeutil.CanonicalTaskID, file, RawQuery string, browse, download bool) (string, error) {
-	if browse && download {
-		return "", errors.New("browse and download are mutually excluded and cannot be used at the same time")
-	}
+// sandboxContainerID returns the container of the run directory with the task sandbox. For pod tasks it's
+// the executor container, the last element, not the nested task container.
+func sandboxContainerID(id *nodeutil.CanonicalTaskID) string {
+	return id.ContainerIDs[len(id.ContainerIDs)-1]
+}
 
+// taskLogPath returns the path of the task sandbox in dcos-log on the agent running the task.
+func taskLogPath(id *nodeutil.CanonicalTaskID) string {
 	// find if the task is standalone of a pod.
 	isPod := id.ExecutorID != ""
 	executorID := id.ExecutorID
@@ -347,15 +694,22 @@ func redirectURL(id *nodeutil.CanonicalTaskID, file, RawQuery string, browse, do
 		executorID = id.ID
 	}
 
-	// take the last element
-	taskID := id.ContainerIDs[len(id.ContainerIDs)-1]
-	taskLogURL := fmt.Sprintf("%s/%s/logs/v2/task/frameworks/%s/executors/%s/runs/%s", prefix, id.AgentID,
-		id.FrameworkID, executorID, taskID)
+	containerID := sandboxContainerID(id)
+	taskLogPath := fmt.Sprintf("/v2/task/frameworks/%s/executors/%s/runs/%s", id.FrameworkID, executorID, containerID)
 
 	if isPod {
-		taskLogURL += path.Join("/tasks", id.ID)
+		taskLogPath += path.Join("/tasks", id.ID)
+	}
+	return taskLogPath
+}
+
+func redirectURL(id *nodeutil.CanonicalTaskID, file, RawQuery string, browse, download bool) (string, error) {
+	if browse && download {
+		return "", errors.New("browse and download are mutually excluded and cannot be used at the same time")
 	}
 
+	taskLogURL := fmt.Sprintf("%s/%s/logs%s", prefix, id.AgentID, taskLogPath(id))
+
 	if browse {
 		taskLogURL = path.Join(taskLogURL, "/files/browse")
 	} else {
@@ -385,10 +739,96 @@ func downloadHandler(w http.ResponseWriter, req *http.Request) {
 	discover(w, req, false, true)
 }
 
+// completedAny is a completed parameter value which looks up the running tasks first, then the completed ones.
+const completedAny = "any"
+
+// taskCandidate is one of the tasks matching a discovered task name, returned with 300 Multiple Choices.
+type taskCandidate struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	AgentID     string `json:"agent_id"`
+	FrameworkID string `json:"framework_id"`
+	ExecutorID  string `json:"executor_id,omitempty"`
+	ContainerID string `json:"container_id,omitempty"`
+	State       string `json:"state"`
+	StartTime   string `json:"start_time,omitempty"`
+
+	// URL is the logs URL of the task, empty if the task never started a container.
+	URL string `json:"url,omitempty"`
+}
+
+// taskCandidates is the response of discover if the task name is ambiguous.
+type taskCandidates struct {
+	middleware.ErrorResponse
+	Candidates []taskCandidate `json:"candidates"`
+}
+
+// findTasks returns the tasks with the name or an ID containing it. A task with the exact ID is returned alone.
+// completed is true for the completed tasks, false for the running ones and any to prefer the running tasks
+// if there are some.
+func findTasks(state *cluster.State, name, completed string) []cluster.Task {
+	var running, done []cluster.Task
+	match := func(found []cluster.Task, tasks []cluster.Task) []cluster.Task {
+		for _, t := range tasks {
+			if t.Name == name || strings.Contains(t.ID, name) {
+				found = append(found, t)
+			}
+		}
+		return found
+	}
+
+	for _, framework := range state.Frameworks {
+		running = match(running, framework.Tasks)
+		done = match(done, framework.CompletedTasks)
+	}
+	for _, framework := range state.CompletedFrameworks {
+		done = match(done, framework.Tasks)
+		done = match(done, framework.CompletedTasks)
+	}
+
+	var all []cluster.Task
+	switch completed {
+	case "true":
+		all = done
+	case "false":
+		all = running
+	default:
+		all = append(running, done...)
+	}
+
+	for _, t := range all {
+		if t.ID == name {
+			return []cluster.Task{t}
+		}
+	}
+
+	if completed == completedAny && len(running) > 0 {
+		return running
+	}
+	return all
+}
+
+// parseCompleted parses the completed parameter, any by default.
+func parseCompleted(completed string) (string, error) {
+	switch completed {
+	case "":
+		return completedAny, nil
+	case "true", "false", completedAny:
+		return completed, nil
+	}
+	return "", fmt.Errorf("invalid completed parameter %s. Must be true, false or %s", completed, completedAny)
+}
+
 func discover(w http.ResponseWriter, req *http.Request, browse, download bool) {
-	nodeInfo, ok := middleware.FromContextNodeInfo(req.Context())
+	cfg, ok := middleware.FromContextConfig(req.Context())
+	if !ok {
+		logError(w, req, fmt.Sprintf("invalid context, unable to retrieve %T object", cfg), http.StatusInternalServerError)
+		return
+	}
+
+	client, ok := middleware.FromContextHTTPClient(req.Context())
 	if !ok {
-		logError(w, req, "invalid context, unable to retrieve a nodeInfo object", http.StatusInternalServerError)
+		logError(w, req, fmt.Sprintf("invalid context, unable to retrieve %T object", client), http.StatusInternalServerError)
 		return
 	}
 
@@ -405,16 +845,17 @@ func discover(w http.ResponseWriter, req *http.Request, browse, download bool) {
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	query := req.URL.Query()
+	completed, err := parseCompleted(query.Get(completedParam))
+	if err != nil {
+		logError(w, req, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// try to get the canonical ID for a running task first.
-	var (
-		canonicalTaskID *nodeutil.CanonicalTaskID
-		err             error
-	)
+	// the completed parameter is not passed to the task logs.
+	query.Del(completedParam)
+	rawQuery := query.Encode()
 
-	// add headers to context
 	token, ok := middleware.FromContextToken(req.Context())
 	if !ok {
 		logError(w, req, "unable to get authorization header from a request", http.StatusUnauthorized)
@@ -423,23 +864,68 @@ func discover(w http.ResponseWriter, req *http.Request, browse, download bool) {
 
 	header := http.Header{}
 	header.Set("Authorization", token)
-	ctx = nodeutil.NewContextWithHeaders(ctx, header)
 
-	// TODO: expose this option to a user.
-	for _, completed := range []bool{false, true} {
-		canonicalTaskID, err = nodeInfo.TaskCanonicalID(ctx, taskID, completed)
-		if err == nil {
-			break
+	ctx, cancel := context.WithTimeout(req.Context(), cfg.MesosTimeout())
+	state, err := cluster.GetState(ctx, client, cfg.MesosStateURL(), header)
+	cancel()
+
+	var tasks []cluster.Task
+	if err == nil {
+		tasks = findTasks(state, taskID, completed)
+	}
+
+	// mesos forgets the completed tasks, the agents look them up in the sandboxes left on the disk.
+	if len(tasks) == 0 && cfg.FlagRole != dcos.RoleMaster && completed != "false" && localSandboxAllowed(req, cfg) {
+		if discoverSandboxes(w, req, cfg, header, taskID, file, rawQuery, browse, download) {
+			return
+		}
+	}
+
+	if err != nil {
+		logError(w, req, "unable to get canonical task ID: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch len(tasks) {
+	case 0:
+		logError(w, req, fmt.Sprintf("task %s not found", taskID), http.StatusNotFound)
+		return
+	case 1:
+	default:
+		var candidates []taskCandidate
+		for _, task := range tasks {
+			candidate := taskCandidate{
+				ID:          task.ID,
+				Name:        task.Name,
+				AgentID:     task.SlaveID,
+				FrameworkID: task.FrameworkID,
+				ExecutorID:  task.ExecutorID,
+				State:       task.State,
+			}
+
+			if !task.StartTime.IsZero() {
+				candidate.StartTime = task.StartTime.UTC().Format(time.RFC3339)
+			}
+
+			if id, err := canonicalTaskID(task.Task); err == nil {
+				candidate.ContainerID = sandboxContainerID(id)
+				candidate.URL, _ = redirectURL(id, file, rawQuery, browse, download)
+			}
+			candidates = append(candidates, candidate)
 		}
+
+		writeCandidates(w, req, taskID, candidates)
+		return
 	}
 
+	id, err := canonicalTaskID(tasks[0].Task)
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to get canonical task ID: %s", err)
 		logError(w, req, errMsg, http.StatusInternalServerError)
 		return
 	}
 
-	taskURL, err := redirectURL(canonicalTaskID, file, req.URL.RawQuery, browse, download)
+	taskURL, err := redirectURL(id, file, rawQuery, browse, download)
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to build redirect URL: %s", err)
 		logError(w, req, errMsg, http.StatusInternalServerError)
@@ -449,46 +935,342 @@ func discover(w http.ResponseWriter, req *http.Request, browse, download bool) {
 	http.Redirect(w, req, taskURL, http.StatusSeeOther)
 }
 
-func journalHandler(w http.ResponseWriter, req *http.Request) {
-	acceptHeader := req.Header.Get("Accept")
-	useSSE := acceptHeader == eventStreamContentType
+// writeCandidates replies with 300 Multiple Choices and the tasks matching the discovered task name.
+func writeCandidates(w http.ResponseWriter, req *http.Request, taskID string, candidates []taskCandidate) {
+	resp := taskCandidates{
+		ErrorResponse: middleware.ErrorResponse{
+			Code:      middleware.ErrCodeFromStatus(http.StatusMultipleChoices),
+			Message:   fmt.Sprintf("found %d tasks matching %s, use the ID of one of them", len(candidates), taskID),
+			RequestID: middleware.RequestID(req),
+		},
+		Candidates: candidates,
+	}
 
-	// for streaming endpoints and SSE logs format we include id: CursorID before each log entry.
-	entryFormatter := jr.NewEntryFormatter(acceptHeader, useSSE)
-	var (
-		cursor string
-		err    error
-		opts   []jr.Option
-	)
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(http.StatusMultipleChoices)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logrus.Errorf("unable to encode task candidates: %s", err)
+	}
+}
 
-	if componentName := mux.Vars(req)["name"]; componentName != "" {
-		matches := []jr.JournalEntryMatch{
-			{
-				Field: "UNIT",
-				Value: componentName,
-			},
-			{
-				Field: "_SYSTEMD_UNIT",
-				Value: componentName,
-			},
-		}
+// sandboxTaskStatus is the state of the tasks found in the sandboxes mesos no longer knows.
+const sandboxTaskStatus = "TASK_UNKNOWN"
+
+// sandboxTaskID returns the canonical ID of the task which ran in a sandbox found on the agent. The sandboxes
+// of the previous agent runs are served by the current agent, so the task logs are routed to it.
+func sandboxTaskID(agentID string, run sandbox.Run) *nodeutil.CanonicalTaskID {
+	id := &nodeutil.CanonicalTaskID{
+		ID:           run.ExecutorID,
+		AgentID:      agentID,
+		FrameworkID:  run.FrameworkID,
+		ContainerIDs: []string{run.ContainerID},
+	}
 
-		opts = append(opts, jr.OptionMatchOR(matches))
+	if run.TaskPath != "" {
+		id.ID = run.TaskPath
+		id.ExecutorID = run.ExecutorID
 	}
+	return id
+}
 
-	// parse filters
-	if filters := req.URL.Query()[filterParam]; len(filters) > 0 {
-		var matches []jr.JournalEntryMatch
-		for _, filter := range filters {
-			filterArray := strings.Split(filter, ":")
-			if len(filterArray) != 2 {
-				logError(w, req, "incorrect filter parameter format, must be ?filer=key:value. Got "+filter, http.StatusBadRequest)
-				return
-			}
+// sandboxRuns scans the sandboxes on the agent for the runs of a task.
+func sandboxRuns(req *http.Request, cfg *config.Config, header http.Header, taskID string) (string, []sandbox.Run, error) {
+	nodeInfo, ok := middleware.FromContextNodeInfo(req.Context())
+	if !ok {
+		return "", nil, fmt.Errorf("invalid context, unable to retrieve a %T object", nodeInfo)
+	}
 
-			// all matches must uppercase
-			matches = append(matches, jr.JournalEntryMatch{
-				Field: strings.ToUpper(filterArray[0]),
+	ctx, cancel := context.WithTimeout(req.Context(), cfg.MesosTimeout())
+	defer cancel()
+
+	mesosID, err := nodeInfo.MesosID(nodeutil.NewContextWithHeaders(ctx, header))
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to get mesosID: %s", err)
+	}
+
+	runs, err := sandbox.Scan(cfg.FlagSandboxRoot, taskID)
+	return mesosID, runs, err
+}
+
+// discoverSandboxes redirects to the logs of a task found in the sandboxes on the agent. It returns false
+// if no sandbox was found.
+func discoverSandboxes(w http.ResponseWriter, req *http.Request, cfg *config.Config, header http.Header, taskID, file,
+	rawQuery string, browse, download bool) bool {
+	mesosID, runs, err := sandboxRuns(req, cfg, header, taskID)
+	if err != nil {
+		logrus.Errorf("unable to scan the sandboxes for task %s: %s", taskID, err)
+		return false
+	}
+
+	switch len(runs) {
+	case 0:
+		return false
+	case 1:
+	default:
+		candidates := make([]taskCandidate, len(runs))
+		for i, run := range runs {
+			id := sandboxTaskID(mesosID, run)
+			candidates[i] = taskCandidate{
+				ID:          id.ID,
+				AgentID:     mesosID,
+				FrameworkID: run.FrameworkID,
+				ExecutorID:  id.ExecutorID,
+				ContainerID: run.ContainerID,
+				State:       sandboxTaskStatus,
+			}
+			candidates[i].URL, _ = redirectURL(id, file, rawQuery, browse, download)
+		}
+
+		writeCandidates(w, req, taskID, candidates)
+		return true
+	}
+
+	taskURL, err := redirectURL(sandboxTaskID(mesosID, runs[0]), file, rawQuery, browse, download)
+	if err != nil {
+		logError(w, req, fmt.Sprintf("unable to build redirect URL: %s", err), http.StatusInternalServerError)
+		return true
+	}
+
+	http.Redirect(w, req, taskURL, http.StatusSeeOther)
+	return true
+}
+
+// sandboxRun is a run of a task found in the sandboxes on the agent, with its logs URL.
+type sandboxRun struct {
+	sandbox.Run
+	URL string `json:"url"`
+}
+
+// sandboxesHandler returns the runs of a task found in the sandboxes on the agent, including the runs mesos
+// no longer knows.
+func sandboxesHandler(w http.ResponseWriter, req *http.Request) {
+	cfg, ok := middleware.FromContextConfig(req.Context())
+	if !ok {
+		logError(w, req, fmt.Sprintf("invalid context, unable to retrieve %T object", cfg), http.StatusInternalServerError)
+		return
+	}
+
+	token, ok := middleware.FromContextToken(req.Context())
+	if !ok {
+		logError(w, req, "unable to get authorization header from a request", http.StatusUnauthorized)
+		return
+	}
+
+	if !localSandboxAllowed(req, cfg) {
+		logError(w, req, "reading the sandboxes from the disk is not allowed", http.StatusForbidden)
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", token)
+
+	taskID := mux.Vars(req)["taskID"]
+	mesosID, runs, err := sandboxRuns(req, cfg, header, taskID)
+	if err != nil {
+		logError(w, req, "unable to scan the sandboxes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := struct {
+		Runs []sandboxRun `json:"runs"`
+	}{
+		Runs: make([]sandboxRun, len(runs)),
+	}
+
+	for i, run := range runs {
+		id := sandboxTaskID(mesosID, run)
+		resp.Runs[i] = sandboxRun{
+			Run: run,
+			URL: fmt.Sprintf("%s/%s/logs%s", prefix, id.AgentID, taskLogPath(id)),
+		}
+	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logrus.Errorf("unable to encode sandbox runs: %s", err)
+	}
+}
+
+// componentsParams parses the components window and whether all units are listed, not only DC/OS ones.
+func componentsParams(req *http.Request) (window time.Duration, all bool, err error) {
+	query := req.URL.Query()
+
+	window = defaultComponentWindow
+	if windowStr := query.Get(windowParam); windowStr != "" {
+		window, err = time.ParseDuration(windowStr)
+		if err != nil {
+			return 0, false, fmt.Errorf("unable to parse %s parameter: %s", windowParam, err)
+		}
+
+		if window <= 0 || window > maxComponentWindow {
+			return 0, false, fmt.Errorf("%s parameter must be positive and at most %s. Got %s", windowParam,
+				maxComponentWindow, window)
+		}
+	}
+
+	if allStr := query.Get(allParam); allStr != "" {
+		all, err = strconv.ParseBool(allStr)
+		if err != nil {
+			return 0, false, fmt.Errorf("unable to parse %s parameter: %s", allParam, err)
+		}
+	}
+	return window, all, nil
+}
+
+// componentsCache keeps the recently listed components by window and units for componentsCacheTTL. Reading
+// the journal is expensive, at most one read of a window runs at a time and the requests arriving while it runs
+// wait for its result. The read is not tied to the request which started it, a client going away does not
+// fail the other requests waiting for it.
+type componentsCache struct {
+	read componentsReader
+
+	mu      sync.Mutex
+	entries map[string]*componentsCall
+}
+
+// componentsCall is a running or a finished read of the components, the fields are set once done is closed.
+type componentsCall struct {
+	done       chan struct{}
+	components []jr.Component
+	err        error
+	expires    time.Time
+}
+
+// componentsReader reads the components of the units include accepts from the journal, e.g. jr.Components.
+type componentsReader func(ctx context.Context, window time.Duration, include func(unit string) bool) ([]jr.Component, error)
+
+func newComponentsCache(read componentsReader) *componentsCache {
+	return &componentsCache{read: read, entries: make(map[string]*componentsCall)}
+}
+
+// get returns the components in the window, all units or DC/OS ones only. It returns ctx.Err() if the read
+// does not finish before the context is done.
+func (c *componentsCache) get(ctx context.Context, window time.Duration, all bool) ([]jr.Component, error) {
+	key := fmt.Sprintf("%s %t", window, all)
+
+	c.mu.Lock()
+	call := c.entries[key]
+	if call == nil || c.expired(call) {
+		call = &componentsCall{done: make(chan struct{})}
+		c.entries[key] = call
+		go c.run(call, window, all)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.components, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// expired returns true if the call finished and its result is no longer served, the errors are never served
+// to the later requests. It must be called with the lock held.
+func (c *componentsCache) expired(call *componentsCall) bool {
+	select {
+	case <-call.done:
+		return call.err != nil || time.Now().After(call.expires)
+	default:
+		return false
+	}
+}
+
+// run reads the components. The failed reads are not cached, the next request reads the journal again.
+func (c *componentsCache) run(call *componentsCall, window time.Duration, all bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), componentsReadTimeout)
+	defer cancel()
+
+	call.components, call.err = c.read(ctx, window, func(unit string) bool {
+		return all || strings.HasPrefix(unit, dcosUnitPrefix)
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	call.expires = time.Now().Add(componentsCacheTTL)
+	close(call.done)
+
+	// the expired entries are dropped, the cache holds at most the recently requested windows.
+	for key, cached := range c.entries {
+		if c.expired(cached) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// componentsHandler lists the systemd units with journal entries, the most severe first, with their activity
+// in the window.
+func componentsHandler(w http.ResponseWriter, req *http.Request) {
+	window, all, err := componentsParams(req)
+	if err != nil {
+		logError(w, req, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := recentComponents.get(req.Context(), window, all)
+	if err != nil {
+		logError(w, req, "unable to read the components from journald: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := struct {
+		Window     string         `json:"window"`
+		Components []jr.Component `json:"components"`
+	}{
+		Window:     window.String(),
+		Components: list,
+	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logrus.Errorf("unable to encode components: %s", err)
+	}
+}
+
+func journalHandler(w http.ResponseWriter, req *http.Request) {
+	acceptHeader := req.Header.Get("Accept")
+	useSSE := acceptHeader == eventStreamContentType
+
+	// for streaming endpoints and SSE logs format we include id: CursorID before each log entry.
+	entryFormatter := jr.NewEntryFormatter(acceptHeader, useSSE)
+	if redactor, ok := middleware.FromContextRedactor(req.Context()); ok {
+		entryFormatter = jr.NewRedactedFormatter(entryFormatter, redactor)
+	}
+	var (
+		cursor string
+		err    error
+		opts   []jr.Option
+	)
+
+	if componentName := mux.Vars(req)["name"]; componentName != "" {
+		matches := []jr.JournalEntryMatch{
+			{
+				Field: "UNIT",
+				Value: componentName,
+			},
+			{
+				Field: "_SYSTEMD_UNIT",
+				Value: componentName,
+			},
+		}
+
+		opts = append(opts, jr.OptionMatchOR(matches))
+	}
+
+	// parse filters
+	if filters := req.URL.Query()[filterParam]; len(filters) > 0 {
+		var matches []jr.JournalEntryMatch
+		for _, filter := range filters {
+			filterArray := strings.Split(filter, ":")
+			if len(filterArray) != 2 {
+				logError(w, req, "incorrect filter parameter format, must be ?filer=key:value. Got "+filter, http.StatusBadRequest)
+				return
+			}
+
+			// all matches must uppercase
+			matches = append(matches, jr.JournalEntryMatch{
+				Field: strings.ToUpper(filterArray[0]),
 				Value: filterArray[1],
 			})
 		}
@@ -556,6 +1338,13 @@ func journalHandler(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	pattern, err := multilinePattern(req)
+	if err != nil {
+		logError(w, req, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts = append(opts, jr.OptionMultiline(pattern))
+
 	j, err := jr.NewReader(entryFormatter, opts...)
 	if err != nil {
 		logError(w, req, "unable to open journald: "+err.Error(), http.StatusInternalServerError)
@@ -583,7 +1372,7 @@ func journalHandler(w http.ResponseWriter, req *http.Request) {
 		}
 
 		if b == 0 {
-			logError(w, req, "No match found", http.StatusNoContent)
+			middleware.WriteNoContent(w, req, "no match found")
 		}
 		return
 	}
@@ -591,6 +1380,7 @@ func journalHandler(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("X-Accel-Buffering", "no")
 	f := w.(http.Flusher)
 	notify := w.(http.CloseNotifier).CloseNotify()
+	shutdown, _ := middleware.FromContextShutdown(req.Context())
 
 	f.Flush()
 	for {
@@ -600,8 +1390,21 @@ func journalHandler(w http.ResponseWriter, req *http.Request) {
 				logrus.Debugf("closing a client connection.")
 				return
 			}
-		case <- time.After(time.Second):
-			err := j.Follow(time.Millisecond * 100, w)
+		case <-shutdown:
+			{
+				cursor, err := j.Journal.GetCursor()
+				if err != nil {
+					logrus.Errorf("unable to get journal cursor: %s", err)
+				}
+
+				if err := middleware.WriteShutdownEvent(w, cursor); err != nil {
+					logrus.Errorf("unable to write shutdown event: %s", err)
+				}
+				f.Flush()
+				return
+			}
+		case <-time.After(time.Second):
+			err := j.Follow(time.Millisecond*100, w)
 			if err != nil {
 				logrus.Errorf("error reading journal %s", err)
 				return
@@ -612,7 +1415,44 @@ func journalHandler(w http.ResponseWriter, req *http.Request) {
 
 }
 
+// browseParams parses the recursive browse parameters. depth and glob are only valid with recursive=true.
+func browseParams(req *http.Request) (recursive bool, depth int, glob string, err error) {
+	query := req.URL.Query()
+	if recursiveStr := query.Get(recursiveParam); recursiveStr != "" {
+		recursive, err = strconv.ParseBool(recursiveStr)
+		if err != nil {
+			return false, 0, "", fmt.Errorf("unable to parse recursive parameter. %s not a boolean", recursiveStr)
+		}
+	}
+
+	depthStr, glob := query.Get(depthParam), query.Get(globParam)
+	if !recursive && (depthStr != "" || glob != "") {
+		return false, 0, "", errors.New("depth and glob parameters require recursive=true")
+	}
+
+	depth = reader.DefaultBrowseDepth
+	if depthStr != "" {
+		depth, err = strconv.Atoi(depthStr)
+		if err != nil || depth <= 0 || depth > reader.MaxBrowseDepth {
+			return false, 0, "", fmt.Errorf("invalid depth parameter %s. Must be between 1 and %d", depthStr,
+				reader.MaxBrowseDepth)
+		}
+	}
+
+	if _, err := path.Match(glob, ""); err != nil {
+		return false, 0, "", fmt.Errorf("invalid glob parameter %s: %s", glob, err)
+	}
+
+	return recursive, depth, glob, nil
+}
+
 func browseFiles(w http.ResponseWriter, req *http.Request) {
+	recursive, depth, glob, err := browseParams(req)
+	if err != nil {
+		logError(w, req, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	token, ok := middleware.FromContextToken(req.Context())
 	if !ok {
 		logError(w, req, "unable to get authorization header from a request", http.StatusUnauthorized)
@@ -636,14 +1476,25 @@ func browseFiles(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	files, err := r.BrowseSandbox()
+	// the tree has the per-directory totals, the flat listing of the sandbox directory is kept by default.
+	var files interface{}
+	if recursive {
+		files, err = r.BrowseTree(req.Context(), depth, glob)
+	} else {
+		files, err = r.BrowseSandbox(req.Context())
+	}
+
+	if err == reader.ErrAgentUnavailable {
+		agentUnavailable(w, req)
+		return
+	}
 	if err != nil {
 		logError(w, req, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if err := json.NewEncoder(w).Encode(files); err != nil {
-		logError(w, req, fmt.Sprintf("unable to encode sandbox files: %s. Items: %s", err, files), http.StatusInternalServerError)
+		logError(w, req, fmt.Sprintf("unable to encode sandbox files: %s. Items: %v", err, files), http.StatusInternalServerError)
 		return
 	}
 }
@@ -672,7 +1523,11 @@ func downloadFile(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	downloadResp, err := r.Download()
+	downloadResp, err := r.Download(req.Context())
+	if err == reader.ErrAgentUnavailable {
+		agentUnavailable(w, req)
+		return
+	}
 	if err != nil {
 		logError(w, req, err.Error(), http.StatusInternalServerError)
 		return
@@ -685,8 +1540,456 @@ func downloadFile(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	_, err = io.Copy(w, downloadResp.Body)
+	var body io.Reader = downloadResp.Body
+	if redactor, ok := middleware.FromContextRedactor(req.Context()); ok && redactor != nil {
+		// the size of redacted content is unknown.
+		w.Header().Del("Content-Length")
+		body = redactor.NewReader(body)
+	}
+
+	_, err = io.Copy(w, body)
 	if err != nil {
 		logrus.Errorf("error raised while reading the download endpoint: %s", err)
 	}
 }
+
+// archiveParams parses the archive parameters. The format defaults to tar.gz, the path to the sandbox directory.
+func archiveParams(req *http.Request) (format, dir, glob string, err error) {
+	query := req.URL.Query()
+
+	format = query.Get(formatParam)
+	switch format {
+	case "":
+		format = reader.ArchiveTarGz
+	case reader.ArchiveTarGz, reader.ArchiveZip:
+	default:
+		return "", "", "", fmt.Errorf("invalid format parameter %s. Supported formats: %s, %s", format,
+			reader.ArchiveTarGz, reader.ArchiveZip)
+	}
+
+	glob = query.Get(globParam)
+	if _, err := path.Match(glob, ""); err != nil {
+		return "", "", "", fmt.Errorf("invalid glob parameter %s: %s", glob, err)
+	}
+
+	// the path is relative to the sandbox and cannot point outside of it.
+	return format, path.Clean("/" + query.Get(pathParam)), glob, nil
+}
+
+func archiveFiles(w http.ResponseWriter, req *http.Request) {
+	format, dir, glob, err := archiveParams(req)
+	if err != nil {
+		logError(w, req, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg, ok := middleware.FromContextConfig(req.Context())
+	if !ok {
+		logError(w, req, fmt.Sprintf("invalid context, unable to retrieve %T object", cfg), http.StatusInternalServerError)
+		return
+	}
+
+	token, ok := middleware.FromContextToken(req.Context())
+	if !ok {
+		logError(w, req, "unable to get authorization header from a request", http.StatusUnauthorized)
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", token)
+
+	opts := []reader.Option{reader.OptHeaders(header)}
+
+	r, err := setupFilesAPIReader(req, "/files/browse", opts...)
+	if err != nil {
+		e, ok := err.(errSetupFilesAPIReader)
+		if !ok {
+			logError(w, req, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logError(w, req, e.msg, e.code)
+		return
+	}
+
+	archive, err := r.NewArchive(req.Context(), dir, glob, uint64(cfg.FlagArchiveMaxSize)<<20)
+	switch err {
+	case nil:
+	case reader.ErrAgentUnavailable:
+		agentUnavailable(w, req)
+		return
+	case reader.ErrFileNotFound:
+		logError(w, req, fmt.Sprintf("directory %s not found", dir), http.StatusNotFound)
+		return
+	case reader.ErrArchiveTooLarge:
+		logError(w, req, fmt.Sprintf("%s. Max size %d MB", err, cfg.FlagArchiveMaxSize), http.StatusRequestEntityTooLarge)
+		return
+	default:
+		logError(w, req, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var transform func(io.Reader) io.Reader
+	if redactor, ok := middleware.FromContextRedactor(req.Context()); ok && redactor != nil {
+		transform = redactor.NewReader
+	}
+
+	contentType := "application/gzip"
+	if format == reader.ArchiveZip {
+		contentType = "application/zip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archive.Name+"."+format))
+
+	// the headers are sent with the first bytes, the errors can only be logged.
+	if err := archive.Write(req.Context(), w, format, transform); err != nil {
+		logrus.Errorf("error writing %s archive of %s: %s", format, dir, err)
+	}
+}
+
+// agentURL returns the URL of dcos-log on an agent.
+func agentURL(cfg *config.Config, agent nodeutil.Slave, logPath string, query url.Values) string {
+	u := url.URL{
+		Scheme:   cfg.AgentLogsScheme(),
+		Host:     net.JoinHostPort(agent.Hostname, strconv.Itoa(cfg.FlagAgentLogsPort)),
+		Path:     agentLogsPrefix + logPath,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// canonicalTaskID returns the canonical ID of a task from mesos state.
+func canonicalTaskID(task nodeutil.Task) (*nodeutil.CanonicalTaskID, error) {
+	containerIDs, err := task.ContainerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	return &nodeutil.CanonicalTaskID{
+		ID:           task.ID,
+		AgentID:      task.SlaveID,
+		FrameworkID:  task.FrameworkID,
+		ExecutorID:   task.ExecutorID,
+		ContainerIDs: containerIDs,
+	}, nil
+}
+
+// searchQueries returns the agent queries of a cluster search. The journal is searched on every agent, the task logs
+// on the agents running the tasks with a matching name or ID. The node parameters select the agents by ID
+// or hostname, the other parameters are passed to the agents.
+func searchQueries(cfg *config.Config, state *cluster.State, vars map[string]string, query url.Values) []cluster.Query {
+	nodes := query[nodeParam]
+	selected := func(agent nodeutil.Slave) bool {
+		if len(nodes) == 0 {
+			return true
+		}
+
+		for _, node := range nodes {
+			if node == agent.ID || node == agent.Hostname {
+				return true
+			}
+		}
+		return false
+	}
+
+	agentQuery := url.Values{}
+	for key, values := range query {
+		if key != nodeParam {
+			agentQuery[key] = values
+		}
+	}
+	if agentQuery.Get(limitParam) == "" {
+		agentQuery.Set(limitParam, strconv.Itoa(defaultSearchLimit))
+	}
+
+	agents := make(map[string]nodeutil.Slave, len(state.Slaves))
+	for _, agent := range state.Slaves {
+		agents[agent.ID] = agent
+	}
+
+	var queries []cluster.Query
+	taskID := vars["taskID"]
+	if taskID == "" {
+		logPath := path.Join("/v2/component", vars["name"])
+		for _, agent := range state.Slaves {
+			if selected(agent) {
+				queries = append(queries, cluster.Query{Node: agent.ID, Hostname: agent.Hostname,
+					URL: agentURL(cfg, agent, logPath, agentQuery)})
+			}
+		}
+		return queries
+	}
+
+	file := vars["file"]
+	if file == "" {
+		file = "stdout"
+	}
+
+	for _, framework := range state.Frameworks {
+		for _, task := range framework.Tasks {
+			if task.Name != taskID && !strings.Contains(task.ID, taskID) {
+				continue
+			}
+
+			agent, ok := agents[task.SlaveID]
+			if !ok || !selected(agent) {
+				continue
+			}
+
+			id, err := canonicalTaskID(task.Task)
+			if err != nil {
+				logrus.Warnf("unable to search task %s: %s", task.ID, err)
+				continue
+			}
+
+			queries = append(queries, cluster.Query{Node: agent.ID, Hostname: agent.Hostname, Task: task.ID,
+				URL: agentURL(cfg, agent, path.Join(taskLogPath(id), file), agentQuery)})
+		}
+	}
+	return queries
+}
+
+// searchHandler searches the journal or the task logs on the agents. The entries are merged by timestamp,
+// the agents which failed are reported in the result.
+func searchHandler(w http.ResponseWriter, req *http.Request) {
+	cfg, ok := middleware.FromContextConfig(req.Context())
+	if !ok {
+		logError(w, req, fmt.Sprintf("invalid context, unable to retrieve %T object", cfg), http.StatusInternalServerError)
+		return
+	}
+
+	client, ok := middleware.FromContextHTTPClient(req.Context())
+	if !ok {
+		logError(w, req, fmt.Sprintf("invalid context, unable to retrieve %T object", client), http.StatusInternalServerError)
+		return
+	}
+
+	token, ok := middleware.FromContextToken(req.Context())
+	if !ok {
+		logError(w, req, "unable to get authorization header from a request", http.StatusUnauthorized)
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", token)
+
+	ctx, cancel := context.WithTimeout(req.Context(), cfg.MesosTimeout())
+	state, err := cluster.GetState(ctx, client, cfg.MesosStateURL(), header)
+	cancel()
+	if err != nil {
+		logError(w, req, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	vars := mux.Vars(req)
+	queries := searchQueries(cfg, state, vars, req.URL.Query())
+	if len(queries) == 0 && vars["taskID"] != "" {
+		logError(w, req, fmt.Sprintf("task %s not found", vars["taskID"]), http.StatusNotFound)
+		return
+	}
+
+	searcher := &cluster.Searcher{
+		Client:      client,
+		Header:      header,
+		Concurrency: cfg.FlagSearchConcurrency,
+		Timeout:     cfg.SearchTimeout(),
+	}
+	result := searcher.Search(req.Context(), queries)
+
+	w.Header().Set("Content-Type", jsonContentType)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logrus.Errorf("unable to encode search result: %s", err)
+	}
+}
+
+// streamQueries returns the streams of the running tasks of a framework, by ID or name, with a name starting with
+// the task prefix. The streams are reopened without the position parameters, the task cursor is used instead.
+func streamQueries(cfg *config.Config, state *cluster.State, vars map[string]string, query url.Values) []cluster.Query {
+	resumeQuery := url.Values{}
+	for key, values := range query {
+		switch key {
+		case cursorParam, skipParam, limitParam, sinceParam:
+		default:
+			resumeQuery[key] = values
+		}
+	}
+
+	agents := make(map[string]nodeutil.Slave, len(state.Slaves))
+	for _, agent := range state.Slaves {
+		agents[agent.ID] = agent
+	}
+
+	file := vars["file"]
+	if file == "" {
+		file = "stdout"
+	}
+
+	var queries []cluster.Query
+	for _, framework := range state.Frameworks {
+		if framework.ID != vars["framework"] && framework.Name != vars["framework"] {
+			continue
+		}
+
+		for _, task := range framework.Tasks {
+			if task.State != "TASK_RUNNING" || !strings.HasPrefix(task.Name, vars["taskPrefix"]) {
+				continue
+			}
+
+			agent, ok := agents[task.SlaveID]
+			if !ok {
+				continue
+			}
+
+			id, err := canonicalTaskID(task.Task)
+			if err != nil {
+				logrus.Warnf("unable to stream task %s: %s", task.ID, err)
+				continue
+			}
+
+			logPath := path.Join(taskLogPath(id), file)
+			queries = append(queries, cluster.Query{
+				Node:      agent.ID,
+				Hostname:  agent.Hostname,
+				Task:      task.ID,
+				URL:       agentURL(cfg, agent, logPath, query),
+				ResumeURL: agentURL(cfg, agent, logPath, resumeQuery),
+			})
+		}
+	}
+	return queries
+}
+
+// labelEvent adds the task ID to the fields of a task stream event.
+func labelEvent(e cluster.Event) string {
+	var entry map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(e.Data), &entry); err != nil {
+		return e.Data
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(entry["fields"], &fields); err != nil || fields == nil {
+		return e.Data
+	}
+	fields["TASK_ID"] = e.Query.Task
+
+	labeled, err := json.Marshal(fields)
+	if err != nil {
+		return e.Data
+	}
+	entry["fields"] = labeled
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return e.Data
+	}
+	return string(data)
+}
+
+// streamHandler merges the live streams of the running instances of a task into one Server-Sent Events stream.
+// The tasks are looked up again periodically, e.g. to follow a rolling deploy.
+func streamHandler(w http.ResponseWriter, req *http.Request) {
+	cfg, ok := middleware.FromContextConfig(req.Context())
+	if !ok {
+		logError(w, req, fmt.Sprintf("invalid context, unable to retrieve %T object", cfg), http.StatusInternalServerError)
+		return
+	}
+
+	client, ok := middleware.FromContextHTTPClient(req.Context())
+	if !ok {
+		logError(w, req, fmt.Sprintf("invalid context, unable to retrieve %T object", client), http.StatusInternalServerError)
+		return
+	}
+
+	token, ok := middleware.FromContextToken(req.Context())
+	if !ok {
+		logError(w, req, "unable to get authorization header from a request", http.StatusUnauthorized)
+		return
+	}
+
+	cursors, err := cluster.ParseCursors(req.Header.Get("Last-Event-ID"))
+	if err != nil {
+		logError(w, req, "unable to parse Last-Event-ID header: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", token)
+
+	vars := mux.Vars(req)
+	query := req.URL.Query()
+	lookup := func(ctx context.Context) ([]cluster.Query, error) {
+		ctx, cancel := context.WithTimeout(ctx, cfg.MesosTimeout())
+		defer cancel()
+
+		state, err := cluster.GetState(ctx, client, cfg.MesosStateURL(), header)
+		if err != nil {
+			return nil, err
+		}
+		return streamQueries(cfg, state, vars, query), nil
+	}
+
+	queries, err := lookup(req.Context())
+	if err != nil {
+		logError(w, req, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(queries) == 0 {
+		logError(w, req, fmt.Sprintf("no running tasks of framework %s matching %s", vars["framework"],
+			vars["taskPrefix"]), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", eventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	f, ok := w.(http.Flusher)
+	if !ok {
+		logError(w, req, "unable to type assert ResponseWriter to Flusher", http.StatusInternalServerError)
+		return
+	}
+	shutdown, _ := middleware.FromContextShutdown(req.Context())
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	m := &cluster.Multiplexer{
+		Client:  client,
+		Header:  header,
+		Lookup:  lookup,
+		Cursors: cursors,
+	}
+	events := m.Stream(ctx, queries)
+
+	// some proxies close the idle connections, a ping comment keeps the stream open.
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+
+	lastEventID := req.Header.Get("Last-Event-ID")
+	f.Flush()
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Debugf("Request context done: %s. Request URI: %s", ctx.Err(), req.RequestURI)
+			return
+		case <-shutdown:
+			if err := middleware.WriteShutdownEvent(w, lastEventID); err != nil {
+				logrus.Errorf("unable to write shutdown event: %s", err)
+			}
+			f.Flush()
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			f.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			lastEventID = e.ID
+			fmt.Fprintf(w, "id: %s\ndata: %s\n\n", e.ID, labelEvent(e))
+			f.Flush()
+		}
+	}
+}
@@ -1,15 +1,28 @@
 package v2
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/dcos/dcos-log/dcos-log/mesos/files/reader"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/dcos/dcos-go/dcos/nodeutil"
+	"github.com/dcos/dcos-log/dcos-log/api/middleware"
+	"github.com/dcos/dcos-log/dcos-log/cluster"
+	"github.com/dcos/dcos-log/dcos-log/config"
+	jr "github.com/dcos/dcos-log/dcos-log/journal/reader"
+	"github.com/dcos/dcos-log/dcos-log/mesos/files/reader"
+	"github.com/dcos/dcos-log/dcos-log/mesos/sandbox"
 )
 
 type filesAPIResponse struct {
@@ -60,7 +73,7 @@ func makeRequest(req *http.Request, t *testing.T) string {
 		t.Fatal(err)
 	}
 
-	r, err := reader.NewLineReader(&http.Client{}, *testURL, "a", "b", "c", "d", "f",
+	r, err := reader.NewLineReader(context.Background(), &http.Client{}, *testURL, "a", "b", "c", "d", "f",
 		"stdout", reader.LineFormat, opts...)
 	if err != nil {
 		t.Fatal(err)
@@ -142,3 +155,373 @@ func TestBuildOptsSkip(t *testing.T) {
 		t.Fatalf("expect %s. Got %s", expectedResponse, resp)
 	}
 }
+
+func TestContentOpts(t *testing.T) {
+	for _, query := range []string{"?parse=json", "?filter=level:error", "?parse=json&filter=level:error&filter=code:500",
+		"?filter=url:http://host:8080/", "?until=2018-01-02T10:05:00Z", "?multiline=java", "?multiline_start=%5E%5C%5B"} {
+		req, err := http.NewRequest("GET", "/"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := contentOpts(req); err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+	}
+
+	for _, query := range []string{"?parse=xml", "?filter=level", "?until=10:05",
+		"?multiline=cobol", "?multiline_start=%28", "?multiline=go&multiline_start=%5Ea"} {
+		req, err := http.NewRequest("GET", "/"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := contentOpts(req); err == nil {
+			t.Fatalf("%s: expect an error", query)
+		}
+	}
+}
+
+func TestBrowseParams(t *testing.T) {
+	for query, valid := range map[string]bool{
+		"":                           true,
+		"?recursive=true":            true,
+		"?recursive=true&depth=2":    true,
+		"?recursive=true&glob=*.log": true,
+		"?recursive=false":           true,
+		"?recursive=yes":             false,
+		"?depth=2":                   false,
+		"?glob=*.log":                false,
+		"?recursive=true&depth=0":    false,
+		"?recursive=true&depth=100":  false,
+		"?recursive=true&glob=%5B":   false,
+		"?recursive=true&depth=two":  false,
+	} {
+		req, err := http.NewRequest("GET", "/"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, depth, _, err := browseParams(req)
+		if valid != (err == nil) {
+			t.Fatalf("%s: expect valid %t. Got %v", query, valid, err)
+		}
+
+		if valid && query != "?recursive=true&depth=2" && depth != reader.DefaultBrowseDepth {
+			t.Fatalf("%s: expect default depth. Got %d", query, depth)
+		}
+	}
+}
+
+func TestArchiveParams(t *testing.T) {
+	for query, expected := range map[string][]string{
+		"":                          {reader.ArchiveTarGz, "/"},
+		"?format=zip&path=logs":     {reader.ArchiveZip, "/logs"},
+		"?path=../../etc":           {reader.ArchiveTarGz, "/etc"},
+		"?format=tar.gz&glob=*.log": {reader.ArchiveTarGz, "/"},
+		"?format=rar":               nil,
+		"?glob=%5B":                 nil,
+	} {
+		req, err := http.NewRequest("GET", "/"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		format, dir, _, err := archiveParams(req)
+		if (expected == nil) != (err != nil) {
+			t.Fatalf("%s: expect valid %t. Got %v", query, expected != nil, err)
+		}
+
+		if expected != nil && (format != expected[0] || dir != expected[1]) {
+			t.Fatalf("%s: expect %v. Got %s %s", query, expected, format, dir)
+		}
+	}
+}
+
+func TestSearchQueries(t *testing.T) {
+	cfg := &config.Config{FlagAgentLogsScheme: "https", FlagAgentLogsPort: 61001}
+	task := func(id, name, agent, executor string) cluster.Task {
+		return cluster.Task{Task: nodeutil.Task{ID: id, Name: name, FrameworkID: "fw", ExecutorID: executor, SlaveID: agent,
+			Statuses: []nodeutil.Status{{ContainerStatus: nodeutil.ContainerStatus{
+				ContainerID: nodeutil.NestedValue{Value: "container-" + id}}}}}}
+	}
+	state := &cluster.State{
+		Slaves: []nodeutil.Slave{{ID: "a1", Hostname: "10.0.0.1"}, {ID: "a2", Hostname: "10.0.0.2"}},
+		Frameworks: []cluster.Framework{{Tasks: []cluster.Task{
+			task("web.1", "web", "a1", ""), task("web.2", "web", "a2", ""), task("db.1", "db", "a2", "pod"),
+		}}},
+	}
+
+	queries := searchQueries(cfg, state, map[string]string{"name": "dcos-log.service"},
+		url.Values{"filter": {"REQUEST_ID:42"}, "node": {"10.0.0.2"}})
+	if len(queries) != 1 || queries[0].Node != "a2" || queries[0].URL !=
+		"https://10.0.0.2:61001/system/v1/logs/v2/component/dcos-log.service?filter=REQUEST_ID%3A42&limit=100" {
+		t.Fatalf("unexpected component queries %+v", queries)
+	}
+
+	queries = searchQueries(cfg, state, map[string]string{"taskID": "web"}, url.Values{"limit": {"5"}})
+	if len(queries) != 2 || queries[0].Task != "web.1" || queries[1].URL !=
+		"https://10.0.0.2:61001/system/v1/logs/v2/task/frameworks/fw/executors/web.2/runs/container-web.2/stdout?limit=5" {
+		t.Fatalf("unexpected task queries %+v", queries)
+	}
+
+	queries = searchQueries(cfg, state, map[string]string{"taskID": "db.1", "file": "stderr"}, url.Values{})
+	if len(queries) != 1 || queries[0].URL !=
+		"https://10.0.0.2:61001/system/v1/logs/v2/task/frameworks/fw/executors/pod/runs/container-db.1/tasks/db.1/stderr?limit=100" {
+		t.Fatalf("unexpected pod task queries %+v", queries)
+	}
+
+	if queries := searchQueries(cfg, state, map[string]string{"taskID": "cache"}, url.Values{}); len(queries) != 0 {
+		t.Fatalf("expect no queries for unknown task. Got %+v", queries)
+	}
+}
+
+func TestStreamQueries(t *testing.T) {
+	cfg := &config.Config{FlagAgentLogsScheme: "http", FlagAgentLogsPort: 61001}
+	task := func(id, name, state string) cluster.Task {
+		return cluster.Task{Task: nodeutil.Task{ID: id, Name: name, FrameworkID: "fw", SlaveID: "a1", State: state,
+			Statuses: []nodeutil.Status{{ContainerStatus: nodeutil.ContainerStatus{
+				ContainerID: nodeutil.NestedValue{Value: "container-" + id}}}}}}
+	}
+	state := &cluster.State{
+		Slaves: []nodeutil.Slave{{ID: "a1", Hostname: "10.0.0.1"}},
+		Frameworks: []cluster.Framework{
+			{ID: "fw", Name: "marathon", Tasks: []cluster.Task{
+				task("web.1", "web", "TASK_RUNNING"), task("web.2", "web", "TASK_STAGING"),
+				task("webhook.1", "webhook", "TASK_RUNNING"), task("db.1", "db", "TASK_RUNNING"),
+			}},
+			{ID: "other", Name: "other", Tasks: []cluster.Task{task("web.3", "web", "TASK_RUNNING")}},
+		},
+	}
+
+	queries := streamQueries(cfg, state, map[string]string{"framework": "marathon", "taskPrefix": "web"},
+		url.Values{"skip": {"-10"}, "filter": {"level:error"}})
+	if len(queries) != 2 || queries[0].Task != "web.1" || queries[1].Task != "webhook.1" {
+		t.Fatalf("expect the running web tasks of marathon. Got %+v", queries)
+	}
+
+	if queries[0].URL != "http://10.0.0.1:61001/system/v1/logs/v2/task/frameworks/fw/executors/web.1/runs/container-web.1/stdout?filter=level%3Aerror&skip=-10" ||
+		queries[0].ResumeURL != "http://10.0.0.1:61001/system/v1/logs/v2/task/frameworks/fw/executors/web.1/runs/container-web.1/stdout?filter=level%3Aerror" {
+		t.Fatalf("unexpected stream URLs %+v", queries[0])
+	}
+}
+
+func TestWriteAgentUnavailableEvent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := writeAgentUnavailableEvent(buf, "42"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "event: error\nid: 42\nretry: 10000\n" +
+		`data: {"error":"mesos agent is unavailable","cursor":"42","retry_after":10}` + "\n\n"
+	if buf.String() != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf.String())
+	}
+}
+
+func TestLabelEvent(t *testing.T) {
+	e := cluster.Event{Query: cluster.Query{Task: "web.1"}, Data: `{"fields":{"MESSAGE":"hello"},"realtime_timestamp":10}`}
+	if data := labelEvent(e); data != `{"fields":{"MESSAGE":"hello","TASK_ID":"web.1"},"realtime_timestamp":10}` {
+		t.Fatalf("unexpected labeled event %s", data)
+	}
+
+	e.Data = "plain text"
+	if data := labelEvent(e); data != "plain text" {
+		t.Fatalf("expect plain text unchanged. Got %s", data)
+	}
+}
+
+func TestFindTasks(t *testing.T) {
+	task := func(id, name string) cluster.Task {
+		return cluster.Task{Task: nodeutil.Task{ID: id, Name: name}}
+	}
+	state := &cluster.State{
+		Frameworks: []cluster.Framework{{
+			Tasks:          []cluster.Task{task("web.2", "web"), task("api.1", "api")},
+			CompletedTasks: []cluster.Task{task("web.1", "web"), task("db.1", "db")},
+		}},
+		CompletedFrameworks: []cluster.Framework{{Tasks: []cluster.Task{task("db.2", "db")}}},
+	}
+
+	for _, tc := range []struct {
+		name, completed string
+		expected        []string
+	}{
+		{"web", completedAny, []string{"web.2"}},
+		{"web", "true", []string{"web.1"}},
+		{"web", "false", []string{"web.2"}},
+		{"web.1", completedAny, []string{"web.1"}},
+		{"web.1", "false", nil},
+		{"db", completedAny, []string{"db.1", "db.2"}},
+		{"db", "false", nil},
+		{"cache", completedAny, nil},
+	} {
+		var found []string
+		for _, t := range findTasks(state, tc.name, tc.completed) {
+			found = append(found, t.ID)
+		}
+
+		if fmt.Sprint(found) != fmt.Sprint(tc.expected) {
+			t.Fatalf("%s completed=%s: expect %v. Got %v", tc.name, tc.completed, tc.expected, found)
+		}
+	}
+}
+
+func TestParseCompleted(t *testing.T) {
+	for param, expected := range map[string]string{"": "any", "any": "any", "true": "true", "false": "false"} {
+		if completed, err := parseCompleted(param); err != nil || completed != expected {
+			t.Fatalf("%s: expect %s. Got %s, %v", param, expected, completed, err)
+		}
+	}
+
+	if _, err := parseCompleted("yes"); err == nil {
+		t.Fatal("expect invalid completed parameter")
+	}
+}
+
+func TestSandboxTaskID(t *testing.T) {
+	run := sandbox.Run{AgentID: "S1", FrameworkID: "F1", ExecutorID: "web.1", ContainerID: "C1"}
+	id := sandboxTaskID("S2", run)
+	if taskLogPath(id) != "/v2/task/frameworks/F1/executors/web.1/runs/C1" || id.AgentID != "S2" {
+		t.Fatalf("unexpected task log path %s on %s", taskLogPath(id), id.AgentID)
+	}
+
+	run.ExecutorID, run.TaskPath = "instance-pod", "pod.web"
+	id = sandboxTaskID("S2", run)
+	if taskLogPath(id) != "/v2/task/frameworks/F1/executors/instance-pod/runs/C1/tasks/pod.web" {
+		t.Fatalf("unexpected pod task log path %s", taskLogPath(id))
+	}
+}
+
+func TestSandboxContainerID(t *testing.T) {
+	id := &nodeutil.CanonicalTaskID{ID: "pod.web", FrameworkID: "F1", ExecutorID: "instance-pod",
+		ContainerIDs: []string{"C2", "C1"}}
+
+	// the listed container must be the one of the task log path.
+	if containerID := sandboxContainerID(id); containerID != "C1" ||
+		!strings.Contains(taskLogPath(id), "/runs/"+containerID+"/") {
+		t.Fatalf("expect container C1 in %s. Got %s", taskLogPath(id), containerID)
+	}
+}
+
+func TestSandboxesHandlerForbidden(t *testing.T) {
+	cfg := &config.Config{FlagLocalSandboxPrincipals: []string{"bootstrapuser"}}
+
+	// the principal of an unverified token is not trusted.
+	req := httptest.NewRequest("GET", "/sandboxes/web.1", nil)
+	req.Header.Set("Authorization", "token=eyJhbGciOiJub25lIn0.eyJ1aWQiOiJib290c3RyYXB1c2VyIn0.")
+
+	w := httptest.NewRecorder()
+	middleware.Wrapped(http.HandlerFunc(sandboxesHandler), cfg, &http.Client{}, nil).ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expect 403. Got %d", w.Code)
+	}
+}
+
+func TestComponentsCache(t *testing.T) {
+	var reads []string
+	cache := newComponentsCache(func(ctx context.Context, window time.Duration, include func(string) bool) ([]jr.Component,
+		error) {
+		reads = append(reads, fmt.Sprintf("%s %t", window, include("sshd.service")))
+		return []jr.Component{{Name: "dcos-log.service"}}, nil
+	})
+
+	for _, all := range []bool{false, false, true} {
+		list, err := cache.get(context.Background(), time.Hour, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(list) != 1 || list[0].Name != "dcos-log.service" {
+			t.Fatalf("expect dcos-log.service. Got %+v", list)
+		}
+	}
+
+	// the second request is served from the cache.
+	if fmt.Sprint(reads) != "[1h0m0s false 1h0m0s true]" {
+		t.Fatalf("expect two reads. Got %v", reads)
+	}
+}
+
+func TestComponentsCacheWaiters(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	reads := map[time.Duration]int{}
+	cache := newComponentsCache(func(ctx context.Context, window time.Duration, include func(string) bool) ([]jr.Component,
+		error) {
+		mu.Lock()
+		reads[window]++
+		n := reads[window]
+		mu.Unlock()
+
+		switch {
+		case window == time.Hour:
+			<-release
+		case window == 2*time.Hour && n == 1:
+			return nil, errors.New("journal is not available")
+		}
+		return []jr.Component{{Name: "dcos-log.service"}}, ctx.Err()
+	})
+
+	// the client which started the read goes away.
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		_, err := cache.get(ctx, time.Hour, false)
+		errs <- err
+	}()
+	go func() {
+		_, err := cache.get(context.Background(), time.Hour, false)
+		errs <- err
+	}()
+
+	// the other windows are not blocked by the running read, the errors are not cached.
+	if _, err := cache.get(context.Background(), 2*time.Hour, false); err == nil {
+		t.Fatal("expect the read error")
+	}
+	if list, err := cache.get(context.Background(), 2*time.Hour, false); err != nil || len(list) != 1 {
+		t.Fatalf("expect dcos-log.service. Got %v, %v", list, err)
+	}
+
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("expect %v. Got %v", context.Canceled, err)
+	}
+
+	close(release)
+	if err := <-errs; err != nil {
+		t.Fatalf("expect the waiting request to get the read result. Got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if reads[time.Hour] != 1 || reads[2*time.Hour] != 2 {
+		t.Fatalf("expect one read of 1h and two of 2h. Got %v", reads)
+	}
+}
+
+func TestComponentsParams(t *testing.T) {
+	for query, expected := range map[string]string{
+		"":                    "1h0m0s false",
+		"?window=15m":         "15m0s false",
+		"?window=6h&all=true": "6h0m0s true",
+		"?window=7h":          "",
+		"?window=-1m":         "",
+		"?window=1d":          "",
+		"?all=maybe":          "",
+	} {
+		req, err := http.NewRequest("GET", "/"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		window, all, err := componentsParams(req)
+		if (expected == "") != (err != nil) {
+			t.Fatalf("%s: expect valid %t. Got %v", query, expected != "", err)
+		}
+
+		if actual := fmt.Sprintf("%s %t", window, all); expected != "" && actual != expected {
+			t.Fatalf("%s: expect %s. Got %s", query, expected, actual)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"path"
 
+	"github.com/dcos/dcos-go/dcos"
 	"github.com/dcos/dcos-go/dcos/nodeutil"
 	"github.com/dcos/dcos-log/dcos-log/api/middleware"
 	"github.com/dcos/dcos-log/dcos-log/config"
@@ -11,12 +12,18 @@ import (
 )
 
 const (
-	taskPath       = "/task/frameworks/{frameworkID}/executors/{executorID}/runs/{containerID}"
-	taskBrowsePath = taskPath + "/files/browse"
-	podPath        = taskPath + "/tasks/{taskPath}"
-	podBrowsePath  = podPath + "/files/browse"
-	discoverPath   = "/task/{taskID}"
-	componentPath  = "/component"
+	taskPath        = "/task/frameworks/{frameworkID}/executors/{executorID}/runs/{containerID}"
+	taskBrowsePath  = taskPath + "/files/browse"
+	podPath         = taskPath + "/tasks/{taskPath}"
+	podBrowsePath   = podPath + "/files/browse"
+	taskArchivePath = taskPath + "/files/archive"
+	podArchivePath  = podPath + "/files/archive"
+	discoverPath    = "/task/{taskID}"
+	componentPath   = "/component"
+	componentsPath  = "/components"
+	searchPath      = "/search"
+	streamPath      = "/stream/{framework}/{taskPrefix}"
+	sandboxesPath   = "/sandboxes/{taskID}"
 )
 
 // InitRoutes inits the v1 logging routes
@@ -26,6 +33,11 @@ func InitRoutes(v2 *mux.Router, cfg *config.Config, client *http.Client, nodeInf
 	v2.Path(taskBrowsePath).Handler(wrappedBrowseFiles).Methods("GET")
 	v2.Path(podBrowsePath).Handler(wrappedBrowseFiles).Methods("GET")
 
+	// download sandbox directories as archives
+	wrappedArchiveFiles := middleware.Wrapped(http.HandlerFunc(archiveFiles), cfg, client, nodeInfo)
+	v2.Path(taskArchivePath).Handler(wrappedArchiveFiles).Methods("GET")
+	v2.Path(podArchivePath).Handler(wrappedArchiveFiles).Methods("GET")
+
 	// task logs
 	wrappedTaskLogHandler := middleware.Wrapped(http.HandlerFunc(filesAPIHandler), cfg, client, nodeInfo)
 	v2.Path(path.Join(taskPath, "/{file}")).Handler(wrappedTaskLogHandler).Methods("GET")
@@ -51,8 +63,32 @@ func InitRoutes(v2 *mux.Router, cfg *config.Config, client *http.Client, nodeInf
 	v2.Path(componentPath).Handler(wrappedComponentHandler).Methods("GET")
 	v2.Path(path.Join(componentPath, "/{name}")).Handler(wrappedComponentHandler).Methods("GET")
 
+	// components with log activity
+	wrappedComponentsHandler := middleware.Wrapped(http.HandlerFunc(componentsHandler), cfg, client, nodeInfo)
+	v2.Path(componentsPath).Handler(wrappedComponentsHandler).Methods("GET")
+
 	// download path
 	wrappedDownloadHandler := middleware.Wrapped(http.HandlerFunc(downloadFile), cfg, client, nodeInfo)
 	v2.Path(path.Join(taskPath, "/{file}/download")).Handler(wrappedDownloadHandler).Methods("GET")
 	v2.Path(path.Join(podPath, "/{file}/download")).Handler(wrappedDownloadHandler).Methods("GET")
+
+	// the task runs found in the sandboxes on the agent
+	if cfg.FlagRole != dcos.RoleMaster {
+		wrappedSandboxesHandler := middleware.Wrapped(http.HandlerFunc(sandboxesHandler), cfg, client, nodeInfo)
+		v2.Path(sandboxesPath).Handler(wrappedSandboxesHandler).Methods("GET")
+	}
+
+	// cluster search and streams, the masters query the agents.
+	if cfg.FlagRole == dcos.RoleMaster {
+		wrappedSearchHandler := middleware.Wrapped(http.HandlerFunc(searchHandler), cfg, client, nodeInfo)
+		v2.Path(path.Join(searchPath, componentPath)).Handler(wrappedSearchHandler).Methods("GET")
+		v2.Path(path.Join(searchPath, componentPath, "/{name}")).Handler(wrappedSearchHandler).Methods("GET")
+		v2.Path(path.Join(searchPath, "/task/{taskID}")).Handler(wrappedSearchHandler).Methods("GET")
+		v2.Path(path.Join(searchPath, "/task/{taskID}/file/{file}")).Handler(wrappedSearchHandler).Methods("GET")
+
+		// merged live stream of the task instances
+		wrappedStreamHandler := middleware.Wrapped(http.HandlerFunc(streamHandler), cfg, client, nodeInfo)
+		v2.Path(streamPath).Handler(wrappedStreamHandler).Methods("GET")
+		v2.Path(path.Join(streamPath, "/file/{file}")).Handler(wrappedStreamHandler).Methods("GET")
+	}
 }
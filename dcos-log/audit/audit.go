@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-systemd/journal"
+)
+
+// SyslogIdentifier is a SYSLOG_IDENTIFIER used for audit records written to the journal.
+const SyslogIdentifier = "dcos-log-audit"
+
+// Record describes a single access to the logs served by dcos-log. Principal is the uid of a verified
+// authentication token, ClaimedPrincipal the uid claim of a token which could not be verified.
+type Record struct {
+	Time             time.Time     `json:"time"`
+	RequestID        string        `json:"request_id,omitempty"`
+	Principal        string        `json:"principal"`
+	ClaimedPrincipal string        `json:"claimed_principal,omitempty"`
+	RemoteAddr       string        `json:"remote_addr"`
+	Method           string        `json:"method"`
+	Endpoint         string        `json:"endpoint"`
+	Route            string        `json:"route"`
+	Query            string        `json:"query,omitempty"`
+	Filters          []string      `json:"filters,omitempty"`
+	Resource         Resource      `json:"resource"`
+	Bytes            int64         `json:"bytes"`
+	Duration         time.Duration `json:"duration_ns"`
+	Code             int           `json:"code"`
+}
+
+// Resource identifies the logs that were accessed. Task logs are identified by framework, executor
+// and container IDs, component logs by a systemd unit name.
+type Resource struct {
+	FrameworkID string `json:"framework_id,omitempty"`
+	ExecutorID  string `json:"executor_id,omitempty"`
+	ContainerID string `json:"container_id,omitempty"`
+	TaskPath    string `json:"task_path,omitempty"`
+	TaskID      string `json:"task_id,omitempty"`
+	File        string `json:"file,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+}
+
+// String returns a human readable description of the resource.
+func (r Resource) String() string {
+	var parts []string
+	for _, field := range []struct{ name, value string }{
+		{"framework", r.FrameworkID},
+		{"executor", r.ExecutorID},
+		{"container", r.ContainerID},
+		{"task_path", r.TaskPath},
+		{"task", r.TaskID},
+		{"file", r.File},
+		{"unit", r.Unit},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.name+"="+field.value)
+		}
+	}
+
+	if len(parts) == 0 {
+		return "journal"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Logger is an interface used to persist audit records.
+type Logger interface {
+	Log(Record) error
+	Close() error
+}
+
+// NewJournalLogger returns a Logger which writes audit records to the local journal
+// with SYSLOG_IDENTIFIER=dcos-log-audit.
+func NewJournalLogger() (Logger, error) {
+	if !journal.Enabled() {
+		return nil, fmt.Errorf("journald socket is not available")
+	}
+	return journalLogger{}, nil
+}
+
+type journalLogger struct{}
+
+func (journalLogger) Log(r Record) error {
+	vars := map[string]string{
+		"SYSLOG_IDENTIFIER":       SyslogIdentifier,
+		"AUDIT_REQUEST_ID":        r.RequestID,
+		"AUDIT_PRINCIPAL":         r.Principal,
+		"AUDIT_CLAIMED_PRINCIPAL": r.ClaimedPrincipal,
+		"AUDIT_REMOTE_ADDR":       r.RemoteAddr,
+		"AUDIT_METHOD":            r.Method,
+		"AUDIT_ENDPOINT":          r.Endpoint,
+		"AUDIT_ROUTE":             r.Route,
+		"AUDIT_QUERY":             r.Query,
+		"AUDIT_FILTERS":           strings.Join(r.Filters, ","),
+		"AUDIT_FRAMEWORK_ID":      r.Resource.FrameworkID,
+		"AUDIT_EXECUTOR_ID":       r.Resource.ExecutorID,
+		"AUDIT_CONTAINER_ID":      r.Resource.ContainerID,
+		"AUDIT_TASK_PATH":         r.Resource.TaskPath,
+		"AUDIT_TASK_ID":           r.Resource.TaskID,
+		"AUDIT_FILE":              r.Resource.File,
+		"AUDIT_UNIT":              r.Resource.Unit,
+		"AUDIT_BYTES":             strconv.FormatInt(r.Bytes, 10),
+		"AUDIT_DURATION":          r.Duration.String(),
+		"AUDIT_CODE":              strconv.Itoa(r.Code),
+	}
+
+	// do not send empty fields
+	for k, v := range vars {
+		if v == "" {
+			delete(vars, k)
+		}
+	}
+
+	return journal.Send(r.String(), journal.PriInfo, vars)
+}
+
+func (journalLogger) Close() error {
+	return nil
+}
+
+// String returns a one line summary of the record.
+func (r Record) String() string {
+	principal := r.Principal
+	if principal == "" {
+		principal = "anonymous"
+		if r.ClaimedPrincipal != "" {
+			principal += " (unverified " + r.ClaimedPrincipal + ")"
+		}
+	}
+
+	return fmt.Sprintf("%s from %s: %s %s [%s] code=%d bytes=%d duration=%s", principal, r.RemoteAddr, r.Method,
+		r.Endpoint, r.Resource, r.Code, r.Bytes, r.Duration)
+}
+
+// NewFileLogger returns a Logger which writes audit records as JSON lines to a file. The file is rotated
+// when it reaches maxSize bytes, keeping at most maxBackups rotated files.
+func NewFileLogger(path string, maxSize int64, maxBackups int) (Logger, error) {
+	w, err := newRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileLogger{w: w}, nil
+}
+
+type fileLogger struct {
+	sync.Mutex
+	w *rotatingFile
+}
+
+func (f *fileLogger) Log(r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	_, err = f.w.Write(append(b, '\n'))
+	return err
+}
+
+func (f *fileLogger) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.w.Close()
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcos-log-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	logger, err := NewFileLogger(path, 1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := Record{
+		Time:       time.Now(),
+		Principal:  "bootstrapuser",
+		RemoteAddr: "127.0.0.1:1234",
+		Method:     "GET",
+		Endpoint:   "/v1/range/",
+		Filters:    []string{"STREAM:STDOUT"},
+		Resource:   Resource{FrameworkID: "f", ExecutorID: "e", ContainerID: "c"},
+		Bytes:      100,
+		Duration:   time.Second,
+		Code:       200,
+	}
+
+	if err := logger.Log(record); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("expect 1 record. Got %d", len(records))
+	}
+
+	r := records[0]
+	if r.Principal != record.Principal || r.Resource != record.Resource || r.Bytes != record.Bytes ||
+		r.Code != record.Code || r.Duration != record.Duration {
+		t.Fatalf("expect %+v. Got %+v", record, r)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcos-log-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	w, err := newRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		path:        "four\nfive\n",
+		path + ".1": "three\n",
+		path + ".2": "one\ntwo\n",
+	} {
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(content) != expected {
+			t.Fatalf("expect %s to contain %q. Got %q", name, expected, content)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expect only 2 backups. Got %s", err)
+	}
+}
+
+func TestRecordString(t *testing.T) {
+	r := Record{
+		RemoteAddr: "127.0.0.1:1234",
+		Method:     "GET",
+		Endpoint:   "/v2/component/dcos-mesos-master",
+		Resource:   Resource{Unit: "dcos-mesos-master"},
+		Code:       200,
+	}
+
+	s := r.String()
+	for _, expected := range []string{"anonymous", "unit=dcos-mesos-master", "code=200"} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("expect %q in %q", expected, s)
+		}
+	}
+}
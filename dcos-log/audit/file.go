@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// rotatingFile is an io.WriteCloser which rotates the underlying file once it reaches maxSize.
+// Rotated files are renamed to <path>.1, <path>.2 ... <path>.<maxBackups>, the oldest file is removed.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if path == "" {
+		return nil, errors.New("audit file path cannot be empty")
+	}
+
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid max file size %d. Must be positive integer", maxSize)
+	}
+
+	if maxBackups < 0 {
+		return nil, fmt.Errorf("invalid number of backups %d. Must be zero or positive integer", maxBackups)
+	}
+
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) backupName(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+
+	// shift the backups, the oldest one is overwritten.
+	for i := r.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(r.backupName(i), r.backupName(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := os.Rename(r.path, r.backupName(1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return r.open()
+}
+
+// Write implements io.Writer. The file is rotated before the write if the write would exceed maxSize.
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	if r.size > 0 && r.size+int64(len(b)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, fmt.Errorf("unable to rotate %s: %s", r.path, err)
+		}
+	}
+
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the underlying file.
+func (r *rotatingFile) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,290 @@
+// Package cluster fans out the log queries from a master to dcos-log on the agents and merges the results.
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcos/dcos-go/dcos/nodeutil"
+)
+
+const (
+	// DefaultConcurrency is a number of nodes queried at the same time.
+	DefaultConcurrency = 10
+
+	// maxEntryLine is a maximum size of a JSON entry returned by a node.
+	maxEntryLine = 8 << 20
+
+	// maxErrorBody is a maximum size of the error response of a node reported to the client.
+	maxErrorBody = 1024
+)
+
+// State is the mesos state of the leading master.
+type State struct {
+	Slaves              []nodeutil.Slave `json:"slaves"`
+	Frameworks          []Framework      `json:"frameworks"`
+	CompletedFrameworks []Framework      `json:"completed_frameworks"`
+}
+
+// Framework is a framework in mesos state.
+type Framework struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Tasks          []Task `json:"tasks"`
+	CompletedTasks []Task `json:"completed_tasks"`
+}
+
+// Task is a task in mesos state. Unlike nodeutil.Task, it has the time the task started.
+type Task struct {
+	nodeutil.Task
+
+	// StartTime is the time of the TASK_RUNNING status update, zero if the task never ran.
+	StartTime time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Task) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &t.Task); err != nil {
+		return err
+	}
+
+	var task struct {
+		Statuses []struct {
+			State     string  `json:"state"`
+			Timestamp float64 `json:"timestamp"`
+		} `json:"statuses"`
+	}
+	if err := json.Unmarshal(b, &task); err != nil {
+		return err
+	}
+
+	for _, status := range task.Statuses {
+		if status.State == "TASK_RUNNING" {
+			sec := int64(status.Timestamp)
+			t.StartTime = time.Unix(sec, int64((status.Timestamp-float64(sec))*1e9))
+			break
+		}
+	}
+	return nil
+}
+
+// GetState returns the mesos state of the leading master. nodeutil.NodeInfo looks up a single task in the state,
+// but does not expose the agents.
+func GetState(ctx context.Context, client *http.Client, stateURL string, header http.Header) (*State, error) {
+	req, err := http.NewRequest("GET", stateURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get mesos state: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to get mesos state: bad status %d. URL %s", resp.StatusCode, stateURL)
+	}
+
+	state := &State{}
+	if err := json.NewDecoder(resp.Body).Decode(state); err != nil {
+		return nil, fmt.Errorf("unable to decode mesos state: %s", err)
+	}
+	return state, nil
+}
+
+// Query is a log query sent to dcos-log on a node.
+type Query struct {
+	Node     string
+	Hostname string
+
+	// Task is set for the task log queries.
+	Task string
+
+	URL string
+
+	// ResumeURL is used by Multiplexer to reopen a task stream, it has no initial position parameters.
+	ResumeURL string
+}
+
+// Entry is a log entry returned by a node, with the node it was read on.
+type Entry struct {
+	Node     string `json:"node"`
+	Hostname string `json:"hostname"`
+	Task     string `json:"task,omitempty"`
+
+	Fields            map[string]interface{} `json:"fields"`
+	Cursor            string                 `json:"cursor,omitempty"`
+	RealtimeTimestamp uint64                 `json:"realtime_timestamp,omitempty"`
+}
+
+// NodeStatus is the outcome of a query. A node which failed has Error set, the entries of the other nodes
+// are still returned.
+type NodeStatus struct {
+	Node     string `json:"node"`
+	Hostname string `json:"hostname"`
+	Task     string `json:"task,omitempty"`
+	Entries  int    `json:"entries"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Result is a merged result of the queries.
+type Result struct {
+	Entries []Entry      `json:"entries"`
+	Nodes   []NodeStatus `json:"nodes"`
+}
+
+// Searcher sends the queries to the nodes.
+type Searcher struct {
+	Client *http.Client
+	Header http.Header
+
+	// Concurrency is a number of nodes queried at the same time, DefaultConcurrency if not set.
+	Concurrency int
+
+	// Timeout is a timeout of a query to a single node.
+	Timeout time.Duration
+}
+
+// Search sends the queries in parallel and merges the entries by their realtime timestamp. The entries without
+// a timestamp keep their place relative to the entries of the same node read before them.
+func (s *Searcher) Search(ctx context.Context, queries []Query) *Result {
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	entries := make([][]Entry, len(queries))
+	result := &Result{Nodes: make([]NodeStatus, len(queries))}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q Query) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			var err error
+			entries[i], err = s.query(ctx, q)
+
+			result.Nodes[i] = NodeStatus{
+				Node:     q.Node,
+				Hostname: q.Hostname,
+				Task:     q.Task,
+				Entries:  len(entries[i]),
+				Duration: time.Since(start).String(),
+			}
+			if err != nil {
+				result.Nodes[i].Error = err.Error()
+			}
+		}(i, q)
+	}
+	wg.Wait()
+
+	result.Entries = merge(entries)
+	return result
+}
+
+// query reads the entries returned by a node. If the node fails in the middle of the response, the entries read
+// so far are returned with the error.
+func (s *Searcher) query(ctx context.Context, q Query) ([]Entry, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest("GET", q.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	for k, v := range s.Header {
+		header[k] = v
+	}
+	header.Set("Accept", "application/json")
+	req.Header = header
+
+	resp, err := s.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// the node has no entries matching the query.
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, fmt.Errorf("bad status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEntryLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return entries, fmt.Errorf("unable to decode entry: %s", err)
+		}
+
+		// the node attribution must not be overridden by the entry.
+		entry.Node, entry.Hostname, entry.Task = q.Node, q.Hostname, q.Task
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// merge merges the entries of the nodes, sorted by the realtime timestamp.
+func merge(nodes [][]Entry) []Entry {
+	type keyed struct {
+		key   uint64
+		entry Entry
+	}
+
+	var all []keyed
+	for _, entries := range nodes {
+		var last uint64
+		for _, entry := range entries {
+			// an entry without a timestamp follows the previous entry of the node.
+			if entry.RealtimeTimestamp != 0 {
+				last = entry.RealtimeTimestamp
+			}
+			all = append(all, keyed{key: last, entry: entry})
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].key < all[j].key
+	})
+
+	merged := make([]Entry, len(all))
+	for i := range all {
+		merged[i] = all[i].entry
+	}
+	return merged
+}
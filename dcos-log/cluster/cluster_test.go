@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newAgent(t *testing.T, handler http.HandlerFunc) string {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestSearch(t *testing.T) {
+	journal := newAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" || r.Header.Get("Authorization") != "token" {
+			t.Fatalf("unexpected headers %v", r.Header)
+		}
+		io.WriteString(w, `{"fields":{"MESSAGE":"a1"},"cursor":"c1","realtime_timestamp":100}`+"\n"+
+			`{"fields":{"MESSAGE":"a3"},"cursor":"c3","realtime_timestamp":300}`+"\n")
+	})
+
+	// the second line has no timestamp, it must follow the first one.
+	task := newAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"fields":{"MESSAGE":"b2"},"realtime_timestamp":200}`+"\n"+
+			`{"fields":{"MESSAGE":"b2 continued"}}`+"\n"+
+			`{"fields":{"MESSAGE":"b4"},"realtime_timestamp":400}`+"\n")
+	})
+
+	failing := newAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "agent is broken", http.StatusInternalServerError)
+	})
+
+	slow := newAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	header := http.Header{}
+	header.Set("Authorization", "token")
+	s := &Searcher{Client: &http.Client{}, Header: header, Concurrency: 2, Timeout: 100 * time.Millisecond}
+
+	result := s.Search(context.Background(), []Query{
+		{Node: "a", Hostname: "host-a", URL: journal},
+		{Node: "b", Hostname: "host-b", Task: "task-b", URL: task},
+		{Node: "c", Hostname: "host-c", URL: failing},
+		{Node: "d", Hostname: "host-d", URL: slow},
+	})
+
+	var messages, nodes []string
+	for _, entry := range result.Entries {
+		messages = append(messages, entry.Fields["MESSAGE"].(string))
+		nodes = append(nodes, entry.Node)
+	}
+
+	expectedMessages := []string{"a1", "b2", "b2 continued", "a3", "b4"}
+	expectedNodes := []string{"a", "b", "b", "a", "b"}
+	for i := range expectedMessages {
+		if len(messages) != len(expectedMessages) || messages[i] != expectedMessages[i] || nodes[i] != expectedNodes[i] {
+			t.Fatalf("expect %v on %v. Got %v on %v", expectedMessages, expectedNodes, messages, nodes)
+		}
+	}
+
+	if result.Entries[1].Task != "task-b" || result.Entries[1].Hostname != "host-b" {
+		t.Fatalf("expect the entry attributed to task-b on host-b. Got %+v", result.Entries[1])
+	}
+
+	if result.Nodes[0].Entries != 2 || result.Nodes[0].Error != "" || result.Nodes[1].Entries != 3 {
+		t.Fatalf("unexpected node statuses %+v", result.Nodes)
+	}
+
+	if result.Nodes[2].Error == "" || result.Nodes[3].Error == "" {
+		t.Fatalf("expect the failing and slow nodes to report errors. Got %+v", result.Nodes)
+	}
+}
+
+func TestSearchNoContent(t *testing.T) {
+	// the node has no matching entries.
+	empty := newAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	s := &Searcher{Client: &http.Client{}}
+	result := s.Search(context.Background(), []Query{{Node: "a", URL: empty}})
+
+	if len(result.Entries) != 0 || len(result.Nodes) != 1 {
+		t.Fatalf("expect an empty result. Got %+v", result)
+	}
+
+	if result.Nodes[0].Error != "" || result.Nodes[0].Entries != 0 {
+		t.Fatalf("expect no error for 204. Got %+v", result.Nodes[0])
+	}
+}
+
+func TestSearchConcurrency(t *testing.T) {
+	var running, max int32
+	agent := newAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	var queries []Query
+	for i := 0; i < 10; i++ {
+		queries = append(queries, Query{Node: "a", URL: agent})
+	}
+
+	s := &Searcher{Client: &http.Client{}, Concurrency: 3}
+	result := s.Search(context.Background(), queries)
+
+	if max > 3 {
+		t.Fatalf("expect at most 3 queries at once. Got %d", max)
+	}
+
+	if len(result.Entries) != 0 || len(result.Nodes) != 10 {
+		t.Fatalf("expect 10 empty results. Got %d entries, %d nodes", len(result.Entries), len(result.Nodes))
+	}
+}
+
+func TestState(t *testing.T) {
+	stateURL := newAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"slaves":[{"id":"a","hostname":"host-a"}],"frameworks":[{"id":"fw","tasks":[{"id":"web.1",`+
+			`"slave_id":"a","state":"TASK_RUNNING","statuses":[{"state":"TASK_STARTING","timestamp":1500000000.5},`+
+			`{"state":"TASK_RUNNING","timestamp":1500000001.25,"container_status":{"container_id":{"value":"c1"}}}]}]}]}`)
+	})
+
+	state, err := GetState(context.Background(), &http.Client{}, stateURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(state.Slaves) != 1 || state.Slaves[0].Hostname != "host-a" {
+		t.Fatalf("unexpected agents %+v", state.Slaves)
+	}
+
+	task := state.Frameworks[0].Tasks[0]
+	if task.ID != "web.1" || task.SlaveID != "a" || task.StartTime.UnixNano() != 1500000001250000000 {
+		t.Fatalf("unexpected task %+v", task)
+	}
+}
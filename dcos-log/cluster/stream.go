@@ -0,0 +1,256 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRefreshInterval is an interval the tasks of a merged stream are looked up again.
+	DefaultRefreshInterval = 10 * time.Second
+
+	// upstreamMaxDuration limits a single upstream stream. The deadline also keeps the client transport timeout,
+	// meant for short requests, from closing the stream. An upstream which ended is reopened from its cursor.
+	upstreamMaxDuration = time.Hour
+)
+
+// ParseCursors parses the Last-Event-ID of a merged stream, the cursors of the task streams by task ID.
+func ParseCursors(lastEventID string) (map[string]string, error) {
+	values, err := url.ParseQuery(lastEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursors := make(map[string]string, len(values))
+	for task := range values {
+		cursors[task] = values.Get(task)
+	}
+	return cursors, nil
+}
+
+// FormatCursors formats the cursors of the task streams as the event ID of a merged stream.
+func FormatCursors(cursors map[string]string) string {
+	tasks := make([]string, 0, len(cursors))
+	for task := range cursors {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	values := make([]string, len(tasks))
+	for i, task := range tasks {
+		values[i] = url.QueryEscape(task) + "=" + url.QueryEscape(cursors[task])
+	}
+	return strings.Join(values, "&")
+}
+
+// Event is an event of a merged stream.
+type Event struct {
+	Query Query
+
+	// ID is the ID of the merged stream event, the cursors of all the tasks.
+	ID string
+
+	Data string
+}
+
+// Multiplexer merges the Server-Sent Events streams of the tasks running on several agents into one.
+type Multiplexer struct {
+	Client *http.Client
+	Header http.Header
+
+	// Lookup returns the tasks to stream, it's called every Refresh interval. The streams of the new tasks
+	// are opened, the streams of the tasks no longer returned are closed.
+	Lookup  func(ctx context.Context) ([]Query, error)
+	Refresh time.Duration
+
+	// Cursors are the cursors of the task streams to resume from, parsed from the client Last-Event-ID.
+	Cursors map[string]string
+}
+
+// upstream is an open task stream.
+type upstream struct {
+	query  Query
+	cancel context.CancelFunc
+}
+
+// upstreamEvent is an event read from a task stream.
+type upstreamEvent struct {
+	task string
+	id   string
+	data string
+}
+
+// Stream opens the streams of the tasks and returns the merged events. The initial tasks use Query URL, unless
+// the stream is resumed. The streams opened later, for the new tasks or to reconnect, use Query ResumeURL with
+// the task cursor. The channel is closed once ctx is done.
+func (m *Multiplexer) Stream(ctx context.Context, queries []Query) <-chan Event {
+	out := make(chan Event)
+	go m.run(ctx, queries, out)
+	return out
+}
+
+func (m *Multiplexer) run(ctx context.Context, queries []Query, out chan<- Event) {
+	defer close(out)
+
+	refresh := m.Refresh
+	if refresh <= 0 {
+		refresh = DefaultRefreshInterval
+	}
+
+	cursors := make(map[string]string, len(m.Cursors))
+	for task, cursor := range m.Cursors {
+		cursors[task] = cursor
+	}
+
+	var (
+		events  = make(chan upstreamEvent)
+		done    = make(chan *upstream)
+		active  = make(map[string]*upstream)
+		opened  = make(map[string]bool)
+		resumed = len(cursors) > 0
+	)
+
+	update := func(queries []Query) {
+		current := make(map[string]bool, len(queries))
+		for _, q := range queries {
+			current[q.Task] = true
+			if _, ok := active[q.Task]; ok {
+				continue
+			}
+
+			streamURL := q.URL
+			if resumed || opened[q.Task] {
+				streamURL = q.ResumeURL
+			}
+			opened[q.Task] = true
+
+			upCtx, cancel := context.WithTimeout(ctx, upstreamMaxDuration)
+			u := &upstream{query: q, cancel: cancel}
+			active[q.Task] = u
+
+			go func(q Query, cursor string) {
+				m.read(upCtx, q, streamURL, cursor, events)
+				cancel()
+				select {
+				case done <- u:
+				case <-ctx.Done():
+				}
+			}(q, cursors[q.Task])
+		}
+
+		// the tasks which are no longer running are not streamed.
+		for task, u := range active {
+			if !current[task] {
+				u.cancel()
+				delete(active, task)
+				delete(cursors, task)
+			}
+		}
+	}
+
+	update(queries)
+
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			queries, err := m.Lookup(ctx)
+			if err != nil {
+				logrus.Errorf("unable to look up the streamed tasks: %s", err)
+				continue
+			}
+			update(queries)
+		case u := <-done:
+			// the stream ended, it's reopened with the next lookup if the task is still running.
+			if active[u.query.Task] == u {
+				delete(active, u.query.Task)
+			}
+		case e := <-events:
+			u, ok := active[e.task]
+			if !ok {
+				continue
+			}
+
+			if e.id != "" {
+				cursors[e.task] = e.id
+			}
+
+			select {
+			case out <- Event{Query: u.query, ID: FormatCursors(cursors), Data: e.data}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// read reads the events of a task stream until it ends or ctx is done.
+func (m *Multiplexer) read(ctx context.Context, q Query, streamURL, cursor string, events chan<- upstreamEvent) {
+	req, err := http.NewRequest("GET", streamURL, nil)
+	if err != nil {
+		logrus.Errorf("unable to stream task %s: %s", q.Task, err)
+		return
+	}
+
+	header := http.Header{}
+	for k, v := range m.Header {
+		header[k] = v
+	}
+	header.Set("Accept", "text/event-stream")
+	if cursor != "" {
+		header.Set("Last-Event-ID", cursor)
+	}
+	req.Header = header
+
+	resp, err := m.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() == nil {
+			logrus.Warnf("unable to stream task %s from %s: %s", q.Task, q.Hostname, err)
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logrus.Warnf("unable to stream task %s from %s: bad status %d", q.Task, q.Hostname, resp.StatusCode)
+		return
+	}
+
+	var id, data string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEntryLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data == "" {
+				continue
+			}
+
+			select {
+			case events <- upstreamEvent{task: q.Task, id: id, data: data}:
+			case <-ctx.Done():
+				return
+			}
+			id, data = "", ""
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			if data != "" {
+				data += "\n"
+			}
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+}
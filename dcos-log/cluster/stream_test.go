@@ -0,0 +1,116 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCursors(t *testing.T) {
+	cursors := map[string]string{"web.1": "10", "web&2=": "20"}
+
+	id := FormatCursors(cursors)
+	if strings.ContainsAny(id, "\n ") {
+		t.Fatalf("invalid event ID %q", id)
+	}
+
+	parsed, err := ParseCursors(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed) != 2 || parsed["web.1"] != "10" || parsed["web&2="] != "20" {
+		t.Fatalf("expect %v. Got %v", cursors, parsed)
+	}
+}
+
+// newSSEAgent returns a fake dcos-log streaming the events of a task. The Last-Event-ID of the requests
+// are recorded.
+func newSSEAgent(t *testing.T, task string, lastEventIDs *sync.Map) string {
+	return newAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Fatalf("unexpected Accept header %s", r.Header.Get("Accept"))
+		}
+		lastEventIDs.Store(task+" "+r.URL.RawQuery, r.Header.Get("Last-Event-ID"))
+
+		fmt.Fprintf(w, ": ping\n\nid: 5\ndata: {\"fields\":{\"MESSAGE\":\"%s\"}}\n\n", task)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+}
+
+func TestMultiplexer(t *testing.T) {
+	var lastEventIDs sync.Map
+	web1 := newSSEAgent(t, "web.1", &lastEventIDs)
+	web2 := newSSEAgent(t, "web.2", &lastEventIDs)
+
+	query := func(task, url string) Query {
+		return Query{Node: "agent-" + task, Task: task, URL: url + "?initial", ResumeURL: url + "?resume"}
+	}
+
+	// web.1 is replaced by web.2, like in a rolling deploy.
+	lookup := func(ctx context.Context) ([]Query, error) {
+		return []Query{query("web.2", web2)}, nil
+	}
+
+	m := &Multiplexer{
+		Client:  &http.Client{},
+		Lookup:  lookup,
+		Refresh: 50 * time.Millisecond,
+		Cursors: map[string]string{"web.1": "3"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := m.Stream(ctx, []Query{query("web.1", web1)})
+
+	e := <-events
+	if e.Query.Task != "web.1" || e.Data != `{"fields":{"MESSAGE":"web.1"}}` || e.ID != "web.1=5" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	e = <-events
+	if e.Query.Task != "web.2" || e.ID != "web.2=5" {
+		t.Fatalf("expect web.2 event without web.1 cursor. Got %+v", e)
+	}
+
+	// the stream was resumed, web.1 continues from its cursor, the new task web.2 from the beginning.
+	if id, _ := lastEventIDs.Load("web.1 resume"); id != "3" {
+		t.Fatalf("expect web.1 resumed from 3. Got %v", id)
+	}
+	if id, _ := lastEventIDs.Load("web.2 resume"); id != "" {
+		t.Fatalf("expect web.2 streamed from the beginning. Got %v", id)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestMultiplexerInitialURL(t *testing.T) {
+	var lastEventIDs sync.Map
+	web1 := newSSEAgent(t, "web.1", &lastEventIDs)
+
+	q := Query{Task: "web.1", URL: web1 + "?initial", ResumeURL: web1 + "?resume"}
+	m := &Multiplexer{
+		Client: &http.Client{},
+		Lookup: func(ctx context.Context) ([]Query, error) { return []Query{q}, nil },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := m.Stream(ctx, []Query{q})
+	if e := <-events; e.ID != "web.1=5" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	if _, ok := lastEventIDs.Load("web.1 initial"); !ok {
+		t.Fatal("expect the initial URL used")
+	}
+}
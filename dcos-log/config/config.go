@@ -1,19 +1,48 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dcos/dcos-go/dcos"
+	"github.com/dcos/dcos-log/dcos-log/redact"
 	"github.com/sirupsen/logrus"
 	"github.com/xeipuuv/gojsonschema"
 )
 
 const (
 	dcosLog                  = "dcos-log"
+	envPrefix                = "DCOS_LOG_"
 	defaultHTTPPort          = 8080
 	defaultGETRequestTimeout = "5s"
+	defaultShutdownTimeout   = "30s"
+	defaultMesosTimeout      = 10 * time.Second
+	defaultMesosChunkSize    = 1 << 16
+	defaultMesosRetries      = 3
+	defaultMesosMaxLineSize  = 4 << 20
+	defaultArchiveMaxSize    = 1024
+
+	// defaultAgentLogsPort is a port of admin router on the agents, proxying /system/v1/logs to dcos-log.
+	defaultAgentLogsPort     = 61001
+	defaultSearchConcurrency = 10
+	defaultSearchTimeout     = 10 * time.Second
+
+	defaultAuditFileMaxSize    = 100
+	defaultAuditFileMaxBackups = 5
+
+	// defaultSandboxRoot is the sandbox root of mesos agent started with the default --work_dir.
+	defaultSandboxRoot = "/var/lib/mesos/slave/slaves"
 )
 
 var internalJSONValidationSchema = `
@@ -38,18 +67,268 @@ var internalJSONValidationSchema = `
 	    "ca-cert": {
 	      "type": "string"
 	    },
+	    "auth-jwks-url": {
+	      "type": "string",
+	      "pattern": "^https?://"
+	    },
+	    "tls-cert": {
+	      "type": "string",
+	      "minLength": 1
+	    },
+	    "tls-key": {
+	      "type": "string",
+	      "minLength": 1
+	    },
+	    "tls-client-ca": {
+	      "type": "string",
+	      "minLength": 1
+	    },
 	    "timeout": {
 	      "type": "string"
 	    },
+	    "mesos-scheme": {
+	      "type": "string",
+	      "enum": ["http", "https"]
+	    },
+	    "mesos-agent-port": {
+	      "type": "integer",
+	      "minimum": 1,
+	      "maximum": 65535
+	    },
+	    "mesos-master-port": {
+	      "type": "integer",
+	      "minimum": 1,
+	      "maximum": 65535
+	    },
+	    "mesos-state-url": {
+	      "type": "string",
+	      "pattern": "^https?://"
+	    },
+	    "sandbox-root": {
+	      "type": "string",
+	      "pattern": "^/"
+	    },
+	    "shutdown-timeout": {
+	      "type": "string"
+	    },
+	    "mesos-timeout": {
+	      "type": "string"
+	    },
+	    "mesos-chunk-size": {
+	      "type": "integer",
+	      "minimum": 1024
+	    },
+	    "mesos-max-line-size": {
+	      "type": "integer",
+	      "minimum": 1024
+	    },
+	    "mesos-retries": {
+	      "type": "integer",
+	      "minimum": 0
+	    },
+	    "archive-max-size": {
+	      "type": "integer",
+	      "minimum": 0
+	    },
+	    "agent-logs-scheme": {
+	      "type": "string",
+	      "enum": ["http", "https"]
+	    },
+	    "agent-logs-port": {
+	      "type": "integer",
+	      "minimum": 1,
+	      "maximum": 65535
+	    },
+	    "search-concurrency": {
+	      "type": "integer",
+	      "minimum": 1
+	    },
+	    "search-timeout": {
+	      "type": "string"
+	    },
 	    "role": {
 	      "type": "string",
 	      "enum": ["master", "agent", "agent_public"]
+	    },
+	    "audit": {
+	      "type": "boolean"
+	    },
+	    "audit-file": {
+	      "type": "string"
+	    },
+	    "audit-file-max-size": {
+	      "type": "integer",
+	      "minimum": 1
+	    },
+	    "audit-file-max-backups": {
+	      "type": "integer",
+	      "minimum": 0
+	    },
+	    "max-streams": {
+	      "type": "integer",
+	      "minimum": 0
+	    },
+	    "max-streams-per-client": {
+	      "type": "integer",
+	      "minimum": 0
+	    },
+	    "rate-limit": {
+	      "type": "number",
+	      "minimum": 0
+	    },
+	    "rate-limit-burst": {
+	      "type": "integer",
+	      "minimum": 0
+	    },
+	    "stream-rate-limit": {
+	      "type": "integer",
+	      "minimum": 0
+	    },
+	    "redact": {
+	      "type": "array",
+	      "items": {
+	        "type": "object",
+	        "properties": {
+	          "name": {
+	            "type": "string"
+	          },
+	          "pattern": {
+	            "type": "string",
+	            "minLength": 1
+	          },
+	          "replacement": {
+	            "type": "string"
+	          }
+	        },
+	        "required": ["pattern"],
+	        "additionalProperties": false
+	      }
+	    },
+	    "redact-bypass-principals": {
+	      "type": "array",
+	      "items": {
+	        "type": "string"
+	      }
+	    },
+	    "local-sandbox-principals": {
+	      "type": "array",
+	      "items": {
+	        "type": "string",
+	        "minLength": 1
+	      }
+	    },
+	    "json-field-keys": {
+	      "type": "object",
+	      "properties": {
+	        "level": {
+	          "type": "array",
+	          "items": {
+	            "type": "string",
+	            "minLength": 1
+	          }
+	        },
+	        "timestamp": {
+	          "type": "array",
+	          "items": {
+	            "type": "string",
+	            "minLength": 1
+	          }
+	        },
+	        "message": {
+	          "type": "array",
+	          "items": {
+	            "type": "string",
+	            "minLength": 1
+	          }
+	        }
+	      },
+	      "additionalProperties": false
+	    },
+	    "timestamp-layouts": {
+	      "type": "array",
+	      "items": {
+	        "type": "string",
+	        "minLength": 1
+	      }
 	    }
 	  },
 	  "required": ["role"],
+	  "dependencies": {
+	    "tls-cert": ["tls-key"],
+	    "tls-key": ["tls-cert"],
+	    "tls-client-ca": ["tls-cert"]
+	  },
 	  "additionalProperties": false
 	}`
 
+// Source describes where the value of a setting comes from.
+type Source string
+
+// Setting sources, from the lowest to the highest precedence.
+const (
+	SourceDefault Source = "default"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+	SourceFile    Source = "file"
+)
+
+// Reloadable is a list of settings applied without a restart when the config is reloaded.
+var Reloadable = []string{
+	"verbose",
+	"timeout",
+	"shutdown-timeout",
+	"max-streams",
+	"max-streams-per-client",
+	"rate-limit",
+	"rate-limit-burst",
+	"stream-rate-limit",
+	"redact",
+	"redact-bypass-principals",
+}
+
+func isReloadable(name string) bool {
+	for _, reloadable := range Reloadable {
+		if reloadable == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Sensitive is a list of settings which values are not returned by Settings, they would reveal the redacted
+// secrets and the principals allowed to read them.
+var Sensitive = []string{
+	"redact",
+	"redact-bypass-principals",
+	"local-sandbox-principals",
+}
+
+func isSensitive(name string) bool {
+	for _, sensitive := range Sensitive {
+		if sensitive == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Setting is an effective value of a config setting. The value of a sensitive setting is null.
+type Setting struct {
+	Name       string          `json:"name"`
+	Value      json.RawMessage `json:"value"`
+	Source     Source          `json:"source"`
+	Reloadable bool            `json:"reloadable"`
+	Sensitive  bool            `json:"sensitive,omitempty"`
+}
+
+// JSONFieldKeys lists the keys of JSON log lines holding the level, timestamp and message. An empty list
+// keeps the default keys.
+type JSONFieldKeys struct {
+	Level     []string `json:"level,omitempty"`
+	Timestamp []string `json:"timestamp,omitempty"`
+	Message   []string `json:"message,omitempty"`
+}
+
 // Config is a structure used to store dcos-log config.
 type Config struct {
 	// FlagPort is a TCP port the service must run on.
@@ -67,11 +346,122 @@ type Config struct {
 	// FlagCACertFile is a path to CA certificate.
 	FlagCACertFile string `json:"ca-cert"`
 
+	// FlagAuthJWKSURL is a URL of the IAM JSON web key set used to verify the authentication tokens. If empty,
+	// the tokens are not verified and the features which need the client identity are disabled.
+	FlagAuthJWKSURL string `json:"auth-jwks-url,omitempty"`
+
+	// FlagTLSCertFile is a path to the server certificate. If set, dcos-log serves HTTPS.
+	FlagTLSCertFile string `json:"tls-cert,omitempty"`
+
+	// FlagTLSKeyFile is a path to the server certificate private key.
+	FlagTLSKeyFile string `json:"tls-key,omitempty"`
+
+	// FlagTLSClientCAFile is a path to CA certificate used to verify client certificates. If set, a client
+	// must present a valid certificate.
+	FlagTLSClientCAFile string `json:"tls-client-ca,omitempty"`
+
 	// FlagGetRequestTimeout sets a timeout for Get requests used in authorization.
 	FlagGetRequestTimeout string `json:"timeout"`
 
+	// FlagShutdownTimeout is the time given to the open requests and log streams to finish on SIGTERM.
+	FlagShutdownTimeout string `json:"shutdown-timeout"`
+
+	// FlagMesosScheme is a scheme used to reach mesos. If empty, https is used with auth enabled, http otherwise.
+	FlagMesosScheme string `json:"mesos-scheme,omitempty"`
+
+	// FlagMesosAgentPort is a port of the local mesos agent.
+	FlagMesosAgentPort int `json:"mesos-agent-port"`
+
+	// FlagMesosMasterPort is a port of mesos master.
+	FlagMesosMasterPort int `json:"mesos-master-port"`
+
+	// FlagMesosStateURL is a URL of the leading mesos master state endpoint. If empty, the leader DNS record
+	// with FlagMesosMasterPort is used.
+	FlagMesosStateURL string `json:"mesos-state-url,omitempty"`
+
+	// FlagSandboxRoot is a directory with mesos agent sandboxes, `<work_dir>/slaves`.
+	FlagSandboxRoot string `json:"sandbox-root"`
+
+	// FlagMesosTimeout is a timeout of a single request to mesos files API, including the retries, and state endpoints.
+	FlagMesosTimeout string `json:"mesos-timeout"`
+
+	// FlagMesosChunkSize is a number of bytes requested from mesos files API at once.
+	FlagMesosChunkSize int `json:"mesos-chunk-size"`
+
+	// FlagMesosMaxLineSize is a maximum size of a sandbox file line in bytes, the longer lines are truncated.
+	FlagMesosMaxLineSize int `json:"mesos-max-line-size"`
+
+	// FlagMesosRetries is a number of times a failed request to mesos files API is retried.
+	FlagMesosRetries int `json:"mesos-retries"`
+
+	// FlagArchiveMaxSize is a maximum size in megabytes of the files in a sandbox archive. 0 means unlimited.
+	FlagArchiveMaxSize int `json:"archive-max-size"`
+
+	// FlagAgentLogsScheme is a scheme of dcos-log on the agents, reached through admin router by the cluster search.
+	FlagAgentLogsScheme string `json:"agent-logs-scheme,omitempty"`
+
+	// FlagAgentLogsPort is a port of dcos-log on the agents, reached through admin router by the cluster search.
+	FlagAgentLogsPort int `json:"agent-logs-port"`
+
+	// FlagSearchConcurrency is a number of agents queried at the same time by the cluster search.
+	FlagSearchConcurrency int `json:"search-concurrency"`
+
+	// FlagSearchTimeout is a timeout of the cluster search query to a single agent.
+	FlagSearchTimeout string `json:"search-timeout"`
+
 	// FlagRole sets a node's role
 	FlagRole string `json:"role"`
+
+	// FlagAudit enables audit records for every request.
+	FlagAudit bool `json:"audit"`
+
+	// FlagAuditFile is a path to the audit log file. If empty, audit records are written to the journal.
+	FlagAuditFile string `json:"audit-file"`
+
+	// FlagAuditFileMaxSize is a size of the audit log file in megabytes after which the file is rotated.
+	FlagAuditFileMaxSize int `json:"audit-file-max-size"`
+
+	// FlagAuditFileMaxBackups is a number of rotated audit log files to keep.
+	FlagAuditFileMaxBackups int `json:"audit-file-max-backups"`
+
+	// FlagMaxStreams is a maximum number of concurrently open log streams. 0 means unlimited.
+	FlagMaxStreams int `json:"max-streams"`
+
+	// FlagMaxStreamsPerClient is a maximum number of concurrently open log streams per principal, or per remote
+	// address for the requests without a token. 0 means unlimited.
+	FlagMaxStreamsPerClient int `json:"max-streams-per-client"`
+
+	// FlagRateLimit is a number of requests per second allowed per client. 0 means unlimited.
+	FlagRateLimit float64 `json:"rate-limit"`
+
+	// FlagRateLimitBurst is a number of requests a client can make at once above the rate limit.
+	FlagRateLimitBurst int `json:"rate-limit-burst"`
+
+	// FlagStreamRateLimit is a maximum number of bytes per second sent to a single log stream. 0 means unlimited.
+	FlagStreamRateLimit int `json:"stream-rate-limit"`
+
+	// FlagRedactRules is a list of redaction rules applied to the served log content. Config file only.
+	FlagRedactRules []redact.Rule `json:"redact,omitempty"`
+
+	// FlagRedactBypassPrincipals is a list of principals allowed to read unredacted logs with ?redact=false.
+	// Config file only.
+	FlagRedactBypassPrincipals []string `json:"redact-bypass-principals,omitempty"`
+
+	// FlagLocalSandboxPrincipals is a list of principals allowed to read the sandboxes mesos agent no longer
+	// knows from the local disk. Mesos can't authorize these reads, they are disabled if the list is empty.
+	// Config file only.
+	FlagLocalSandboxPrincipals []string `json:"local-sandbox-principals,omitempty"`
+
+	// FlagJSONFieldKeys overrides the keys of JSON log lines mapped to the level, timestamp and message fields
+	// with ?parse=json. Config file only.
+	FlagJSONFieldKeys *JSONFieldKeys `json:"json-field-keys,omitempty"`
+
+	// FlagTimestampLayouts is a list of Go time layouts of the task log line timestamps used by ?since and ?until.
+	// The default layouts are used if not set. Config file only.
+	FlagTimestampLayouts []string `json:"timestamp-layouts,omitempty"`
+
+	args    []string
+	sources map[string]Source
 }
 
 func (c *Config) setFlags(fs *flag.FlagSet) {
@@ -80,29 +470,124 @@ func (c *Config) setFlags(fs *flag.FlagSet) {
 	fs.StringVar(&c.FlagConfig, "config", c.FlagConfig, "Use config file.")
 	fs.BoolVar(&c.FlagAuth, "auth", c.FlagAuth, "Enable authorization.")
 	fs.StringVar(&c.FlagCACertFile, "ca-cert", c.FlagCACertFile, "Use certificate authority.")
+	fs.StringVar(&c.FlagAuthJWKSURL, "auth-jwks-url", c.FlagAuthJWKSURL,
+		"IAM JSON web key set URL used to verify the authentication tokens.")
+	fs.StringVar(&c.FlagTLSCertFile, "tls-cert", c.FlagTLSCertFile, "Serve HTTPS with the certificate.")
+	fs.StringVar(&c.FlagTLSKeyFile, "tls-key", c.FlagTLSKeyFile, "Server certificate private key.")
+	fs.StringVar(&c.FlagTLSClientCAFile, "tls-client-ca", c.FlagTLSClientCAFile,
+		"Require client certificates signed by the certificate authority.")
 	fs.StringVar(&c.FlagGetRequestTimeout, "timeout", c.FlagGetRequestTimeout, "GET request timeout.")
+	fs.StringVar(&c.FlagShutdownTimeout, "shutdown-timeout", c.FlagShutdownTimeout,
+		"Time to wait for open requests to finish on shutdown.")
+	fs.StringVar(&c.FlagMesosScheme, "mesos-scheme", c.FlagMesosScheme,
+		"Scheme used to reach mesos, https if auth is enabled by default.")
+	fs.IntVar(&c.FlagMesosAgentPort, "mesos-agent-port", c.FlagMesosAgentPort, "Mesos agent port.")
+	fs.IntVar(&c.FlagMesosMasterPort, "mesos-master-port", c.FlagMesosMasterPort, "Mesos master port.")
+	fs.StringVar(&c.FlagMesosStateURL, "mesos-state-url", c.FlagMesosStateURL, "Leading mesos master state URL.")
+	fs.StringVar(&c.FlagSandboxRoot, "sandbox-root", c.FlagSandboxRoot, "Mesos agent sandboxes directory.")
+	fs.StringVar(&c.FlagMesosTimeout, "mesos-timeout", c.FlagMesosTimeout, "Mesos request timeout.")
+	fs.IntVar(&c.FlagMesosChunkSize, "mesos-chunk-size", c.FlagMesosChunkSize,
+		"Bytes requested from mesos files API at once.")
+	fs.IntVar(&c.FlagMesosMaxLineSize, "mesos-max-line-size", c.FlagMesosMaxLineSize,
+		"Maximum size of a sandbox file line in bytes, longer lines are truncated.")
+	fs.IntVar(&c.FlagMesosRetries, "mesos-retries", c.FlagMesosRetries,
+		"Number of retries of a failed mesos files API request.")
+	fs.IntVar(&c.FlagArchiveMaxSize, "archive-max-size", c.FlagArchiveMaxSize,
+		"Maximum size of the files in a sandbox archive in megabytes, 0 means unlimited.")
+	fs.StringVar(&c.FlagAgentLogsScheme, "agent-logs-scheme", c.FlagAgentLogsScheme,
+		"Scheme of admin router on the agents used by the cluster search. Defaults to https if -tls-cert is set.")
+	fs.IntVar(&c.FlagAgentLogsPort, "agent-logs-port", c.FlagAgentLogsPort,
+		"Port of admin router on the agents used by the cluster search.")
+	fs.IntVar(&c.FlagSearchConcurrency, "search-concurrency", c.FlagSearchConcurrency,
+		"Number of agents queried at the same time by the cluster search.")
+	fs.StringVar(&c.FlagSearchTimeout, "search-timeout", c.FlagSearchTimeout,
+		"Timeout of the cluster search query to a single agent.")
 	fs.StringVar(&c.FlagRole, "role", c.FlagRole, "Set node's role.")
+	fs.BoolVar(&c.FlagAudit, "audit", c.FlagAudit, "Enable audit records.")
+	fs.StringVar(&c.FlagAuditFile, "audit-file", c.FlagAuditFile, "Write audit records to a file instead of the journal.")
+	fs.IntVar(&c.FlagAuditFileMaxSize, "audit-file-max-size", c.FlagAuditFileMaxSize,
+		"Rotate the audit file after it reaches the size in megabytes.")
+	fs.IntVar(&c.FlagAuditFileMaxBackups, "audit-file-max-backups", c.FlagAuditFileMaxBackups,
+		"Number of rotated audit files to keep.")
+	fs.IntVar(&c.FlagMaxStreams, "max-streams", c.FlagMaxStreams, "Maximum number of open log streams.")
+	fs.IntVar(&c.FlagMaxStreamsPerClient, "max-streams-per-client", c.FlagMaxStreamsPerClient,
+		"Maximum number of open log streams per client.")
+	fs.Float64Var(&c.FlagRateLimit, "rate-limit", c.FlagRateLimit, "Requests per second allowed per client.")
+	fs.IntVar(&c.FlagRateLimitBurst, "rate-limit-burst", c.FlagRateLimitBurst,
+		"Requests a client can make at once above the rate limit.")
+	fs.IntVar(&c.FlagStreamRateLimit, "stream-rate-limit", c.FlagStreamRateLimit,
+		"Maximum bytes per second sent to a log stream.")
 }
 
-// NewConfig returns a new instance of Config with loaded fields.
+// envName returns the environment variable overriding a flag default, e.g. DCOS_LOG_AUDIT_FILE for -audit-file.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// applyEnv sets the flags from DCOS_LOG_* environment variables. The flags given in the command line
+// take precedence.
+func (c *Config) applyEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || err != nil {
+			return
+		}
+
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %s", value, envName(f.Name), setErr)
+			return
+		}
+		c.sources[f.Name] = SourceEnv
+	})
+	return err
+}
+
+// NewConfig returns a new instance of Config with loaded fields. The values are taken from, in order of
+// precedence, the config file, the command line flags, DCOS_LOG_* environment variables and the defaults.
 func NewConfig(args []string) (*Config, error) {
-	config := &Config{}
+	config := &Config{
+		sources: make(map[string]Source),
+	}
 	if len(args) == 0 {
 		return config, errors.New("arguments cannot be empty")
 	}
+	config.args = args
 
 	// load default config values
 	config.FlagPort = defaultHTTPPort
 	config.FlagGetRequestTimeout = defaultGETRequestTimeout
+	config.FlagShutdownTimeout = defaultShutdownTimeout
+	config.FlagMesosAgentPort = dcos.PortMesosAgent
+	config.FlagMesosMasterPort = dcos.PortMesosMaster
+	config.FlagSandboxRoot = defaultSandboxRoot
+	config.FlagMesosTimeout = defaultMesosTimeout.String()
+	config.FlagMesosChunkSize = defaultMesosChunkSize
+	config.FlagMesosRetries = defaultMesosRetries
+	config.FlagMesosMaxLineSize = defaultMesosMaxLineSize
+	config.FlagArchiveMaxSize = defaultArchiveMaxSize
+	config.FlagAgentLogsPort = defaultAgentLogsPort
+	config.FlagSearchConcurrency = defaultSearchConcurrency
+	config.FlagSearchTimeout = defaultSearchTimeout.String()
+	config.FlagAuditFileMaxSize = defaultAuditFileMaxSize
+	config.FlagAuditFileMaxBackups = defaultAuditFileMaxBackups
 
 	flagSet := flag.NewFlagSet(dcosLog, flag.ContinueOnError)
 	config.setFlags(flagSet)
 
+	if err := config.applyEnv(flagSet); err != nil {
+		return config, err
+	}
+
 	// override with user provided arguments
 	if err := flagSet.Parse(args[1:]); err != nil {
 		return config, err
 	}
 
+	flagSet.Visit(func(f *flag.Flag) {
+		config.sources[f.Name] = SourceFlag
+	})
+
 	// read config file if exists.
 	if err := readAndUpdateConfigFile(config); err != nil {
 		return nil, err
@@ -131,10 +616,204 @@ func readAndUpdateConfigFile(defaultConfig *Config) error {
 		return err
 	}
 
+	fileValues := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(configContent, &fileValues); err != nil {
+		return err
+	}
+
+	for name := range fileValues {
+		defaultConfig.sources[name] = SourceFile
+	}
+
 	// override default values
 	return json.Unmarshal(configContent, defaultConfig)
 }
 
+// MesosScheme returns a scheme used to reach mesos.
+func (c *Config) MesosScheme() string {
+	if c.FlagMesosScheme != "" {
+		return c.FlagMesosScheme
+	}
+
+	if c.FlagAuth {
+		return "https"
+	}
+	return "http"
+}
+
+// AgentLogsScheme returns a scheme used to reach dcos-log on the agents.
+func (c *Config) AgentLogsScheme() string {
+	if c.FlagAgentLogsScheme != "" {
+		return c.FlagAgentLogsScheme
+	}
+
+	if c.FlagTLSCertFile != "" {
+		return "https"
+	}
+	return "http"
+}
+
+// MesosPort returns a port of the local mesos process, master or agent depending on the node role.
+func (c *Config) MesosPort() int {
+	if c.FlagRole == dcos.RoleMaster {
+		return c.FlagMesosMasterPort
+	}
+	return c.FlagMesosAgentPort
+}
+
+// MesosTimeout returns a timeout of a single request to mesos. The default is returned if the value is invalid.
+func (c *Config) MesosTimeout() time.Duration {
+	timeout, err := time.ParseDuration(c.FlagMesosTimeout)
+	if err != nil || timeout <= 0 {
+		return defaultMesosTimeout
+	}
+	return timeout
+}
+
+// SearchTimeout returns a timeout of the cluster search query to a single agent. The default is returned
+// if the value is invalid.
+func (c *Config) SearchTimeout() time.Duration {
+	timeout, err := time.ParseDuration(c.FlagSearchTimeout)
+	if err != nil || timeout <= 0 {
+		return defaultSearchTimeout
+	}
+	return timeout
+}
+
+// MesosStateURL returns a URL of the leading mesos master state endpoint.
+func (c *Config) MesosStateURL() string {
+	if c.FlagMesosStateURL != "" {
+		return c.FlagMesosStateURL
+	}
+
+	stateURL := url.URL{
+		Scheme: c.MesosScheme(),
+		Host:   net.JoinHostPort(dcos.DNSRecordLeader, strconv.Itoa(c.FlagMesosMasterPort)),
+		Path:   "/state",
+	}
+	return stateURL.String()
+}
+
+// values returns the config settings encoded to JSON by the setting name.
+func (c *Config) values() (map[string]json.RawMessage, error) {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Source returns the source of a setting value.
+func (c *Config) Source(name string) Source {
+	if source, ok := c.sources[name]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// Settings returns the effective config settings sorted by name, without the values of the sensitive settings.
+func (c *Config) Settings() ([]Setting, error) {
+	values, err := c.values()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make([]Setting, 0, len(values))
+	for name, value := range values {
+		sensitive := isSensitive(name)
+		if sensitive {
+			value = json.RawMessage("null")
+		}
+
+		settings = append(settings, Setting{
+			Name:       name,
+			Value:      value,
+			Source:     c.Source(name),
+			Reloadable: isReloadable(name),
+			Sensitive:  sensitive,
+		})
+	}
+
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Name < settings[j].Name
+	})
+	return settings, nil
+}
+
+// Reload loads the config again from the same arguments, environment and config file. It returns a copy of
+// the config with the reloadable settings updated, the names of the changed reloadable settings and
+// the names of the changed settings which are not applied until a restart.
+func (c *Config) Reload() (reloaded *Config, changed, restartRequired []string, err error) {
+	loaded, err := NewConfig(c.args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	current, err := c.values()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	values, err := loaded.values()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range current {
+		names[name] = true
+	}
+	for name := range values {
+		names[name] = true
+	}
+
+	reloaded = &Config{
+		FlagConfig: c.FlagConfig,
+		args:       c.args,
+		sources:    make(map[string]Source),
+	}
+	for name, source := range c.sources {
+		reloaded.sources[name] = source
+	}
+
+	for name := range names {
+		if bytes.Equal(current[name], values[name]) {
+			continue
+		}
+
+		if !isReloadable(name) {
+			restartRequired = append(restartRequired, name)
+			continue
+		}
+
+		changed = append(changed, name)
+		if value, ok := values[name]; ok {
+			current[name] = value
+		} else {
+			delete(current, name)
+		}
+		reloaded.sources[name] = loaded.Source(name)
+	}
+
+	body, err := json.Marshal(current)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := json.Unmarshal(body, reloaded); err != nil {
+		return nil, nil, nil, err
+	}
+
+	sort.Strings(changed)
+	sort.Strings(restartRequired)
+	return reloaded, changed, restartRequired, nil
+}
+
 func validateConfigStruct(config *Config) error {
 	documentLoader := gojsonschema.NewGoLoader(config)
 	return validate(documentLoader)
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "dcos-log-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestConfigSources(t *testing.T) {
+	os.Setenv("DCOS_LOG_MAX_STREAMS", "10")
+	os.Setenv("DCOS_LOG_TIMEOUT", "7s")
+	defer os.Unsetenv("DCOS_LOG_MAX_STREAMS")
+	defer os.Unsetenv("DCOS_LOG_TIMEOUT")
+
+	configFile := writeConfigFile(t, `{"role": "agent", "max-streams-per-client": 2}`)
+	defer os.Remove(configFile)
+
+	cfg, err := NewConfig([]string{"dcos-log", "-config", configFile, "-timeout", "3s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.FlagMaxStreams != 10 || cfg.FlagGetRequestTimeout != "3s" || cfg.FlagMaxStreamsPerClient != 2 {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	for name, source := range map[string]Source{
+		"max-streams":            SourceEnv,
+		"timeout":                SourceFlag,
+		"max-streams-per-client": SourceFile,
+		"role":                   SourceFile,
+		"port":                   SourceDefault,
+	} {
+		if cfg.Source(name) != source {
+			t.Fatalf("expect %s source %s. Got %s", name, source, cfg.Source(name))
+		}
+	}
+
+	settings, err := cfg.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, setting := range settings {
+		if setting.Name == "max-streams" {
+			if string(setting.Value) != "10" || !setting.Reloadable {
+				t.Fatalf("unexpected setting %+v", setting)
+			}
+			return
+		}
+	}
+	t.Fatal("max-streams setting not found")
+}
+
+func TestConfigSettingsSensitive(t *testing.T) {
+	configFile := writeConfigFile(t, `{"role": "agent", "redact": [{"pattern": "secret"}],
+		"redact-bypass-principals": ["bootstrapuser"], "local-sandbox-principals": ["bootstrapuser"]}`)
+	defer os.Remove(configFile)
+
+	cfg, err := NewConfig([]string{"dcos-log", "-config", configFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := cfg.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hidden := 0
+	for _, setting := range settings {
+		if isSensitive(setting.Name) {
+			if string(setting.Value) != "null" || !setting.Sensitive || setting.Source != SourceFile {
+				t.Fatalf("expect the value to be hidden. Got %+v", setting)
+			}
+			hidden++
+		}
+	}
+
+	if hidden != 3 {
+		t.Fatalf("expect 3 sensitive settings. Got %d", hidden)
+	}
+}
+
+func TestConfigLocalSandboxPrincipals(t *testing.T) {
+	configFile := writeConfigFile(t, `{"role": "agent", "local-sandbox-principals": ["bootstrapuser"]}`)
+	defer os.Remove(configFile)
+
+	cfg, err := NewConfig([]string{"dcos-log", "-config", configFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.FlagLocalSandboxPrincipals) != 1 || cfg.FlagLocalSandboxPrincipals[0] != "bootstrapuser" {
+		t.Fatalf("expect bootstrapuser. Got %v", cfg.FlagLocalSandboxPrincipals)
+	}
+
+	invalidFile := writeConfigFile(t, `{"role": "agent", "local-sandbox-principals": [""]}`)
+	defer os.Remove(invalidFile)
+
+	if _, err := NewConfig([]string{"dcos-log", "-config", invalidFile}); err == nil {
+		t.Fatal("expect an empty principal to be invalid")
+	}
+}
+
+func TestConfigInvalidEnv(t *testing.T) {
+	os.Setenv("DCOS_LOG_PORT", "not-a-number")
+	defer os.Unsetenv("DCOS_LOG_PORT")
+
+	if _, err := NewConfig([]string{"dcos-log", "-role", "agent"}); err == nil {
+		t.Fatal("expect an error for invalid DCOS_LOG_PORT")
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	configFile := writeConfigFile(t, `{"role": "agent", "port": 8080, "verbose": false}`)
+	defer os.Remove(configFile)
+
+	cfg, err := NewConfig([]string{"dcos-log", "-config", configFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(configFile, []byte(`{"role": "agent", "port": 9090, "verbose": true,
+		"redact": [{"pattern": "secret"}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, changed, restartRequired, err := cfg.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(changed, []string{"redact", "verbose"}) {
+		t.Fatalf("unexpected changed settings %v", changed)
+	}
+
+	if !reflect.DeepEqual(restartRequired, []string{"port"}) {
+		t.Fatalf("unexpected restart required settings %v", restartRequired)
+	}
+
+	if !reloaded.FlagVerbose || len(reloaded.FlagRedactRules) != 1 {
+		t.Fatalf("expect reloadable settings to be applied. Got %+v", reloaded)
+	}
+
+	if reloaded.FlagPort != 8080 {
+		t.Fatalf("expect port not to be changed. Got %d", reloaded.FlagPort)
+	}
+
+	// the reloaded config can be reloaded again.
+	if _, changed, _, err := reloaded.Reload(); err != nil || len(changed) != 0 {
+		t.Fatalf("expect no changes. Got %v, %v", changed, err)
+	}
+}
+
+func TestConfigMesosEndpoints(t *testing.T) {
+	cfg, err := NewConfig([]string{"dcos-log", "-role", "agent", "-auth"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.MesosScheme() != "https" || cfg.MesosPort() != 5051 {
+		t.Fatalf("unexpected mesos scheme %s or port %d", cfg.MesosScheme(), cfg.MesosPort())
+	}
+
+	if stateURL := cfg.MesosStateURL(); stateURL != "https://leader.mesos:5050/state" {
+		t.Fatalf("unexpected state URL %s", stateURL)
+	}
+
+	if cfg.MesosTimeout() != 10*time.Second || cfg.FlagMesosChunkSize != 65536 {
+		t.Fatalf("unexpected mesos timeout %s or chunk size %d", cfg.MesosTimeout(), cfg.FlagMesosChunkSize)
+	}
+
+	cfg, err = NewConfig([]string{"dcos-log", "-role", "master", "-mesos-scheme", "http",
+		"-mesos-master-port", "15050", "-sandbox-root", "/mnt/mesos/slaves", "-mesos-timeout", "30s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.MesosScheme() != "http" || cfg.MesosPort() != 15050 || cfg.FlagSandboxRoot != "/mnt/mesos/slaves" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	if stateURL := cfg.MesosStateURL(); stateURL != "http://leader.mesos:15050/state" {
+		t.Fatalf("unexpected state URL %s", stateURL)
+	}
+
+	if cfg.MesosTimeout() != 30*time.Second {
+		t.Fatalf("unexpected mesos timeout %s", cfg.MesosTimeout())
+	}
+
+	for _, args := range [][]string{
+		{"-sandbox-root", "relative/path"},
+		{"-mesos-scheme", "ftp"},
+		{"-mesos-agent-port", "0"},
+		{"-mesos-state-url", "leader.mesos/state"},
+		{"-mesos-chunk-size", "100"},
+	} {
+		if _, err := NewConfig(append([]string{"dcos-log", "-role", "agent"}, args...)); err == nil {
+			t.Fatalf("expect validation error for %v", args)
+		}
+	}
+}
+
+func TestConfigAgentLogsScheme(t *testing.T) {
+	for _, tc := range []struct {
+		cfg    Config
+		scheme string
+	}{
+		{Config{}, "http"},
+		{Config{FlagAuth: true, FlagMesosScheme: "https"}, "http"},
+		{Config{FlagTLSCertFile: "/run/dcos/pki/tls/certs/dcos-log.crt"}, "https"},
+		{Config{FlagTLSCertFile: "/run/dcos/pki/tls/certs/dcos-log.crt", FlagAgentLogsScheme: "http"}, "http"},
+		{Config{FlagAgentLogsScheme: "https"}, "https"},
+	} {
+		if scheme := tc.cfg.AgentLogsScheme(); scheme != tc.scheme {
+			t.Fatalf("expect %s for %+v. Got %s", tc.scheme, tc.cfg, scheme)
+		}
+	}
+
+	if _, err := NewConfig([]string{"dcos-log", "-role", "master", "-agent-logs-scheme", "ftp"}); err == nil {
+		t.Fatal("expect an invalid agent logs scheme")
+	}
+}
+
+func TestConfigTimestampLayouts(t *testing.T) {
+	configFile := writeConfigFile(t, `{"role": "agent", "timestamp-layouts": ["2006-01-02"]}`)
+	defer os.Remove(configFile)
+
+	cfg, err := NewConfig([]string{"dcos-log", "-config", configFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cfg.FlagTimestampLayouts, []string{"2006-01-02"}) {
+		t.Fatalf("unexpected timestamp layouts %v", cfg.FlagTimestampLayouts)
+	}
+
+	invalid := writeConfigFile(t, `{"role": "agent", "timestamp-layouts": [""]}`)
+	defer os.Remove(invalid)
+
+	if _, err := NewConfig([]string{"dcos-log", "-config", invalid}); err == nil {
+		t.Fatal("expect validation error for an empty layout")
+	}
+}
@@ -14,5 +14,7 @@ func main() {
 		logrus.Fatalf("Could not load config: %s", err)
 	}
 
-	logrus.Fatal(api.StartServer(cfg))
+	if err := api.StartServer(cfg); err != nil {
+		logrus.Fatal(err)
+	}
 }
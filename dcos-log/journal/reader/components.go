@@ -0,0 +1,248 @@
+package reader
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/coreos/go-systemd/sdjournal"
+)
+
+// unitFields are the journal fields with the unit of an entry. _SYSTEMD_UNIT is the unit which logged the entry,
+// UNIT is the unit systemd logs about, e.g. when it's started or failed.
+var unitFields = []string{"_SYSTEMD_UNIT", "UNIT"}
+
+// priorityNames are the syslog names of the journal PRIORITY values.
+var priorityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// contextCheckInterval is a number of entries read between the checks if the request is cancelled.
+const contextCheckInterval = 1000
+
+// Component is a systemd unit with its log activity.
+type Component struct {
+	Name string `json:"name"`
+
+	// LastRealtimeTimestamp is the time of the last entry of the unit in microseconds, also outside the window.
+	LastRealtimeTimestamp uint64 `json:"last_realtime_timestamp,omitempty"`
+
+	// Entries is a number of the unit entries in the window.
+	Entries uint64 `json:"entries"`
+
+	// Priority is the highest priority of the unit entries in the window, e.g. err or warning.
+	Priority string `json:"priority,omitempty"`
+
+	// priority is the lowest PRIORITY value, the most severe, -1 if no entry had a priority.
+	priority int
+}
+
+// componentStats aggregates the journal entries by unit.
+type componentStats map[string]*Component
+
+func newComponentStats(units []string) componentStats {
+	stats := make(componentStats, len(units))
+	for _, unit := range units {
+		stats[unit] = &Component{Name: unit, priority: -1}
+	}
+	return stats
+}
+
+// last sets the time of the last entry of a unit.
+func (s componentStats) last(unit string, usec uint64) {
+	if c, ok := s[unit]; ok && usec > c.LastRealtimeTimestamp {
+		c.LastRealtimeTimestamp = usec
+	}
+}
+
+// add counts an entry in the window. The entry is counted once for every listed unit it belongs to.
+func (s componentStats) add(units []string, priority string, usec uint64) {
+	p, err := strconv.Atoi(priority)
+	if err != nil || p < 0 || p >= len(priorityNames) {
+		p = -1
+	}
+
+	for i, unit := range units {
+		c, ok := s[unit]
+		if !ok {
+			continue
+		}
+
+		// _SYSTEMD_UNIT and UNIT are usually different, a unit may still log about itself.
+		if i > 0 && unit == units[0] {
+			continue
+		}
+
+		c.Entries++
+		if usec > c.LastRealtimeTimestamp {
+			c.LastRealtimeTimestamp = usec
+		}
+
+		if p >= 0 && (c.priority < 0 || p < c.priority) {
+			c.priority = p
+			c.Priority = priorityNames[p]
+		}
+	}
+}
+
+// list returns the components, the ones with the most severe entries first, then the ones with most entries.
+func (s componentStats) list() []Component {
+	components := make([]Component, 0, len(s))
+	for _, c := range s {
+		components = append(components, *c)
+	}
+
+	// the entries without a priority are the least severe.
+	severity := func(c Component) int {
+		if c.priority < 0 {
+			return len(priorityNames)
+		}
+		return c.priority
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		a, b := components[i], components[j]
+		if severity(a) != severity(b) {
+			return severity(a) < severity(b)
+		}
+		if a.Entries != b.Entries {
+			return a.Entries > b.Entries
+		}
+		return a.Name < b.Name
+	})
+	return components
+}
+
+// componentJournal is the part of the journal API Components reads, implemented by sdjournal.Journal.
+type componentJournal interface {
+	Close() error
+	GetUniqueValues(field string) ([]string, error)
+	FlushMatches()
+	AddMatch(match string) error
+	AddDisjunction() error
+	SeekTail() error
+	SeekRealtimeUsec(usec uint64) error
+	Previous() (uint64, error)
+	Next() (uint64, error)
+	GetRealtimeUsec() (uint64, error)
+	GetDataValue(field string) (string, error)
+}
+
+// openComponentJournal opens the journal read by Components.
+var openComponentJournal = func() (componentJournal, error) {
+	j, err := sdjournal.NewJournal()
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// matchUnits adds the matches of the entries which belong to any of the units.
+func matchUnits(j componentJournal, units []string) error {
+	j.FlushMatches()
+	for _, unit := range units {
+		for _, field := range unitFields {
+			match := JournalEntryMatch{Field: field, Value: unit}
+			if err := j.AddMatch(match.String()); err != nil {
+				return err
+			}
+			if err := j.AddDisjunction(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Components returns the units with entries in the journal which include accepts, with the number of entries and
+// the highest priority in the window before now and the time of their last entry.
+func Components(ctx context.Context, window time.Duration, include func(unit string) bool) ([]Component, error) {
+	if window <= 0 {
+		return nil, ErrInvalidDuration
+	}
+
+	j, err := openComponentJournal()
+	if err != nil {
+		return nil, err
+	}
+	defer j.Close()
+
+	var units []string
+	seen := make(map[string]bool)
+	for _, field := range unitFields {
+		values, err := j.GetUniqueValues(field)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, unit := range values {
+			if !seen[unit] && include(unit) {
+				seen[unit] = true
+				units = append(units, unit)
+			}
+		}
+	}
+	stats := newComponentStats(units)
+
+	if len(units) == 0 {
+		return stats.list(), nil
+	}
+
+	// the last entry of every unit, it may be older than the window.
+	for _, unit := range units {
+		if err := matchUnits(j, []string{unit}); err != nil {
+			return nil, err
+		}
+
+		if err := j.SeekTail(); err != nil {
+			return nil, err
+		}
+
+		if n, err := j.Previous(); err != nil || n == 0 {
+			continue
+		}
+
+		if usec, err := j.GetRealtimeUsec(); err == nil {
+			stats.last(unit, usec)
+		}
+	}
+
+	// journald skips the entries of other units, they are not read.
+	if err := matchUnits(j, units); err != nil {
+		return nil, err
+	}
+
+	start := time.Now().Add(-window)
+	if err := j.SeekRealtimeUsec(uint64(start.UnixNano() / 1000)); err != nil {
+		return nil, err
+	}
+
+	entryUnits := make([]string, len(unitFields))
+	for read := 0; ; read++ {
+		if read%contextCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		n, err := j.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if n == 0 {
+			break
+		}
+
+		// the missing fields are empty.
+		for i, field := range unitFields {
+			entryUnits[i], _ = j.GetDataValue(field)
+		}
+		priority, _ := j.GetDataValue("PRIORITY")
+
+		usec, err := j.GetRealtimeUsec()
+		if err != nil {
+			return nil, err
+		}
+		stats.add(entryUnits, priority, usec)
+	}
+
+	return stats.list(), nil
+}
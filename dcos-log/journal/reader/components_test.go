@@ -0,0 +1,204 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJournal is an in-memory componentJournal. The entries are sorted by their realtime timestamp.
+type fakeJournal struct {
+	entries []map[string]string
+	usecs   []uint64
+	matches []string
+	pos     int
+
+	// read is a number of entries Next and Previous returned.
+	read int
+}
+
+func (j *fakeJournal) Close() error { return nil }
+
+func (j *fakeJournal) GetUniqueValues(field string) ([]string, error) {
+	var values []string
+	seen := make(map[string]bool)
+	for _, entry := range j.entries {
+		if value, ok := entry[field]; ok && !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+func (j *fakeJournal) FlushMatches() { j.matches = nil }
+
+func (j *fakeJournal) AddMatch(match string) error {
+	j.matches = append(j.matches, match)
+	return nil
+}
+
+// AddDisjunction is a no-op, the matches are always alternatives.
+func (j *fakeJournal) AddDisjunction() error { return nil }
+
+func (j *fakeJournal) SeekTail() error {
+	j.pos = len(j.entries)
+	return nil
+}
+
+func (j *fakeJournal) SeekRealtimeUsec(usec uint64) error {
+	j.pos = -1
+	for i, entryUsec := range j.usecs {
+		if entryUsec >= usec {
+			break
+		}
+		j.pos = i
+	}
+	return nil
+}
+
+func (j *fakeJournal) matched(i int) bool {
+	if len(j.matches) == 0 {
+		return true
+	}
+
+	for _, match := range j.matches {
+		kv := strings.SplitN(match, "=", 2)
+		if value, ok := j.entries[i][kv[0]]; ok && value == kv[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *fakeJournal) Next() (uint64, error) {
+	for j.pos++; j.pos < len(j.entries); j.pos++ {
+		if j.matched(j.pos) {
+			j.read++
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (j *fakeJournal) Previous() (uint64, error) {
+	for j.pos--; j.pos >= 0; j.pos-- {
+		if j.matched(j.pos) {
+			j.read++
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (j *fakeJournal) GetRealtimeUsec() (uint64, error) {
+	return j.usecs[j.pos], nil
+}
+
+func (j *fakeJournal) GetDataValue(field string) (string, error) {
+	value, ok := j.entries[j.pos][field]
+	if !ok {
+		return "", fmt.Errorf("no field %s", field)
+	}
+	return value, nil
+}
+
+// useFakeJournal makes Components read the journal until the test ends.
+func useFakeJournal(t *testing.T, j *fakeJournal) {
+	open := openComponentJournal
+	openComponentJournal = func() (componentJournal, error) { return j, nil }
+	t.Cleanup(func() { openComponentJournal = open })
+}
+
+func TestComponentStats(t *testing.T) {
+	stats := newComponentStats([]string{"dcos-mesos-slave.service", "dcos-adminrouter.service", "dcos-log.service"})
+
+	stats.last("dcos-log.service", 100)
+	stats.last("sshd.service", 100)
+	stats.add([]string{"dcos-mesos-slave.service", ""}, "6", 200)
+	stats.add([]string{"dcos-mesos-slave.service", ""}, "6", 300)
+	stats.add([]string{"dcos-adminrouter.service", ""}, "4", 250)
+	stats.add([]string{"init.scope", "dcos-adminrouter.service"}, "3", 260)
+	stats.add([]string{"dcos-mesos-slave.service", "dcos-mesos-slave.service"}, "", 310)
+	stats.add([]string{"sshd.service", ""}, "0", 400)
+
+	components := stats.list()
+	var actual []string
+	for _, c := range components {
+		actual = append(actual, fmt.Sprintf("%s %d %s %d", c.Name, c.Entries, c.Priority, c.LastRealtimeTimestamp))
+	}
+
+	expected := []string{
+		"dcos-adminrouter.service 2 err 260",
+		"dcos-mesos-slave.service 3 info 310",
+		"dcos-log.service 0  100",
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Fatalf("expect %v. Got %v", expected, actual)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	now := uint64(time.Now().UnixNano() / 1000)
+	hour := uint64(time.Hour / time.Microsecond)
+
+	j := &fakeJournal{}
+	for _, entry := range []struct {
+		usec   uint64
+		fields map[string]string
+	}{
+		{now - 3*hour, map[string]string{"_SYSTEMD_UNIT": "dcos-log.service", "PRIORITY": "3"}},
+		{now - 2*hour, map[string]string{"_SYSTEMD_UNIT": "sshd.service", "PRIORITY": "0"}},
+		{now - 30*60*1000000, map[string]string{"_SYSTEMD_UNIT": "dcos-adminrouter.service", "PRIORITY": "6"}},
+		{now - 20*60*1000000, map[string]string{"_SYSTEMD_UNIT": "init.scope", "UNIT": "dcos-adminrouter.service",
+			"PRIORITY": "3"}},
+		{now - 10*60*1000000, map[string]string{"_SYSTEMD_UNIT": "sshd.service", "PRIORITY": "6"}},
+		{now - 5*60*1000000, map[string]string{"_SYSTEMD_UNIT": "dcos-mesos-slave.service"}},
+	} {
+		j.entries = append(j.entries, entry.fields)
+		j.usecs = append(j.usecs, entry.usec)
+	}
+	useFakeJournal(t, j)
+
+	components, err := Components(context.Background(), time.Hour, func(unit string) bool {
+		return strings.HasPrefix(unit, "dcos-")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, c := range components {
+		actual = append(actual, fmt.Sprintf("%s %d %s %d", c.Name, c.Entries, c.Priority, now-c.LastRealtimeTimestamp))
+	}
+
+	expected := []string{
+		fmt.Sprintf("dcos-adminrouter.service 2 err %d", 20*60*1000000),
+		fmt.Sprintf("dcos-mesos-slave.service 1  %d", 5*60*1000000),
+		fmt.Sprintf("dcos-log.service 0  %d", 3*hour),
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Fatalf("expect %v. Got %v", expected, actual)
+	}
+
+	// the last entry of each of 3 units and the 3 entries of the units in the window, the other units are skipped.
+	if j.read != 6 {
+		t.Fatalf("expect 6 entries read. Got %d", j.read)
+	}
+}
+
+func TestComponentsCancelled(t *testing.T) {
+	useFakeJournal(t, &fakeJournal{
+		entries: []map[string]string{{"_SYSTEMD_UNIT": "dcos-log.service"}},
+		usecs:   []uint64{uint64(time.Now().UnixNano() / 1000)},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Components(ctx, time.Hour, func(string) bool { return true }); err != context.Canceled {
+		t.Fatalf("expect %v. Got %v", context.Canceled, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/coreos/go-systemd/sdjournal"
+	"github.com/dcos/dcos-log/dcos-log/multiline"
 	"github.com/sirupsen/logrus"
 )
 
@@ -31,6 +32,15 @@ func OptionReadReverse(reverse bool) Option {
 	}
 }
 
+// OptionMultiline is a functional option that groups the entries of multi-line events, e.g. stack traces, into
+// a single entry. Only the entries of the same process are grouped, the limit counts the events.
+func OptionMultiline(p *multiline.Pattern) Option {
+	return func(r *Reader) error {
+		r.multiline = p
+		return nil
+	}
+}
+
 // OptionLimit is a functional option sets a limit of entries to read from a journal.
 func OptionLimit(n uint64) Option {
 	return func(r *Reader) error {
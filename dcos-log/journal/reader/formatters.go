@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/coreos/go-systemd/sdjournal"
+	"github.com/dcos/dcos-log/dcos-log/redact"
 )
 
 // ContentType is used in response header.
@@ -126,6 +127,37 @@ func (j FormatSSE) FormatEntry(entry *sdjournal.JournalEntry) ([]byte, error) {
 	return entrySSE, nil
 }
 
+// FormatRedacted wraps an EntryFormatter and applies redaction rules to the entry fields before
+// the entry is formatted.
+type FormatRedacted struct {
+	EntryFormatter
+	Redactor *redact.Redactor
+}
+
+// NewRedactedFormatter returns an EntryFormatter which redacts the entries. If redactor is nil,
+// the original formatter is returned.
+func NewRedactedFormatter(f EntryFormatter, redactor *redact.Redactor) EntryFormatter {
+	if redactor == nil {
+		return f
+	}
+
+	return FormatRedacted{
+		EntryFormatter: f,
+		Redactor:       redactor,
+	}
+}
+
+// FormatEntry redacts the entry fields and formats the entry with the wrapped EntryFormatter.
+func (j FormatRedacted) FormatEntry(entry *sdjournal.JournalEntry) ([]byte, error) {
+	redactedEntry := *entry
+	redactedEntry.Fields = make(map[string]string, len(entry.Fields))
+	for k, v := range entry.Fields {
+		redactedEntry.Fields[k] = j.Redactor.Redact(v)
+	}
+
+	return j.EntryFormatter.FormatEntry(&redactedEntry)
+}
+
 func marshalJournalEntry(entry *sdjournal.JournalEntry) ([]byte, error) {
 	formattedEntry := struct {
 		Fields             map[string]string `json:"fields"`
@@ -0,0 +1,41 @@
+package reader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coreos/go-systemd/sdjournal"
+	"github.com/dcos/dcos-log/dcos-log/redact"
+)
+
+func TestFormatRedacted(t *testing.T) {
+	redactor, err := redact.New([]redact.Rule{{Pattern: "password=\\S+", Replacement: "password=***"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &sdjournal.JournalEntry{
+		Fields: map[string]string{
+			"MESSAGE": "login password=secret",
+		},
+	}
+
+	f := NewRedactedFormatter(&FormatJSON{}, redactor)
+	if f.GetContentType() != ContentTypeApplicationJSON {
+		t.Fatalf("expect content type %s. Got %s", ContentTypeApplicationJSON, f.GetContentType())
+	}
+
+	b, err := f.FormatEntry(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "secret") || !strings.Contains(string(b), "password=***") {
+		t.Fatalf("expect redacted entry. Got %s", b)
+	}
+
+	// the original entry must not be modified
+	if entry.Fields["MESSAGE"] != "login password=secret" {
+		t.Fatalf("original entry modified: %s", entry.Fields["MESSAGE"])
+	}
+}
@@ -0,0 +1,71 @@
+package reader
+
+import (
+	"testing"
+
+	"github.com/coreos/go-systemd/sdjournal"
+	"github.com/dcos/dcos-log/dcos-log/multiline"
+)
+
+func newEntry(cursor, unit, message string) *sdjournal.JournalEntry {
+	return &sdjournal.JournalEntry{
+		Cursor: cursor,
+		Fields: map[string]string{"_SYSTEMD_UNIT": unit, "MESSAGE": message},
+	}
+}
+
+func TestMultilineGroup(t *testing.T) {
+	p, err := multiline.Preset("java")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []*sdjournal.JournalEntry{
+		newEntry("1", "a", "ERROR failed"),
+		newEntry("2", "a", "\tat A.a(A.java:1)"),
+		newEntry("3", "b", "\tat B.b(B.java:2)"),
+		newEntry("4", "a", "\tat C.c(C.java:3)"),
+		newEntry("5", "a", "INFO done"),
+	}
+
+	for _, reverse := range []bool{false, true} {
+		r := &Reader{multiline: p, ReadReverse: reverse}
+
+		var events []*sdjournal.JournalEntry
+		for i := range entries {
+			entry := entries[i]
+			if reverse {
+				entry = entries[len(entries)-1-i]
+			}
+
+			if event := r.group(entry); event != nil {
+				events = append(events, event)
+			}
+		}
+		events = append(events, r.event)
+
+		if reverse {
+			for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+				events[i], events[j] = events[j], events[i]
+			}
+		}
+
+		// the entry of unit b does not belong to the event of unit a.
+		expected := []struct{ cursor, message string }{
+			{"2", "ERROR failed\n\tat A.a(A.java:1)"},
+			{"3", "\tat B.b(B.java:2)"},
+			{"4", "\tat C.c(C.java:3)"},
+			{"5", "INFO done"},
+		}
+		if len(events) != len(expected) {
+			t.Fatalf("reverse %t: expect %d events. Got %d", reverse, len(expected), len(events))
+		}
+
+		for i, e := range expected {
+			if events[i].Cursor != e.cursor || events[i].Fields["MESSAGE"] != e.message {
+				t.Fatalf("reverse %t: expect event %d %+v. Got %s %q", reverse, i, e, events[i].Cursor,
+					events[i].Fields["MESSAGE"])
+			}
+		}
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/coreos/go-systemd/sdjournal"
+	"github.com/dcos/dcos-log/dcos-log/metrics"
+	"github.com/dcos/dcos-log/dcos-log/multiline"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,6 +18,9 @@ import (
 // An instance of Reader must always be obtained by calling `NewReader` constructor function.
 var ErrUninitializedReader = errors.New("NewReader() must be called before using journal reader")
 
+var journalReopens = metrics.NewCounter("dcos_log_journal_reopen_total",
+	"Total number of times the journal was reopened by Follow after SD_JOURNAL_INVALIDATE.")
+
 // NewReader returns a new instance of journal reader.
 func NewReader(contentFormatter EntryFormatter, options ...Option) (r *Reader, err error) {
 	// if contentFormatter is not set, use FormatText by default.
@@ -58,6 +64,11 @@ type Reader struct {
 	// n represents the number of logs read.
 	n uint64
 
+	// event is a multi-line event which may continue in the next entries, eventLines is the number of its lines.
+	multiline  *multiline.Pattern
+	event      *sdjournal.JournalEntry
+	eventLines int
+
 	// matchFns contains a list of match functions the user used in the original constructor.
 	// this is useful to re-apply matches in some cases (for instance journald rotation)
 	matchFns []func(journal *sdjournal.Journal)
@@ -104,6 +115,136 @@ func (r *Reader) SeekCursor(c string) error {
 	return nil
 }
 
+// nextEntry moves the journal to the next entry and returns it. It returns nil if the end of the journal
+// is reached.
+func (r *Reader) nextEntry() (*sdjournal.JournalEntry, error) {
+	var (
+		c        uint64
+		err      error
+		skipRead bool
+	)
+	// The problem here is the following. When we read the journal for the first time we have to advance
+	// the cursor to read the very first entry. However when we move the cursor backwards with skip option
+	// `OptionSkipPrev` the cursor will be pointing to an actual entry which we want to read. In this case
+	// we have to be aware how many entries we already read and whether we can read the current cursor.
+
+	// only check if we need to move the cursor for the first time.
+	// if user used a specific cursor in the request we should check if we are pointing to it.
+	// if we are, we should not read the same entry and move to the next one.
+	if r.n == 0 && r.event == nil {
+		// if we can read the cursor without errors we should NOT advance the cursor for the first time.
+		// However, if the user provided a cursor in the request, we should not read, we have to move on
+		// to the next.
+		if cursor, err := r.Journal.GetCursor(); err == nil {
+			if cursor != r.Cursor {
+				skipRead = true
+			}
+		}
+	}
+
+	if !skipRead {
+		if r.ReadReverse {
+			c, err = r.Journal.Previous()
+		} else {
+			c, err = r.Journal.Next()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// EOF detection
+		if c == 0 {
+			return nil, nil
+		}
+	}
+
+	return r.Journal.GetEntry()
+}
+
+// nextEvent returns the next entry. With the multiline grouping, the entries continuing an event are merged
+// into one. An event is returned once the next one starts or the end of the journal is reached.
+func (r *Reader) nextEvent() (*sdjournal.JournalEntry, error) {
+	for {
+		entry, err := r.nextEntry()
+		if err != nil || r.multiline == nil {
+			return entry, err
+		}
+
+		if entry == nil {
+			event := r.event
+			r.event = nil
+			return event, nil
+		}
+
+		if event := r.group(entry); event != nil {
+			return event, nil
+		}
+	}
+}
+
+// group adds the entry to the current event. If the entry starts a new event, the current one is returned.
+func (r *Reader) group(entry *sdjournal.JournalEntry) *sdjournal.JournalEntry {
+	if r.event == nil {
+		r.event, r.eventLines = entry, 1
+		return nil
+	}
+
+	// reading in reverse, the first line of the current event decides whether the entry belongs to it.
+	message, next := entry.Fields["MESSAGE"], entry.Fields["MESSAGE"]
+	if r.ReadReverse {
+		next = r.eventHead()
+	}
+
+	if r.eventLines < r.multiline.MaxLines && sameSource(r.event, entry) && r.multiline.Continues(next) {
+		event := *r.event
+		event.Fields = make(map[string]string, len(r.event.Fields))
+		if r.ReadReverse {
+			// the event takes the fields of its first entry and keeps the cursor of the last one.
+			for k, v := range entry.Fields {
+				event.Fields[k] = v
+			}
+			event.Fields["MESSAGE"] = message + "\n" + r.event.Fields["MESSAGE"]
+			event.RealtimeTimestamp = entry.RealtimeTimestamp
+			event.MonotonicTimestamp = entry.MonotonicTimestamp
+		} else {
+			for k, v := range r.event.Fields {
+				event.Fields[k] = v
+			}
+			event.Fields["MESSAGE"] = r.event.Fields["MESSAGE"] + "\n" + message
+			event.Cursor = entry.Cursor
+		}
+
+		r.event = &event
+		r.eventLines++
+		return nil
+	}
+
+	event := r.event
+	r.event, r.eventLines = entry, 1
+	return event
+}
+
+// eventHead returns the first line of the current event.
+func (r *Reader) eventHead() string {
+	message := r.event.Fields["MESSAGE"]
+	if i := strings.IndexByte(message, '\n'); i != -1 {
+		return message[:i]
+	}
+	return message
+}
+
+// sourceFields identify the process which logged an entry. Only the entries of the same process are grouped.
+var sourceFields = []string{"_SYSTEMD_UNIT", "SYSLOG_IDENTIFIER", "_PID", "CONTAINER_ID"}
+
+func sameSource(a, b *sdjournal.JournalEntry) bool {
+	for _, field := range sourceFields {
+		if a.Fields[field] != b.Fields[field] {
+			return false
+		}
+	}
+	return true
+}
+
 // Read is implementation of Reader interface.
 // Most of the code was taken from https://github.com/coreos/go-systemd/blob/master/sdjournal/read.go
 func (r *Reader) Read(b []byte) (int, error) {
@@ -113,71 +254,36 @@ func (r *Reader) Read(b []byte) (int, error) {
 			return 0, io.EOF
 		}
 
-		var (
-			c        uint64
-			err      error
-			skipRead bool
-		)
-		// The problem here is the following. When we read the journal for the first time we have to advance
-		// the cursor to read the very first entry. However when we move the cursor backwards with skip option
-		// `OptionSkipPrev` the cursor will be pointing to an actual entry which we want to read. In this case
-		// we have to be aware how many entries we already read and whether we can read the current cursor.
-
-		// only check if we need to move the cursor for the first time.
-		// if user used a specific cursor in the request we should check if we are pointing to it.
-		// if we are, we should not read the same entry and move to the next one.
-		if r.n == 0 {
-			// if we can read the cursor without errors we should NOT advance the cursor for the first time.
-			// However, if the user provided a cursor in the request, we should not read, we have to move on
-			// to the next.
-			if cursor, err := r.Journal.GetCursor(); err == nil {
-				if cursor != r.Cursor {
-					skipRead = true
-				}
-			}
+		if r.contentFormatter == nil {
+			return 0, ErrUninitializedReader
 		}
 
-		if !skipRead {
-			if r.ReadReverse {
-				c, err = r.Journal.Previous()
-			} else {
-				c, err = r.Journal.Next()
-			}
-			if err != nil {
-				return 0, err
-			}
+		entry, err := r.nextEvent()
+		if err != nil {
+			return 0, err
+		}
 
-			// EOF detection
-			if c == 0 {
-				// for server sent events content type some proxies may close connection
-				// after a short timeout. We are going to send a ping comment every 15 seconds
-				// if no data available. This will ensure the connection is kept alive and
-				// nginx will not drop it with `Connection timed out` error.
-				// https://html.spec.whatwg.org/multipage/comms.html
-				if r.contentFormatter.GetContentType() == ContentTypeEventStream {
-					if time.Since(r.eofTime) < time.Duration(time.Second*15) {
-						return 0, io.EOF
-					}
-
-					r.msgReader = bytes.NewReader([]byte(": ping\n\n"))
-					r.eofTime = time.Now()
-					goto reader
+		// EOF detection
+		if entry == nil {
+			// for server sent events content type some proxies may close connection
+			// after a short timeout. We are going to send a ping comment every 15 seconds
+			// if no data available. This will ensure the connection is kept alive and
+			// nginx will not drop it with `Connection timed out` error.
+			// https://html.spec.whatwg.org/multipage/comms.html
+			if r.contentFormatter.GetContentType() == ContentTypeEventStream {
+				if time.Since(r.eofTime) < time.Duration(time.Second*15) {
+					return 0, io.EOF
 				}
-				return 0, io.EOF
+
+				r.msgReader = bytes.NewReader([]byte(": ping\n\n"))
+				r.eofTime = time.Now()
+				goto reader
 			}
+			return 0, io.EOF
 		}
 		// update the timer indicating we are not idling
 		r.eofTime = time.Now()
 
-		if r.contentFormatter == nil {
-			return 0, ErrUninitializedReader
-		}
-
-		entry, err := r.Journal.GetEntry()
-		if err != nil {
-			return 0, err
-		}
-
 		entryBytes, err := r.contentFormatter.FormatEntry(entry)
 		if err != nil {
 			return 0, err
@@ -239,6 +345,7 @@ func (r *Reader) Follow(wait time.Duration, writer io.Writer) error {
 	// https://www.freedesktop.org/software/systemd/man/sd_journal_get_fd.html#Return%20Value
 	if r.Journal.Wait(wait) == sdjournal.SD_JOURNAL_INVALIDATE {
 		logrus.Infof("SD_JOURNAL_INVALIDATE, reopened journal")
+		journalReopens.Inc()
 
 		cursor, err := r.Journal.GetCursor()
 		if err != nil {
@@ -0,0 +1,65 @@
+package limit
+
+import (
+	"time"
+)
+
+// bucket is a token bucket. It is filled with rate tokens per second up to burst tokens.
+// bucket is not safe for concurrent use.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate, burst float64) *bucket {
+	return &bucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// allow takes a token if one is available. Otherwise it returns false and the time until a token is available.
+func (b *bucket) allow(now time.Time) (bool, time.Duration) {
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, b.wait(1)
+}
+
+// reserve takes n tokens, the bucket may go into debt. It returns the time a caller must wait before
+// the reserved tokens are available.
+func (b *bucket) reserve(now time.Time, n float64) time.Duration {
+	b.refill(now)
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return b.wait(0)
+}
+
+// wait returns the time until the bucket has n tokens.
+func (b *bucket) wait(n float64) time.Duration {
+	missing := n - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / b.rate * float64(time.Second))
+}
@@ -0,0 +1,199 @@
+package limit
+
+import (
+	"sync"
+	"time"
+)
+
+// idleClientTimeout is the time after which the request rate state of an idle client is dropped.
+const idleClientTimeout = time.Minute
+
+// Config defines the limits. A zero value disables the limit.
+type Config struct {
+	// MaxStreams is a maximum number of concurrently open log streams.
+	MaxStreams int
+
+	// MaxStreamsPerClient is a maximum number of concurrently open log streams per client.
+	MaxStreamsPerClient int
+
+	// RequestsPerSecond is a sustained request rate allowed per client.
+	RequestsPerSecond float64
+
+	// RequestBurst is a number of requests a client can make at once above the sustained rate.
+	// If zero, the burst is equal to one second worth of requests.
+	RequestBurst int
+
+	// StreamBytesPerSecond is a maximum throughput of a single log stream.
+	StreamBytesPerSecond int
+}
+
+// Usage is a snapshot of the current limiter state.
+type Usage struct {
+	Limits  UsageLimits    `json:"limits"`
+	Streams int            `json:"streams"`
+	Clients map[string]int `json:"clients,omitempty"`
+}
+
+// UsageLimits are the configured limits.
+type UsageLimits struct {
+	MaxStreams           int     `json:"max_streams"`
+	MaxStreamsPerClient  int     `json:"max_streams_per_client"`
+	RequestsPerSecond    float64 `json:"requests_per_second"`
+	RequestBurst         int     `json:"request_burst"`
+	StreamBytesPerSecond int     `json:"stream_bytes_per_second"`
+}
+
+// Limiter enforces the concurrent stream and request rate limits. Clients are identified by an opaque key,
+// the principal or the remote address. Limiter is safe for concurrent use.
+type Limiter struct {
+	cfg Config
+
+	mu            sync.Mutex
+	streams       int
+	clientStreams map[string]int
+	clientRates   map[string]*bucket
+	lastSweep     time.Time
+}
+
+func withDefaults(cfg Config) Config {
+	if cfg.RequestsPerSecond > 0 && cfg.RequestBurst <= 0 {
+		cfg.RequestBurst = int(cfg.RequestsPerSecond)
+		if cfg.RequestBurst < 1 {
+			cfg.RequestBurst = 1
+		}
+	}
+	return cfg
+}
+
+// New returns a new Limiter.
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:           withDefaults(cfg),
+		clientStreams: make(map[string]int),
+		clientRates:   make(map[string]*bucket),
+		lastSweep:     time.Now(),
+	}
+}
+
+// SetConfig changes the limits. The open streams are kept even if they exceed the new limits,
+// the request rate state of all clients is reset.
+func (l *Limiter) SetConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cfg = withDefaults(cfg)
+	l.clientRates = make(map[string]*bucket)
+}
+
+// StreamBytesPerSecond returns the configured per stream throughput limit.
+func (l *Limiter) StreamBytesPerSecond() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg.StreamBytesPerSecond
+}
+
+// AllowRequest returns true if a client did not exceed the request rate. Otherwise it returns false and
+// the time after which the client may retry.
+func (l *Limiter) AllowRequest(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.RequestsPerSecond <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.clientRates[client]
+	if !ok {
+		b = newBucket(l.cfg.RequestsPerSecond, float64(l.cfg.RequestBurst))
+		l.clientRates[client] = b
+	}
+
+	return b.allow(now)
+}
+
+// sweep drops the rate state of the idle clients, their buckets are full again.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleClientTimeout {
+		return
+	}
+	l.lastSweep = now
+
+	for client, b := range l.clientRates {
+		if now.Sub(b.last) > idleClientTimeout {
+			delete(l.clientRates, client)
+		}
+	}
+}
+
+// Reason describes why a stream was rejected.
+type Reason string
+
+// Stream rejection reasons returned by AcquireStream.
+const (
+	ReasonNone             Reason = ""
+	ReasonMaxStreams       Reason = "max_streams"
+	ReasonMaxClientStreams Reason = "max_streams_per_client"
+)
+
+// AcquireStream reserves a stream slot for a client. If a slot is available, the returned function must be
+// called once the stream is closed. Otherwise the function is nil and the reason is returned.
+func (l *Limiter) AcquireStream(client string) (func(), Reason) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.MaxStreams > 0 && l.streams >= l.cfg.MaxStreams {
+		return nil, ReasonMaxStreams
+	}
+
+	if l.cfg.MaxStreamsPerClient > 0 && l.clientStreams[client] >= l.cfg.MaxStreamsPerClient {
+		return nil, ReasonMaxClientStreams
+	}
+
+	l.streams++
+	l.clientStreams[client]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.releaseStream(client)
+		})
+	}, ReasonNone
+}
+
+func (l *Limiter) releaseStream(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.streams--
+	l.clientStreams[client]--
+	if l.clientStreams[client] <= 0 {
+		delete(l.clientStreams, client)
+	}
+}
+
+// Usage returns the configured limits and the number of open streams in total and per client.
+func (l *Limiter) Usage() Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	usage := Usage{
+		Limits: UsageLimits{
+			MaxStreams:           l.cfg.MaxStreams,
+			MaxStreamsPerClient:  l.cfg.MaxStreamsPerClient,
+			RequestsPerSecond:    l.cfg.RequestsPerSecond,
+			RequestBurst:         l.cfg.RequestBurst,
+			StreamBytesPerSecond: l.cfg.StreamBytesPerSecond,
+		},
+		Streams: l.streams,
+		Clients: make(map[string]int, len(l.clientStreams)),
+	}
+
+	for client, n := range l.clientStreams {
+		usage.Clients[client] = n
+	}
+
+	return usage
+}
@@ -0,0 +1,126 @@
+package limit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireStream(t *testing.T) {
+	l := New(Config{MaxStreams: 3, MaxStreamsPerClient: 2})
+
+	release1, reason := l.AcquireStream("a")
+	if release1 == nil {
+		t.Fatalf("expect first stream to be allowed. Got %s", reason)
+	}
+
+	release2, _ := l.AcquireStream("a")
+	if release2 == nil {
+		t.Fatal("expect second stream to be allowed")
+	}
+
+	if release, reason := l.AcquireStream("a"); release != nil || reason != ReasonMaxClientStreams {
+		t.Fatalf("expect %s. Got %s", ReasonMaxClientStreams, reason)
+	}
+
+	release3, _ := l.AcquireStream("b")
+	if release3 == nil {
+		t.Fatal("expect a stream for another client to be allowed")
+	}
+
+	if release, reason := l.AcquireStream("c"); release != nil || reason != ReasonMaxStreams {
+		t.Fatalf("expect %s. Got %s", ReasonMaxStreams, reason)
+	}
+
+	usage := l.Usage()
+	if usage.Streams != 3 || usage.Clients["a"] != 2 || usage.Clients["b"] != 1 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	// release is idempotent
+	release1()
+	release1()
+
+	if release, _ := l.AcquireStream("c"); release == nil {
+		t.Fatal("expect a stream to be allowed after release")
+	}
+
+	usage = l.Usage()
+	if usage.Streams != 3 || usage.Clients["a"] != 1 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+}
+
+func TestAllowRequest(t *testing.T) {
+	l := New(Config{RequestsPerSecond: 1, RequestBurst: 2})
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.AllowRequest("a"); !ok {
+			t.Fatalf("expect request %d to be allowed within the burst", i)
+		}
+	}
+
+	ok, retryAfter := l.AllowRequest("a")
+	if ok {
+		t.Fatal("expect request above the burst to be rejected")
+	}
+
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Fatalf("expect retry after up to 1s. Got %s", retryAfter)
+	}
+
+	if ok, _ := l.AllowRequest("b"); !ok {
+		t.Fatal("expect other client to be allowed")
+	}
+
+	// no limit
+	l = New(Config{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.AllowRequest("a"); !ok {
+			t.Fatal("expect all requests to be allowed without a limit")
+		}
+	}
+}
+
+func TestThrottledWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewThrottledWriter(context.Background(), buf, 100)
+
+	var slept time.Duration
+	w.wait = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	// the first second worth of data is written without a delay.
+	w.Write(make([]byte, 100))
+	if slept != 0 {
+		t.Fatalf("expect no delay within the burst. Got %s", slept)
+	}
+
+	w.Write(make([]byte, 50))
+	if slept < 400*time.Millisecond || slept > 500*time.Millisecond {
+		t.Fatalf("expect about 500ms delay. Got %s", slept)
+	}
+
+	if buf.Len() != 150 {
+		t.Fatalf("expect all bytes to be written. Got %d", buf.Len())
+	}
+}
+
+func TestThrottledWriterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	buf := &bytes.Buffer{}
+	w := NewThrottledWriter(ctx, buf, 1)
+
+	// the client disconnects while the write waits for about 100 seconds.
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := w.Write(make([]byte, 100)); err != context.Canceled {
+		t.Fatalf("expect context.Canceled. Got %v", err)
+	}
+
+	if buf.Len() != 0 {
+		t.Fatalf("expect no bytes to be written. Got %d", buf.Len())
+	}
+}
@@ -0,0 +1,52 @@
+package limit
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ThrottledWriter limits the throughput of the underlying writer. A write blocks until the written bytes
+// fit into the configured rate, so io.Copy into the writer respects the limit. A blocked write returns
+// the context error once the context is done, e.g. when the client disconnects.
+type ThrottledWriter struct {
+	io.Writer
+
+	ctx    context.Context
+	bucket *bucket
+	wait   func(ctx context.Context, d time.Duration) error
+}
+
+// NewThrottledWriter returns a writer limited to bytesPerSecond. The writer may write up to one second
+// worth of data at once.
+func NewThrottledWriter(ctx context.Context, w io.Writer, bytesPerSecond int) *ThrottledWriter {
+	return &ThrottledWriter{
+		Writer: w,
+		ctx:    ctx,
+		bucket: newBucket(float64(bytesPerSecond), float64(bytesPerSecond)),
+		wait:   wait,
+	}
+}
+
+// wait blocks for the duration or until the context is done.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Write implements io.Writer.
+func (t *ThrottledWriter) Write(p []byte) (int, error) {
+	if d := t.bucket.reserve(time.Now(), float64(len(p))); d > 0 {
+		if err := t.wait(t.ctx, d); err != nil {
+			return 0, err
+		}
+	}
+	return t.Writer.Write(p)
+}
@@ -0,0 +1,277 @@
+package reader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The archive formats supported by Archive.Write.
+const (
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+var (
+	// ErrArchiveTooLarge is returned by NewArchive if the files are above the max size or the directory tree is
+	// too deep to be listed.
+	ErrArchiveTooLarge = errors.New("archive is too large")
+)
+
+// Archive is a set of sandbox files written to a tar.gz or zip archive. The files are downloaded one by one while
+// the archive is written, so only a single file is kept at once.
+type Archive struct {
+	rm       *ReadManager
+	dir      string
+	endpoint url.URL
+
+	// Name is the top directory of the archive, the base name of the archived directory.
+	Name string
+
+	Files []SandboxFile
+
+	// Size is the total size of the files.
+	Size uint64
+}
+
+// NewArchive lists the files in a sandbox directory, relative to the sandbox, and its subdirectories. If glob is set,
+// only the matching files are archived, see BrowseTree. If maxSize is above 0 and the files are larger,
+// ErrArchiveTooLarge is returned. The ReadManager must be created with the browse endpoint of the agent,
+// the files are downloaded from the download endpoint next to it.
+func (rm *ReadManager) NewArchive(ctx context.Context, dir, glob string, maxSize uint64) (*Archive, error) {
+	dir = path.Join(rm.sandboxPath, path.Clean("/"+dir))
+	tree, err := rm.browseTree(ctx, dir, MaxBrowseDepth, glob)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{
+		rm:       rm,
+		dir:      dir,
+		endpoint: rm.readEndpoint,
+		Name:     path.Base(dir),
+	}
+	a.endpoint.Path = path.Join(path.Dir(rm.readEndpoint.Path), "download")
+
+	if err := a.add(tree); err != nil {
+		return nil, err
+	}
+
+	if maxSize > 0 && a.Size > maxSize {
+		return nil, ErrArchiveTooLarge
+	}
+	return a, nil
+}
+
+// add adds the files of the directory tree.
+func (a *Archive) add(node *SandboxNode) error {
+	for _, child := range node.Children {
+		switch {
+		case child.Truncated:
+			return ErrArchiveTooLarge
+		case child.Error != "":
+			logrus.Warnf("unable to archive directory %s: %s", child.Path, child.Error)
+		case child.Dir:
+			if err := a.add(child); err != nil {
+				return err
+			}
+		default:
+			a.Files = append(a.Files, child.SandboxFile)
+			a.Size += child.Size
+		}
+	}
+	return nil
+}
+
+// Write writes the archive in the format to w. If transform is set, it's applied to the content of the files,
+// e.g. to redact it. The tar header has the file size, so the transformed content of a tar.gz archive is spooled
+// to a temporary file first, one file at once. An error is returned once the archive is partially written,
+// the client gets a broken archive.
+func (a *Archive) Write(ctx context.Context, w io.Writer, format string, transform func(io.Reader) io.Reader) error {
+	switch format {
+	case ArchiveTarGz:
+		return a.writeTarGz(ctx, w, transform)
+	case ArchiveZip:
+		return a.writeZip(ctx, w, transform)
+	}
+	return fmt.Errorf("unsupported archive format %q. Supported formats: %s, %s", format, ArchiveTarGz, ArchiveZip)
+}
+
+// entryName returns the name of a file in the archive.
+func (a *Archive) entryName(f SandboxFile) string {
+	return path.Join(a.Name, strings.TrimPrefix(f.Path, a.dir))
+}
+
+// open downloads a file. It returns nil if the file was removed since it was listed.
+func (a *Archive) open(ctx context.Context, f SandboxFile) (io.ReadCloser, error) {
+	resp, err := a.rm.download(ctx, a.endpoint, f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, nil
+	}
+
+	resp.Body.Close()
+	return nil, fmt.Errorf("unable to download %s: bad status %d", f.Path, resp.StatusCode)
+}
+
+func (a *Archive) writeTarGz(ctx context.Context, w io.Writer, transform func(io.Reader) io.Reader) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, f := range a.Files {
+		body, err := a.open(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		if body == nil {
+			continue
+		}
+
+		if transform != nil {
+			err = a.writeTransformedTarFile(tw, f, body, transform)
+		} else {
+			err = a.writeTarFile(tw, f, body)
+		}
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeTarFile writes a file to the tar archive. The tar header has the file size, the file may have changed
+// since it was listed, so the content is cut or padded with zeros to the listed size.
+func (a *Archive) writeTarFile(tw *tar.Writer, f SandboxFile, body io.Reader) error {
+	size := int64(f.Size)
+	header := &tar.Header{
+		Name:     a.entryName(f),
+		Mode:     permissions(f.Mode),
+		Size:     size,
+		ModTime:  time.Unix(int64(f.MTime), 0),
+		Typeflag: tar.TypeReg,
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	n, err := io.Copy(tw, io.LimitReader(body, size))
+	if err != nil {
+		return err
+	}
+
+	if n < size {
+		_, err = io.CopyN(tw, zeroReader{}, size-n)
+	}
+	return err
+}
+
+// writeTransformedTarFile writes a file with the transformed content to the tar archive. The size of the transformed
+// content is not known until it's read, the content is written to a temporary file removed once it's archived.
+func (a *Archive) writeTransformedTarFile(tw *tar.Writer, f SandboxFile, body io.Reader,
+	transform func(io.Reader) io.Reader) error {
+	tmp, err := ioutil.TempFile("", "dcos-log-archive-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// a growing file is cut at the listed size before it's transformed, like in zip archives.
+	size, err := io.Copy(tmp, transform(io.LimitReader(body, int64(f.Size))))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	f.Size = uint64(size)
+	return a.writeTarFile(tw, f, tmp)
+}
+
+func (a *Archive) writeZip(ctx context.Context, w io.Writer, transform func(io.Reader) io.Reader) error {
+	zw := zip.NewWriter(w)
+
+	for _, f := range a.Files {
+		body, err := a.open(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		if body == nil {
+			continue
+		}
+
+		header := &zip.FileHeader{
+			Name:     a.entryName(f),
+			Method:   zip.Deflate,
+			Modified: time.Unix(int64(f.MTime), 0),
+		}
+		header.SetMode(os.FileMode(permissions(f.Mode)))
+
+		fw, err := zw.CreateHeader(header)
+		if err == nil {
+			// a growing file is cut at the listed size, so the archive stays within the max size.
+			r := io.LimitReader(body, int64(f.Size))
+			if transform != nil {
+				r = transform(r)
+			}
+			_, err = io.Copy(fw, r)
+		}
+
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// permissions returns the permission bits of a file mode listed by files API, e.g. -rw-r--r--.
+func permissions(mode string) int64 {
+	var perm int64
+	for i := 1; i < len(mode) && i < 10; i++ {
+		if mode[i] != '-' {
+			perm |= 1 << uint(9-i)
+		}
+	}
+	return perm
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
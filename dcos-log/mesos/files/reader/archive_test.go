@@ -0,0 +1,258 @@
+package reader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+)
+
+// newArchiveServer returns a fake mesos files API listing the directories and serving the file contents.
+func newArchiveServer(t *testing.T, dirs map[string][]SandboxFile, contents map[string]string) *url.URL {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Query().Get("path")
+		switch r.URL.Path {
+		case "/files/browse":
+			files, ok := dirs[p]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			if err := json.NewEncoder(w).Encode(files); err != nil {
+				t.Error(err)
+			}
+		case "/files/download":
+			content, ok := contents[p]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, content)
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	browseURL, err := url.Parse(ts.URL + "/files/browse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return browseURL
+}
+
+func newTestArchive(t *testing.T, dir, glob string, maxSize uint64) (*Archive, error) {
+	sandbox := path.Join(DefaultSandboxRoot, "1/frameworks/2/executors/3/runs/4")
+	dirEntry := func(p string) SandboxFile { return SandboxFile{Path: path.Join(sandbox, p), Mode: "drwxr-xr-x"} }
+	file := func(p string, size uint64) SandboxFile {
+		return SandboxFile{Path: path.Join(sandbox, p), Mode: "-rw-r-----", Size: size, MTime: 1500000000}
+	}
+
+	browseURL := newArchiveServer(t, map[string][]SandboxFile{
+		sandbox:                    {file("stdout", 6), file("removed", 3), file("grown", 2), dirEntry("logs")},
+		path.Join(sandbox, "logs"): {file("logs/app.log", 4), file("logs/config.yml", 3)},
+	}, map[string]string{
+		path.Join(sandbox, "stdout"):          "hello\n",
+		path.Join(sandbox, "grown"):           "abcd",
+		path.Join(sandbox, "logs/app.log"):    "log\n",
+		path.Join(sandbox, "logs/config.yml"): "a:1",
+	})
+
+	r, err := NewLineReader(context.Background(), &http.Client{}, *browseURL, "1", "2", "3", "4", "", "stdout", LineFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r.NewArchive(context.Background(), dir, glob, maxSize)
+}
+
+// readTarGz returns the contents of the files in a tar.gz archive by name.
+func readTarGz(t *testing.T, r io.Reader) map[string]string {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if header.Mode != 0640 || header.ModTime.Unix() != 1500000000 {
+			t.Fatalf("%s: unexpected mode %o or mtime %s", header.Name, header.Mode, header.ModTime)
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(content)
+	}
+	return files
+}
+
+func TestArchiveTarGz(t *testing.T) {
+	archive, err := newTestArchive(t, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if archive.Name != "4" || len(archive.Files) != 5 || archive.Size != 18 {
+		t.Fatalf("expect 5 files of 18 bytes in 4. Got %d files of %d bytes in %s", len(archive.Files), archive.Size,
+			archive.Name)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := archive.Write(context.Background(), buf, ArchiveTarGz, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readTarGz(t, buf)
+
+	// the removed file is skipped, the grown file is cut to the listed size.
+	expected := map[string]string{
+		"4/stdout":          "hello\n",
+		"4/grown":           "ab",
+		"4/logs/app.log":    "log\n",
+		"4/logs/config.yml": "a:1",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expect %v. Got %v", expected, files)
+	}
+	for name, content := range expected {
+		if files[name] != content {
+			t.Fatalf("%s: expect %q. Got %q", name, content, files[name])
+		}
+	}
+}
+
+func TestArchiveTarGzTransformed(t *testing.T) {
+	archive, err := newTestArchive(t, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	redact := func(r io.Reader) io.Reader {
+		b, _ := ioutil.ReadAll(r)
+		return strings.NewReader(strings.Replace(string(b), "l", "[REDACTED]", -1))
+	}
+	if err := archive.Write(context.Background(), buf, ArchiveTarGz, redact); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tar headers have the sizes of the transformed content, the grown file is cut before it's transformed.
+	files := readTarGz(t, buf)
+	expected := map[string]string{
+		"4/stdout":          "he[REDACTED][REDACTED]o\n",
+		"4/grown":           "ab",
+		"4/logs/app.log":    "[REDACTED]og\n",
+		"4/logs/config.yml": "a:1",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expect %v. Got %v", expected, files)
+	}
+	for name, content := range expected {
+		if files[name] != content {
+			t.Fatalf("%s: expect %q. Got %q", name, content, files[name])
+		}
+	}
+}
+
+func TestArchiveZip(t *testing.T) {
+	archive, err := newTestArchive(t, "/logs", "*.log", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	upper := func(r io.Reader) io.Reader {
+		b, _ := ioutil.ReadAll(r)
+		return strings.NewReader(strings.ToUpper(string(b)))
+	}
+	if err := archive.Write(context.Background(), buf, ArchiveZip, upper); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(zr.File) != 1 || zr.File[0].Name != "logs/app.log" {
+		t.Fatalf("expect logs/app.log only. Got %d files", len(zr.File))
+	}
+
+	rc, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "LOG\n" {
+		t.Fatalf("expect transformed content. Got %q", content)
+	}
+
+	// the grown file is cut to the listed size.
+	archive, err = newTestArchive(t, "", "grown", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := archive.Write(context.Background(), buf, ArchiveZip, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(zr.File) != 1 || zr.File[0].UncompressedSize64 != 2 {
+		t.Fatalf("expect grown file of 2 bytes. Got %d files", len(zr.File))
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	if _, err := newTestArchive(t, "", "", 10); err != ErrArchiveTooLarge {
+		t.Fatalf("expect ErrArchiveTooLarge. Got %v", err)
+	}
+
+	// the path cannot point outside of the sandbox.
+	if archive, err := newTestArchive(t, "../../..", "", 0); err != nil || archive.Name != "4" {
+		t.Fatalf("expect the sandbox archive. Got %v", err)
+	}
+
+	archive, err := newTestArchive(t, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := archive.Write(context.Background(), ioutil.Discard, "rar", nil); err == nil {
+		t.Fatal("expect unsupported format error")
+	}
+}
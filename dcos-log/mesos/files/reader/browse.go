@@ -0,0 +1,209 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultBrowseDepth is a number of directory levels listed by BrowseTree if the depth is not set.
+	DefaultBrowseDepth = 5
+
+	// MaxBrowseDepth is a maximum number of directory levels listed by BrowseTree.
+	MaxBrowseDepth = 20
+
+	// maxBrowseDirs is a maximum number of directories listed by BrowseTree, the rest is marked as truncated.
+	maxBrowseDirs = 500
+)
+
+// logFile matches the names of stdout, stderr, *.log and the rotated files, e.g. stdout.1 or app.log.2.gz.
+var logFile = regexp.MustCompile(`^(stdout|stderr)(\.\d+)?(\.gz)?$|\.log(\.[\w-]+)*$`)
+
+// IsLogFile returns true if the file name looks like a log file.
+func IsLogFile(name string) bool {
+	return logFile.MatchString(name)
+}
+
+// SandboxNode is a file or a directory in the sandbox tree returned by BrowseTree.
+type SandboxNode struct {
+	SandboxFile
+
+	Name string `json:"name"`
+	Dir  bool   `json:"dir"`
+
+	// Log is set for the files which look like log files.
+	Log bool `json:"log,omitempty"`
+
+	// TotalSize is the size of the files in the directory and its subdirectories.
+	TotalSize uint64 `json:"total_size,omitempty"`
+
+	// Truncated is set for the directories which were not listed because of the depth or the number of directories.
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Error is set if the directory could not be listed.
+	Error string `json:"error,omitempty"`
+
+	Children []*SandboxNode `json:"children,omitempty"`
+}
+
+// browseDir returns the files in a sandbox directory.
+func (rm *ReadManager) browseDir(ctx context.Context, dir string) ([]SandboxFile, error) {
+	v := url.Values{}
+	v.Add(pathParam, dir)
+
+	newURL := rm.readEndpoint
+	newURL.RawQuery = v.Encode()
+
+	req, err := http.NewRequest("GET", newURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = rm.header
+
+	ctx, cancel := context.WithTimeout(ctx, rm.timeout)
+	defer cancel()
+
+	resp, err := rm.roundTrip(req.WithContext(ctx))
+	if err == ErrAgentUnavailable {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to make a GET request: %s. URL %s", err, newURL.String())
+	}
+
+	logrus.Debugf("sandbox browse %s", newURL.String())
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		break
+	case http.StatusNotFound:
+		return nil, ErrFileNotFound
+	default:
+		return nil, fmt.Errorf("bad status %d. URL %s", resp.StatusCode, newURL.String())
+	}
+
+	var files []SandboxFile
+
+	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil {
+		return nil, fmt.Errorf("unable to decode files API response: %s. URL %s", err, newURL.String())
+	}
+
+	return files, nil
+}
+
+// BrowseTree returns the sandbox files and directories up to depth levels, 1 lists the sandbox directory only.
+// If glob is set, only the files with a matching name or path relative to the sandbox are returned, along with
+// the directories containing them. The directories which could not be listed have Error set, BrowseTree fails
+// only if the sandbox itself could not be listed.
+func (rm *ReadManager) BrowseTree(ctx context.Context, depth int, glob string) (*SandboxNode, error) {
+	return rm.browseTree(ctx, rm.sandboxPath, depth, glob)
+}
+
+// browseTree lists the directory tree of a sandbox directory.
+func (rm *ReadManager) browseTree(ctx context.Context, dir string, depth int, glob string) (*SandboxNode, error) {
+	if depth <= 0 || depth > MaxBrowseDepth {
+		return nil, fmt.Errorf("invalid depth %d. Must be between 1 and %d", depth, MaxBrowseDepth)
+	}
+
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %s", glob, err)
+	}
+
+	root := &SandboxNode{
+		SandboxFile: SandboxFile{Path: dir},
+		Dir:         true,
+	}
+
+	w := &treeWalker{rm: rm, root: dir, glob: glob}
+	if err := w.walk(ctx, root, depth); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// treeWalker lists the sandbox directories for BrowseTree.
+type treeWalker struct {
+	rm   *ReadManager
+	root string
+	glob string
+	dirs int
+}
+
+// match returns true if the file matches the glob.
+func (w *treeWalker) match(filePath string) bool {
+	if w.glob == "" {
+		return true
+	}
+
+	name := path.Base(filePath)
+	if strings.Contains(w.glob, "/") {
+		name = strings.TrimPrefix(strings.TrimPrefix(filePath, w.root), "/")
+	}
+
+	matched, _ := path.Match(w.glob, name)
+	return matched
+}
+
+func (w *treeWalker) walk(ctx context.Context, node *SandboxNode, depth int) error {
+	files, err := w.rm.browseDir(ctx, node.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		child := &SandboxNode{
+			SandboxFile: file,
+			Name:        path.Base(file.Path),
+			Dir:         strings.HasPrefix(file.Mode, "d"),
+		}
+
+		if !child.Dir {
+			if !w.match(file.Path) {
+				continue
+			}
+
+			child.Log = IsLogFile(child.Name)
+			node.TotalSize += file.Size
+			node.Children = append(node.Children, child)
+			continue
+		}
+
+		switch {
+		case depth <= 1 || w.dirs >= maxBrowseDirs:
+			child.Truncated = true
+		default:
+			w.dirs++
+			err := w.walk(ctx, child, depth-1)
+			// the agent or the client went away, the rest of the tree can't be listed.
+			if err == ErrAgentUnavailable {
+				return err
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+
+			if err != nil {
+				child.Error = err.Error()
+			} else if w.glob != "" && len(child.Children) == 0 {
+				// no matching files.
+				continue
+			}
+		}
+
+		node.TotalSize += child.TotalSize
+		node.Children = append(node.Children, child)
+	}
+
+	return nil
+}
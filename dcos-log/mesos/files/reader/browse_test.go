@@ -0,0 +1,116 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+)
+
+// newBrowseServer returns a fake mesos files API browse endpoint listing the directories.
+func newBrowseServer(t *testing.T, dirs map[string][]SandboxFile) *url.URL {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		files, ok := dirs[r.URL.Query().Get("path")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(files); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return masterURL
+}
+
+func TestIsLogFile(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"stdout":                true,
+		"stderr.1":              true,
+		"stdout.2.gz":           true,
+		"app.log":               true,
+		"app.log.1":             true,
+		"app.log.2018-01-02.gz": true,
+		"stdout.logrotate.conf": false,
+		"config.yml":            false,
+		"logs":                  false,
+	} {
+		if IsLogFile(name) != expected {
+			t.Fatalf("%s: expect %t", name, expected)
+		}
+	}
+}
+
+func TestBrowseTree(t *testing.T) {
+	sandbox := path.Join(DefaultSandboxRoot, "1/frameworks/2/executors/3/runs/4")
+	dir := func(p string) SandboxFile { return SandboxFile{Path: path.Join(sandbox, p), Mode: "drwxr-xr-x"} }
+	file := func(p string, size uint64) SandboxFile {
+		return SandboxFile{Path: path.Join(sandbox, p), Mode: "-rw-r--r--", Size: size}
+	}
+
+	masterURL := newBrowseServer(t, map[string][]SandboxFile{
+		sandbox:                            {file("stdout", 10), file("stderr", 20), dir("tasks"), dir("missing")},
+		path.Join(sandbox, "tasks"):        {dir("tasks/a"), file("tasks/config.yml", 5)},
+		path.Join(sandbox, "tasks/a"):      {file("tasks/a/app.log", 100), dir("tasks/a/deep")},
+		path.Join(sandbox, "tasks/a/deep"): {file("tasks/a/deep/app.log", 1000)},
+	})
+
+	r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "", "stdout", LineFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := r.BrowseTree(context.Background(), 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.TotalSize != 135 || len(tree.Children) != 4 {
+		t.Fatalf("expect 4 entries of 135 bytes. Got %d entries of %d bytes", len(tree.Children), tree.TotalSize)
+	}
+
+	if stdout := tree.Children[0]; stdout.Name != "stdout" || !stdout.Log || stdout.Dir {
+		t.Fatalf("expect stdout log file. Got %+v", stdout)
+	}
+
+	if missing := tree.Children[3]; missing.Error == "" {
+		t.Fatalf("expect an error listing the missing directory. Got %+v", missing)
+	}
+
+	tasks := tree.Children[2]
+	if tasks.TotalSize != 105 || tasks.Children[1].Log {
+		t.Fatalf("unexpected tasks directory %+v", tasks)
+	}
+
+	a := tasks.Children[0]
+	if !a.Children[0].Log || !a.Children[1].Truncated || a.Children[1].Children != nil {
+		t.Fatalf("expect the deep directory to be truncated. Got %+v", a)
+	}
+
+	tree, err = r.BrowseTree(context.Background(), MaxBrowseDepth, "*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the directories with the matching files are returned.
+	if tree.TotalSize != 1100 || len(tree.Children) != 2 || tree.Children[0].Name != "tasks" {
+		t.Fatalf("expect the tasks directory with 1100 bytes. Got %+v", tree)
+	}
+
+	if _, err := r.BrowseTree(context.Background(), 1, "["); err == nil {
+		t.Fatal("expect an invalid glob error")
+	}
+
+	if _, err := r.BrowseTree(context.Background(), 0, ""); err == nil {
+		t.Fatal("expect an invalid depth error")
+	}
+}
@@ -0,0 +1,102 @@
+package reader
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var errInvalidData = errors.New("invalid files API data string")
+
+// fileData is the file content returned by files API. Mesos writes the file bytes into a JSON string as is,
+// so a chunk may start or end in the middle of a multi-byte character. encoding/json replaces such bytes with
+// U+FFFD and the offsets calculated from the decoded string would not match the file. fileData keeps
+// the original bytes.
+type fileData string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *fileData) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*d = ""
+		return nil
+	}
+
+	s, err := unquoteBytes(b)
+	if err != nil {
+		return err
+	}
+	*d = fileData(s)
+	return nil
+}
+
+// unquoteBytes decodes a JSON string. Unlike encoding/json, the bytes which are not valid UTF-8 are kept.
+func unquoteBytes(b []byte) (string, error) {
+	if len(b) < 2 || b[0] != '"' || b[len(b)-1] != '"' {
+		return "", errInvalidData
+	}
+	b = b[1 : len(b)-1]
+
+	buf := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c != '\\' {
+			buf = append(buf, c)
+			continue
+		}
+
+		i++
+		if i == len(b) {
+			return "", errInvalidData
+		}
+
+		switch b[i] {
+		case '"', '\\', '/':
+			buf = append(buf, b[i])
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r, ok := hexRune(b[i+1:])
+			if !ok {
+				return "", errInvalidData
+			}
+			i += 4
+
+			// a character outside of the basic plane is encoded as a surrogate pair.
+			if utf16.IsSurrogate(r) && i+2 < len(b) && b[i+1] == '\\' && b[i+2] == 'u' {
+				if r2, ok := hexRune(b[i+3:]); ok {
+					if decoded := utf16.DecodeRune(r, r2); decoded != utf8.RuneError {
+						r = decoded
+						i += 6
+					}
+				}
+			}
+			buf = append(buf, string(r)...)
+		default:
+			return "", errInvalidData
+		}
+	}
+
+	return string(buf), nil
+}
+
+// hexRune parses 4 hex digits of \u escape sequence.
+func hexRune(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+
+	r, err := strconv.ParseUint(string(b[:4]), 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(r), true
+}
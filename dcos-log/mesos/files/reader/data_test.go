@@ -0,0 +1,46 @@
+package reader
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnquoteBytes(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected string
+	}{
+		{input: `"plain"`, expected: "plain"},
+		{input: `"a\nb\r\n\t\"\\\/"`, expected: "a\nb\r\n\t\"\\/"},
+		{input: `"é日"`, expected: "é日"},
+		{input: `"😀"`, expected: "😀"},
+		// a chunk cut in the middle of multi-byte characters.
+		{input: "\"\x97\xa5\xe6\x9c\xac\xe8\"", expected: "\x97\xa5本\xe8"},
+	} {
+		actual, err := unquoteBytes([]byte(tc.input))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.input, err)
+		}
+
+		if actual != tc.expected {
+			t.Fatalf("%s: expect %q. Got %q", tc.input, tc.expected, actual)
+		}
+	}
+
+	for _, input := range []string{``, `"`, `plain`, `"\x"`, `"\u12"`, `"abc\"`} {
+		if _, err := unquoteBytes([]byte(input)); err == nil {
+			t.Fatalf("expect an error for %s", input)
+		}
+	}
+}
+
+func TestFileDataUnmarshal(t *testing.T) {
+	resp := &response{}
+	if err := json.Unmarshal([]byte("{\"data\":\"\xe6\x97\",\"offset\":10}"), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Data != "\xe6\x97" || resp.Offset != 10 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
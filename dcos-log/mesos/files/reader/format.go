@@ -3,7 +3,9 @@ package reader
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
+	"github.com/dcos/dcos-log/dcos-log/redact"
 	"github.com/sirupsen/logrus"
 )
 
@@ -31,18 +33,88 @@ func SSEFormat(l Line, rm *ReadManager) (output string) {
 	return output
 }
 
+// JSONFormat formats the lines as newline delimited JSON objects, like the journal entries with application/json.
+func JSONFormat(l Line, rm *ReadManager) string {
+	jsonLine, err := jsonifyLine(l, rm)
+	if err != nil {
+		logrus.Errorf("error getting structured message, falling back to simple text")
+		return l.Message + "\n"
+	}
+	return jsonLine.Message + "\n"
+}
+
 // LineFormat is a simple \readLimit separates format.
 func LineFormat(l Line, rm *ReadManager) string {
 	return l.Message + "\n"
 }
 
+// Redacted wraps a Formatter and applies redaction rules to the line message before the line is formatted.
+// Line offset and size are not modified, they still point to the original content of the file.
+func Redacted(format Formatter, redactor *redact.Redactor) Formatter {
+	if redactor == nil {
+		return format
+	}
+
+	return func(l Line, rm *ReadManager) string {
+		l.Message = redactor.Redact(l.Message)
+		if l.Fields != nil {
+			fields := make(map[string]interface{}, len(l.Fields))
+			for key, value := range l.Fields {
+				fields[key] = redactValue(value, redactor)
+			}
+			l.Fields = fields
+		}
+		return format(l, rm)
+	}
+}
+
+// redactValue applies the redactor to the strings of a parsed JSON value.
+func redactValue(value interface{}, redactor *redact.Redactor) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redactor.Redact(v)
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i := range v {
+			redacted[i] = redactValue(v[i], redactor)
+		}
+		return redacted
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			redacted[key] = redactValue(item, redactor)
+		}
+		return redacted
+	}
+	return value
+}
+
 func jsonifyLine(l Line, rm *ReadManager) (*Line, error) {
-	msg := l.Message
+	fields := make(map[string]interface{}, len(l.Fields)+6)
+	// the parsed fields of a JSON line must not override the task fields.
+	for key, value := range l.Fields {
+		fields[key] = value
+	}
+
+	if _, ok := fields[FieldMessage]; !ok {
+		fields[FieldMessage] = l.Message
+	}
+
+	for key, value := range map[string]string{"AGENT_ID": rm.agentID, "EXECUTOR_ID": rm.executorID,
+		"FRAMEWORK_ID": rm.frameworkID, "CONTAINER_ID": rm.containerID, "FILE": rm.file} {
+		fields[key] = value
+	}
+
+	// the timestamp is in microseconds like the journal realtime timestamp.
 	structMsg := struct {
-		Fields map[string]interface{} `json:"fields"`
+		Fields            map[string]interface{} `json:"fields"`
+		RealtimeTimestamp int64                  `json:"realtime_timestamp,omitempty"`
 	}{
-		Fields: map[string]interface{}{"MESSAGE": msg, "AGENT_ID": rm.agentID, "EXECUTOR_ID": rm.executorID,
-			"FRAMEWORK_ID": rm.frameworkID, "CONTAINER_ID": rm.containerID, "FILE": rm.file},
+		Fields: fields,
+	}
+
+	if !l.Timestamp.IsZero() {
+		structMsg.RealtimeTimestamp = l.Timestamp.UnixNano() / int64(time.Microsecond)
 	}
 
 	marshaledStructMessage, err := json.Marshal(structMsg)
@@ -1,8 +1,21 @@
 package reader
 
-// Line is a structure for a line message with offset.
+import "time"
+
+// Line is a structure for a line message with offset. Size is the size of the line in the file, it's larger
+// than the message if the line was truncated.
 type Line struct {
 	Message string
 	Offset  int
 	Size    int
+
+	// Truncated is a number of bytes dropped from the end of the line.
+	Truncated int
+
+	// Fields are the fields of a JSON line, set if the JSON parsing is enabled and the line is a JSON object.
+	Fields map[string]interface{}
+
+	// Timestamp is the time parsed from the line, or from the previous lines if the line has no timestamp.
+	// It's zero if no timestamp was found.
+	Timestamp time.Time
 }
@@ -1,10 +1,12 @@
 package reader
 
 import (
-	"context"
 	"fmt"
 	"net/http"
+	"path"
 	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/multiline"
 )
 
 // Option is a functional parameters interface.
@@ -34,6 +36,140 @@ func OptFile(f string) Option {
 	}
 }
 
+// OptSandboxRoot sets the mesos agent sandboxes directory, DefaultSandboxRoot is used if not set.
+// It must precede the options which read the file.
+func OptSandboxRoot(sandboxRoot string) Option {
+	return func(rm *ReadManager) error {
+		if !path.IsAbs(sandboxRoot) {
+			return fmt.Errorf("sandbox root must be an absolute path. Got %q", sandboxRoot)
+		}
+		rm.setSandboxPath(sandboxRoot)
+		return nil
+	}
+}
+
+// OptChunkSize sets a number of bytes requested from files API at once, DefaultChunkSize is used if not set.
+func OptChunkSize(n int) Option {
+	return func(rm *ReadManager) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid chunk size %d. Must be positive integer", n)
+		}
+		rm.chunkSize = n
+		return nil
+	}
+}
+
+// OptTimeout sets a timeout of a single files API request including the retries, DefaultTimeout is used if not set.
+// It must precede the options which read the file.
+func OptTimeout(timeout time.Duration) Option {
+	return func(rm *ReadManager) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid timeout %s. Must be positive duration", timeout)
+		}
+		rm.timeout = timeout
+		return nil
+	}
+}
+
+// OptMaxLineSize sets a maximum size of a line in bytes, DefaultMaxLineSize is used if not set. The longer lines
+// are truncated and end with TruncationMarker.
+func OptMaxLineSize(n int) Option {
+	return func(rm *ReadManager) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid max line size %d. Must be positive integer", n)
+		}
+		rm.maxLineSize = n
+		return nil
+	}
+}
+
+// OptParseJSON enables parsing of the lines which are JSON objects. The keys of a line are returned as the line
+// fields and can be matched with OptMatch.
+func OptParseJSON(mapping FieldMapping) Option {
+	return func(rm *ReadManager) error {
+		rm.parseJSON = true
+		rm.fieldMapping = mapping
+		return nil
+	}
+}
+
+// OptFieldMapping sets the keys of JSON lines without enabling the JSON parsing. The timestamp keys are used
+// to find the line timestamps with OptSince and OptUntil.
+func OptFieldMapping(mapping FieldMapping) Option {
+	return func(rm *ReadManager) error {
+		rm.fieldMapping = mapping
+		return nil
+	}
+}
+
+// OptMatch returns only the lines satisfying the matches. Without OptParseJSON only MESSAGE field can be matched.
+func OptMatch(matches []Match) Option {
+	return func(rm *ReadManager) error {
+		if rm.matches == nil {
+			rm.matches = make(map[string][]string)
+		}
+
+		for _, m := range matches {
+			if m.Field == "" {
+				return fmt.Errorf("match field cannot be empty. Got %+v", m)
+			}
+			rm.matches[m.Field] = append(rm.matches[m.Field], m.Value)
+		}
+		return nil
+	}
+}
+
+// OptTimestampLayouts sets the layouts of the line timestamps, DefaultTimestampLayouts are used if not set.
+// It must precede the options which read the file.
+func OptTimestampLayouts(layouts []string) Option {
+	return func(rm *ReadManager) error {
+		if len(layouts) == 0 {
+			return fmt.Errorf("timestamp layouts cannot be empty")
+		}
+		rm.timestampLayouts = layouts
+		return nil
+	}
+}
+
+// OptSince returns the lines with a timestamp at or after t. The file is bisected to find the first line,
+// the lines must be in time order. The lines without a timestamp before the first one with a timestamp
+// are skipped.
+func OptSince(t time.Time) Option {
+	return func(rm *ReadManager) error {
+		rm.since = t
+		return nil
+	}
+}
+
+// OptUntil stops reading at the first line with a timestamp after t.
+func OptUntil(t time.Time) Option {
+	return func(rm *ReadManager) error {
+		rm.until = t
+		return nil
+	}
+}
+
+// OptMultiline groups the lines of multi-line events, e.g. stack traces, into a single line. The lines of an event
+// are joined with a newline, skip and limit count the events.
+func OptMultiline(p *multiline.Pattern) Option {
+	return func(rm *ReadManager) error {
+		rm.multiline = p
+		return nil
+	}
+}
+
+// OptRetries sets a number of times a failed files API request is retried, DefaultRetries is used if not set.
+// 0 disables the retries. It must precede the options which read the file.
+func OptRetries(n int) Option {
+	return func(rm *ReadManager) error {
+		if n < 0 {
+			return fmt.Errorf("invalid number of retries %d. Must be zero or positive integer", n)
+		}
+		rm.retries = n
+		return nil
+	}
+}
+
 // OptHeaders sets the optional request header.
 func OptHeaders(h http.Header) Option {
 	return func(rm *ReadManager) error {
@@ -72,7 +208,7 @@ func OptOffset(offset int) Option {
 // OptReadFromEnd moves the cursor to the end of file.
 func OptReadFromEnd() Option {
 	return func(rm *ReadManager) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := rm.requestContext()
 		defer cancel()
 
 		offset, err := rm.fileLen(ctx)
@@ -0,0 +1,127 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// The fields set from the mapped keys of JSON log lines.
+const (
+	FieldLevel     = "LEVEL"
+	FieldTimestamp = "TIMESTAMP"
+	FieldMessage   = "MESSAGE"
+)
+
+// FieldMapping lists the keys of JSON log lines holding the level, timestamp and message. The first key present
+// in a line is used.
+type FieldMapping struct {
+	Level     []string
+	Timestamp []string
+	Message   []string
+}
+
+// DefaultFieldMapping covers the keys used by the common logging libraries.
+var DefaultFieldMapping = FieldMapping{
+	Level:     []string{"level", "severity", "lvl"},
+	Timestamp: []string{"timestamp", "time", "ts", "@timestamp"},
+	Message:   []string{"message", "msg"},
+}
+
+// Match is a filter on a line field. Like journal matches, the matches on the same field are combined with OR,
+// the matches on different fields with AND.
+type Match struct {
+	Field, Value string
+}
+
+// parseJSONLine returns the fields of a line which is a JSON object. The keys are uppercased like the journal
+// fields, the mapped keys are also set as LEVEL, TIMESTAMP and MESSAGE. If the line has no message key,
+// MESSAGE is the line itself.
+func parseJSONLine(msg string, mapping FieldMapping) (map[string]interface{}, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(msg), "{") {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(msg))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || decoder.More() {
+		return nil, false
+	}
+
+	fields := make(map[string]interface{}, len(object)+3)
+	for key, value := range object {
+		fields[strings.ToUpper(key)] = value
+	}
+
+	for field, keys := range map[string][]string{
+		FieldLevel:     mapping.Level,
+		FieldTimestamp: mapping.Timestamp,
+		FieldMessage:   mapping.Message,
+	} {
+		for _, key := range keys {
+			if value, ok := object[key]; ok {
+				fields[field] = value
+				break
+			}
+		}
+	}
+
+	if _, ok := fields[FieldMessage]; !ok {
+		fields[FieldMessage] = msg
+	}
+
+	return fields, true
+}
+
+// fieldString returns the value of a field used for matching, JSON encoded unless it's a string.
+func fieldString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// lineFields returns the fields of a line message, parsed if the JSON parsing is enabled.
+func (rm *ReadManager) lineFields(msg string) map[string]interface{} {
+	if rm.parseJSON {
+		if fields, ok := parseJSONLine(msg, rm.fieldMapping); ok {
+			return fields
+		}
+	}
+	return map[string]interface{}{FieldMessage: msg}
+}
+
+// match returns true if the line fields satisfy the matches.
+func (rm *ReadManager) match(fields map[string]interface{}) bool {
+	for field, values := range rm.matches {
+		value, ok := fields[field]
+		if !ok {
+			return false
+		}
+
+		matched := false
+		for _, v := range values {
+			if fieldString(value) == v {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
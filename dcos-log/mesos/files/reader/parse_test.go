@@ -0,0 +1,190 @@
+package reader
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/redact"
+)
+
+const jsonLogs = `{"level":"info","msg":"started","ts":"2018-01-01T00:00:00Z"}
+plain text line
+{"severity":"error","message":"failed","code":500}
+{"level":"error","msg":"retrying","nested":{"attempt":1}}
+{"level":"debug","msg":"done"}
+`
+
+func TestParseJSONLine(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected map[string]interface{}
+	}{
+		{
+			line: `{"level":"info","msg":"started","ts":"2018-01-01T00:00:00Z"}`,
+			expected: map[string]interface{}{"LEVEL": "info", "MSG": "started", "TS": "2018-01-01T00:00:00Z",
+				"MESSAGE": "started", "TIMESTAMP": "2018-01-01T00:00:00Z"},
+		},
+		{
+			line: ` {"severity":"error","code":500}`,
+			expected: map[string]interface{}{"SEVERITY": "error", "CODE": json.Number("500"), "LEVEL": "error",
+				"MESSAGE": ` {"severity":"error","code":500}`},
+		},
+	} {
+		fields, ok := parseJSONLine(tc.line, DefaultFieldMapping)
+		if !ok {
+			t.Fatalf("expect %s to be parsed", tc.line)
+		}
+
+		if !reflect.DeepEqual(fields, tc.expected) {
+			t.Fatalf("expect %v. Got %v", tc.expected, fields)
+		}
+	}
+
+	for _, line := range []string{"plain", `["array"]`, `{"broken":`, `{"a":1} {"b":2}`, `"string"`} {
+		if _, ok := parseJSONLine(line, DefaultFieldMapping); ok {
+			t.Fatalf("expect %s not to be parsed", line)
+		}
+	}
+}
+
+func TestFieldMapping(t *testing.T) {
+	mapping := FieldMapping{Level: []string{"lvl"}, Message: []string{"text"}}
+	fields, ok := parseJSONLine(`{"level":"info","lvl":"warn","text":"hello"}`, mapping)
+	if !ok {
+		t.Fatal("expect the line to be parsed")
+	}
+
+	if fields[FieldLevel] != "warn" || fields[FieldMessage] != "hello" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	if _, ok := fields[FieldTimestamp]; ok {
+		t.Fatalf("expect no timestamp. Got %v", fields)
+	}
+}
+
+func TestParseAndMatch(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{
+			name:     "level",
+			opts:     []Option{OptParseJSON(DefaultFieldMapping), OptMatch([]Match{{Field: "LEVEL", Value: "error"}})},
+			expected: "{\"severity\":\"error\",\"message\":\"failed\",\"code\":500}\n{\"level\":\"error\",\"msg\":\"retrying\",\"nested\":{\"attempt\":1}}\n",
+		},
+		{
+			name: "same field OR",
+			opts: []Option{OptParseJSON(DefaultFieldMapping),
+				OptMatch([]Match{{Field: "LEVEL", Value: "info"}, {Field: "LEVEL", Value: "debug"}})},
+			expected: "{\"level\":\"info\",\"msg\":\"started\",\"ts\":\"2018-01-01T00:00:00Z\"}\n{\"level\":\"debug\",\"msg\":\"done\"}\n",
+		},
+		{
+			name: "different fields AND",
+			opts: []Option{OptParseJSON(DefaultFieldMapping),
+				OptMatch([]Match{{Field: "LEVEL", Value: "error"}, {Field: "CODE", Value: "500"}})},
+			expected: "{\"severity\":\"error\",\"message\":\"failed\",\"code\":500}\n",
+		},
+		{
+			name: "nested value",
+			opts: []Option{OptParseJSON(DefaultFieldMapping),
+				OptMatch([]Match{{Field: "NESTED", Value: `{"attempt":1}`}})},
+			expected: "{\"level\":\"error\",\"msg\":\"retrying\",\"nested\":{\"attempt\":1}}\n",
+		},
+		{
+			name:     "message without parsing",
+			opts:     []Option{OptMatch([]Match{{Field: "MESSAGE", Value: "plain text line"}})},
+			expected: "plain text line\n",
+		},
+		{
+			name: "last matching lines",
+			opts: []Option{OptParseJSON(DefaultFieldMapping), OptMatch([]Match{{Field: "LEVEL", Value: "error"}}),
+				OptChunkSize(16), OptReadFromEnd(), OptSkip(-1), OptReadDirection(BottomToTop)},
+			expected: "{\"level\":\"error\",\"msg\":\"retrying\",\"nested\":{\"attempt\":1}}\n",
+		},
+		{
+			name: "limit counts matching lines",
+			opts: []Option{OptParseJSON(DefaultFieldMapping), OptMatch([]Match{{Field: "LEVEL", Value: "error"}}),
+				OptLines(1)},
+			expected: "{\"severity\":\"error\",\"message\":\"failed\",\"code\":500}\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newContentReader(t, jsonLogs, tc.opts...)
+			buf, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(buf) != tc.expected {
+				t.Fatalf("expect %q. Got %q", tc.expected, buf)
+			}
+		})
+	}
+}
+
+func TestSSEFormatParsedFields(t *testing.T) {
+	redactor, err := redact.New([]redact.Rule{{Pattern: "hunter2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rm := &ReadManager{agentID: "agent", frameworkID: "framework", executorID: "executor",
+		containerID: "container", file: "stdout"}
+	line := Line{
+		Message: `{"msg":"login","password":"hunter2","file":"app.go"}`,
+		Offset:  10,
+		Size:    52,
+	}
+	line.Fields, _ = parseJSONLine(line.Message, DefaultFieldMapping)
+
+	output := Redacted(SSEFormat, redactor)(line, rm)
+	if strings.Contains(output, "hunter2") {
+		t.Fatalf("expect the password to be redacted. Got %s", output)
+	}
+
+	data := strings.TrimSuffix(strings.SplitN(output, "data: ", 2)[1], "\n\n")
+	entry := struct {
+		Fields map[string]interface{} `json:"fields"`
+	}{}
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"MSG": "login", "MESSAGE": "login", "PASSWORD": "[REDACTED]", "FILE": "stdout", "AGENT_ID": "agent",
+		"FRAMEWORK_ID": "framework", "EXECUTOR_ID": "executor", "CONTAINER_ID": "container",
+	}
+	if !reflect.DeepEqual(entry.Fields, expected) {
+		t.Fatalf("expect %v. Got %v", expected, entry.Fields)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	rm := &ReadManager{agentID: "agent", frameworkID: "framework", executorID: "executor",
+		containerID: "container", file: "stdout"}
+	line := Line{Message: "hello", Timestamp: time.Unix(1514887200, 0)}
+
+	output := JSONFormat(line, rm)
+	if !strings.HasSuffix(output, "}\n") || strings.Count(output, "\n") != 1 {
+		t.Fatalf("expect a single JSON line. Got %q", output)
+	}
+
+	entry := struct {
+		Fields            map[string]interface{} `json:"fields"`
+		RealtimeTimestamp int64                  `json:"realtime_timestamp"`
+	}{}
+	if err := json.Unmarshal([]byte(output), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Fields["MESSAGE"] != "hello" || entry.Fields["AGENT_ID"] != "agent" ||
+		entry.RealtimeTimestamp != 1514887200000000 {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
@@ -14,12 +14,28 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/dcos/dcos-log/dcos-log/metrics"
+	"github.com/dcos/dcos-log/dcos-log/multiline"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	chunkSize = 1 << 16
+	// DefaultChunkSize is a number of bytes requested from files API at once.
+	DefaultChunkSize = 1 << 16
+
+	// DefaultTimeout is a timeout of a single files API request including the retries.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxLineSize is a maximum size of a line returned by Read. The longer lines are truncated.
+	DefaultMaxLineSize = 4 << 20
+
+	// TruncationMarker is appended to the truncated lines.
+	TruncationMarker = " [truncated]"
+
+	// DefaultSandboxRoot is the sandbox root of mesos agent started with the default --work_dir.
+	DefaultSandboxRoot = "/var/lib/mesos/slave/slaves"
 )
 
 const (
@@ -41,15 +57,28 @@ var (
 
 	// ErrFileNotFound is raised if the request file is not found in mesos files API.
 	ErrFileNotFound = errors.New("file not found")
+
+	// ErrAgentUnavailable is returned without making a request if the recent requests to the agent failed.
+	// The requests are allowed again after BreakerOpenTimeout.
+	ErrAgentUnavailable = errors.New("mesos agent is unavailable")
+)
+
+var (
+	filesAPIRequests = metrics.NewCounter("dcos_log_files_api_requests_total",
+		"Total number of requests to mesos files API by response code.", "code")
+	filesAPIErrors = metrics.NewCounter("dcos_log_files_api_errors_total",
+		"Total number of failed requests to mesos files API by reason.", "reason")
+	filesAPIDuration = metrics.NewHistogram("dcos_log_files_api_request_duration_seconds",
+		"Mesos files API request latency.", nil)
+	filesAPIRetries = metrics.NewCounter("dcos_log_files_api_retries_total",
+		"Total number of retried requests to mesos files API.")
 )
 
 type response struct {
-	Data   string `json:"data"`
-	Offset int    `json:"offset"`
+	Data   fileData `json:"data"`
+	Offset int      `json:"offset"`
 }
 
-type modifier func(s string) string
-
 func notEmpty(args map[string]string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("parameters cannot be empty")
@@ -64,8 +93,9 @@ func notEmpty(args map[string]string) error {
 	return nil
 }
 
-// NewLineReader is a ReadManager constructor.
-func NewLineReader(client *http.Client, masterURL url.URL, agentID, frameworkID, executorID, containerID, taskPath, file string,
+// NewLineReader is a ReadManager constructor. The context bounds all requests made by the ReadManager,
+// once it's cancelled the reads fail with the context error.
+func NewLineReader(ctx context.Context, client *http.Client, masterURL url.URL, agentID, frameworkID, executorID, containerID, taskPath, file string,
 	format Formatter, opts ...Option) (*ReadManager, error) {
 
 	// make sure the required parameters are set properly
@@ -74,24 +104,29 @@ func NewLineReader(client *http.Client, masterURL url.URL, agentID, frameworkID,
 		return nil, err
 	}
 
-	sandboxPath := path.Join("/var/lib/mesos/slave/slaves", agentID, "/frameworks", frameworkID, "/executors", executorID, "/runs", containerID)
-	if taskPath != "" {
-		sandboxPath = path.Join(sandboxPath, path.Join("tasks", taskPath))
-	}
-
 	rm := &ReadManager{
-		client: client,
+		ctx:       ctx,
+		client:    client,
+		chunkSize: DefaultChunkSize,
+		timeout:   DefaultTimeout,
+		retries:   DefaultRetries,
+
+		maxLineSize: DefaultMaxLineSize,
+
+		fieldMapping:     DefaultFieldMapping,
+		timestampLayouts: DefaultTimestampLayouts,
 
 		file:         file,
 		readEndpoint: masterURL,
-		sandboxPath:  sandboxPath,
 		formatFn:     format,
 
 		agentID:     agentID,
 		frameworkID: frameworkID,
 		executorID:  executorID,
 		containerID: containerID,
+		taskPath:    taskPath,
 	}
+	rm.setSandboxPath(DefaultSandboxRoot)
 
 	for _, opt := range opts {
 		if opt != nil {
@@ -101,87 +136,152 @@ func NewLineReader(client *http.Client, masterURL url.URL, agentID, frameworkID,
 		}
 	}
 
+	if !rm.since.IsZero() {
+		offset, err := rm.seekTime(rm.since)
+		if err != nil {
+			return nil, err
+		}
+		rm.offset = offset
+	}
+
 	if rm.readDirection == BottomToTop && rm.skip != 0 {
-		var (
-			offset int
-			length int
-		)
-
-		if rm.offset > chunkSize {
-			offset = rm.offset - chunkSize
-			length = chunkSize
-		} else {
-			// offset 0
-			length = rm.offset
+		skip := rm.skip
+
+		// make skip a positive number
+		if skip < 0 {
+			skip = rm.skip * -1
 		}
 
-		err := calcOffset(offset, length, rm)
+		offset, err := rm.seekBackward(rm.offset, skip)
 		if err != nil && err != io.EOF {
 			return nil, err
 		}
+		rm.offset = offset
 	}
 
 	// guard against negative offset
 	if rm.offset < 0 {
 		rm.offset = 0
 	}
+	rm.cursor = rm.offset
 
 	return rm, nil
 }
 
-func calcOffset(offset, length int, rm *ReadManager) error {
-	var foundLines int
+// setSandboxPath builds the sandbox path from the sandbox root and the container IDs.
+func (rm *ReadManager) setSandboxPath(sandboxRoot string) {
+	rm.sandboxPath = path.Join(sandboxRoot, rm.agentID, "/frameworks", rm.frameworkID, "/executors", rm.executorID,
+		"/runs", rm.containerID)
+	if rm.taskPath != "" {
+		rm.sandboxPath = path.Join(rm.sandboxPath, path.Join("tasks", rm.taskPath))
+	}
+}
 
-	for {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		lines, delta, err := rm.read(ctx, offset, length, reverse)
-		if err != nil {
-			cancel()
-			return err
+// seekBackward returns the offset of the n-th line before the end offset, counting the lines returned by Read.
+// The chunks are read from the end and scanned for the newline bytes, so the offset is exact for any encoding
+// and line length. If the file has less than n lines, 0 is returned.
+func (rm *ReadManager) seekBackward(end, n int) (int, error) {
+	var (
+		found int
+
+		// lineEnd is the offset of the newline terminating the current line, or end for the last line.
+		lineEnd = end
+
+		// tail is the part of the current line read from the following chunks. It's only kept if the lines
+		// have to be matched or grouped.
+		tail string
+
+		keepTail = len(rm.matches) > 0 || rm.multiline != nil
+	)
+
+	for pos := end; pos > 0; {
+		start := pos - rm.chunkSize
+		if start < 0 {
+			start = 0
 		}
 
+		ctx, cancel := rm.requestContext()
+		data, err := rm.readData(ctx, start, pos-start)
 		cancel()
+		if err != nil {
+			return 0, err
+		}
 
-		skip := rm.skip
-
-		// make skip a positive number
-		if skip < 0 {
-			skip = rm.skip * -1
+		if len(data) > pos-start {
+			data = data[:pos-start]
 		}
 
-		// if the required number of lines found, we need to calculate an offset
-		if foundLines+len(lines) >= skip {
-			for i, skipped := 0, 0; skipped < skip && i < len(lines); i++ {
-				if lines[i].Message != "" {
-					skipped++
+		// cur is the end of the current line in data.
+		cur := len(data)
+		for i := len(data) - 1; i >= 0; i-- {
+			if data[i] != '\n' {
+				continue
+			}
+
+			newline := start + i
+			// empty lines are not returned by Read, do not count them.
+			size := lineEnd - newline - 1
+			if size > 0 && rm.countLine(data[i+1:cur]+tail, size) {
+				found++
+				if found == n {
+					return newline + 1, nil
 				}
-				rm.offset -= len(lines[i].Message) + 1
 			}
-			return nil
+
+			lineEnd = newline
+			cur = i
+			tail = ""
 		}
 
-		// if the current chunk contains less then requested lines, then add to a counter
-		// and continue search.
-		foundLines += len(lines)
+		if keepTail {
+			tail = data[:cur] + tail
+			if len(tail) > rm.maxLineSize {
+				tail = tail[:rm.maxLineSize]
+			}
+		}
 
-		length = chunkSize
-		offset -= chunkSize - delta
-		rm.offset = offset
+		pos = start
+	}
 
-		// if the offset is 0 or negative value, the means we reached the top of the file.
-		// we can just set the offset to 0 and read the entire file
-		if offset < 0 {
-			rm.offset = 0
-			return nil
-		}
+	return 0, nil
+}
+
+// countLine returns true if the line is counted by seekBackward. With the multiline grouping only the first lines
+// of the events are counted.
+func (rm *ReadManager) countLine(content string, size int) bool {
+	if len(rm.matches) == 0 && rm.multiline == nil {
+		return true
+	}
+
+	msg := rm.lineMessage(content, size)
+	if rm.multiline != nil && rm.multiline.Continues(msg) {
+		return false
+	}
+	return len(rm.matches) == 0 || rm.match(rm.lineFields(msg))
+}
+
+// lineMessage returns the message of a line with the given content as Read returns it, the content is truncated
+// if the line size is above the max line size.
+func (rm *ReadManager) lineMessage(content string, size int) string {
+	if size <= rm.maxLineSize {
+		return content
+	}
+
+	if len(content) > rm.maxLineSize {
+		content = content[:rm.maxLineSize]
 	}
+	return truncateUTF8(content) + TruncationMarker
 }
 
 // ReadManager is a mesos files API reader. It builds the correct sandbox path to files
 // and implements io.Reader.
 // http://mesos.apache.org/documentation/latest/endpoints/files/read/
 type ReadManager struct {
+	ctx          context.Context
 	client       *http.Client
+	chunkSize    int
+	timeout      time.Duration
+	retries      int
 	readEndpoint url.URL
 	sandboxPath  string
 	header       http.Header
@@ -196,8 +296,36 @@ type ReadManager struct {
 	offset int
 	lines  []Line
 
+	// partial is the beginning of a line which is not terminated yet, up to maxLineSize bytes,
+	// partialSize is the full size of the line read so far.
+	partial     []byte
+	partialSize int
+	maxLineSize int
+
+	// output is the part of the formatted line not returned by Read yet.
+	output string
+
+	parseJSON    bool
+	fieldMapping FieldMapping
+	matches      map[string][]string
+
+	// lastTimestamp is the timestamp of the last line with a timestamp, the following lines without
+	// a timestamp belong to it.
+	timestampLayouts []string
+	since            time.Time
+	until            time.Time
+	lastTimestamp    time.Time
+	untilReached     bool
+
+	// event is a multi-line event which may continue on the next lines, eventLines is the number of its lines.
+	multiline  *multiline.Pattern
+	event      *Line
+	eventLines int
+
 	readLines int
 	stream    bool
+	cursor    int
+	closed    bool
 
 	formatFn Formatter
 
@@ -208,8 +336,13 @@ type ReadManager struct {
 	taskPath    string
 }
 
+// requestContext returns a context for a single files API request.
+func (rm *ReadManager) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(rm.ctx, rm.timeout)
+}
+
 func (rm *ReadManager) do(req *http.Request) (*response, error) {
-	resp, err := rm.client.Do(req)
+	resp, err := rm.roundTrip(req)
 	if err != nil {
 		return nil, err
 	}
@@ -219,13 +352,16 @@ func (rm *ReadManager) do(req *http.Request) (*response, error) {
 	case http.StatusOK:
 		break
 	case http.StatusNotFound:
+		filesAPIErrors.Inc("not_found")
 		return nil, ErrFileNotFound
 	default:
+		filesAPIErrors.Inc("bad_status")
 		return nil, fmt.Errorf("bad status %d", resp.StatusCode)
 	}
 
 	data := &response{}
 	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		filesAPIErrors.Inc("decode")
 		return nil, err
 	}
 
@@ -254,16 +390,13 @@ func (rm *ReadManager) fileLen(ctx context.Context) (int, error) {
 	return resp.Offset, nil
 }
 
-func (rm *ReadManager) read(ctx context.Context, offset, length int, modifier modifier) ([]Line, int, error) {
+// readData returns the file content from offset, up to length bytes.
+func (rm *ReadManager) readData(ctx context.Context, offset, length int) (string, error) {
 	v := url.Values{}
 	v.Add(pathParam, filepath.Join(rm.sandboxPath, rm.file))
 	v.Add(offsetParam, strconv.Itoa(offset))
 	v.Add(lengthParam, strconv.Itoa(length))
 
-	if modifier == nil {
-		modifier = func(s string) string { return s }
-	}
-
 	newURL := rm.readEndpoint
 	newURL.RawQuery = v.Encode()
 
@@ -271,42 +404,113 @@ func (rm *ReadManager) read(ctx context.Context, offset, length int, modifier mo
 
 	req, err := http.NewRequest("GET", newURL.String(), nil)
 	if err != nil {
-		return nil, 0, err
+		return "", err
 	}
 
 	req.Header = rm.header
 	resp, err := rm.do(req.WithContext(ctx))
 	if err != nil {
-		return nil, 0, err
+		return "", err
 	}
 
-	if resp.Data == "" || resp.Data == "\n" {
-		return nil, 0, io.EOF
-	}
+	return string(resp.Data), nil
+}
 
-	lines := strings.Split(modifier(resp.Data), "\n")
+// fill reads the file from the current offset and prepends the complete lines to the buffer. A line spanning
+// several chunks is reassembled, the part above the max line size is dropped. An incomplete last line is kept
+// until the rest is written, unless the ReadManager is not streaming and the end of file is reached.
+// It returns io.EOF if there is no new data.
+func (rm *ReadManager) fill() error {
+	for {
+		ctx, cancel := rm.requestContext()
+		data, err := rm.readData(ctx, rm.offset+rm.partialSize, rm.chunkSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		if data == "" {
+			if rm.stream || rm.partialSize == 0 {
+				return io.EOF
+			}
+
+			// the last line of the file has no newline.
+			rm.emitPartial()
+			return nil
+		}
+
+		var found bool
+		for {
+			i := strings.IndexByte(data, '\n')
+			if i == -1 {
+				break
+			}
 
-	delta := 0
-	// calculate delta only for chunks with offset > 0
-	// this is required to distinguish the chunk that start from the beginning, because it's does not have delta.
-	if offset > 0 && len(lines) > 1 {
-		delta = len(lines[len(lines)-1])
-		lines = lines[:len(lines)-1]
+			rm.appendPartial(data[:i])
+			rm.emitPartial()
+			// skip the newline.
+			rm.offset++
+			data = data[i+1:]
+			found = true
+		}
+		rm.appendPartial(data)
+
+		if found {
+			return nil
+		}
+
+		// the chunk is a part of a long line, read the next one. A short chunk means the rest of the line
+		// is not written yet.
+		if len(data) < rm.chunkSize {
+			if rm.stream {
+				return io.EOF
+			}
+
+			rm.emitPartial()
+			return nil
+		}
 	}
+}
 
-	linesWithOffset := make([]Line, len(lines))
-	// accumulates the position of the line + \readLimit
-	accumulator := 0
-	for i := 0; i < len(lines); i++ {
-		linesWithOffset[i] = Line{
-			Message: lines[i],
-			Offset:  offset + accumulator,
-			Size:    len(lines[i]),
+// appendPartial adds the data to the incomplete line. Only the bytes up to the max line size are kept.
+func (rm *ReadManager) appendPartial(data string) {
+	if keep := rm.maxLineSize - len(rm.partial); keep > 0 {
+		if keep > len(data) {
+			keep = len(data)
 		}
-		accumulator += len(lines[i]) + 1
+		rm.partial = append(rm.partial, data[:keep]...)
+	}
+	rm.partialSize += len(data)
+}
+
+// emitPartial prepends the incomplete line to the buffer and moves the offset past it.
+func (rm *ReadManager) emitPartial() {
+	line := Line{
+		Message: string(rm.partial),
+		Offset:  rm.offset,
+		Size:    rm.partialSize,
+	}
+
+	if truncated := rm.partialSize - len(rm.partial); truncated > 0 {
+		line.Message = rm.lineMessage(line.Message, rm.partialSize)
+		line.Truncated = truncated
 	}
 
-	return linesWithOffset, delta, nil
+	rm.Prepend(line)
+	rm.offset += rm.partialSize
+	rm.partial = rm.partial[:0]
+	rm.partialSize = 0
+}
+
+// truncateUTF8 drops an incomplete multi-byte character at the end of the truncated message.
+func truncateUTF8(s string) string {
+	for i := 0; i < utf8.UTFMax && i < len(s); i++ {
+		r, size := utf8.DecodeLastRuneInString(s[:len(s)-i])
+		if r != utf8.RuneError || size > 1 {
+			return s[:len(s)-i]
+		}
+	}
+	return s
 }
 
 // Prepend the lines to a buffer.
@@ -331,40 +535,68 @@ func (rm *ReadManager) Pop() *Line {
 	return &x
 }
 
+// Cursor returns the file offset right after the last line returned by Read. It can be used with OptOffset
+// to resume reading.
+func (rm *ReadManager) Cursor() int {
+	return rm.cursor
+}
+
+// Close releases the buffered lines. Read returns io.EOF after the ReadManager is closed.
+func (rm *ReadManager) Close() error {
+	rm.closed = true
+	rm.lines = nil
+	return nil
+}
+
 // Read implements io.Reader interface.
 func (rm *ReadManager) Read(b []byte) (int, error) {
+	if rm.closed {
+		return 0, io.EOF
+	}
+
+	if len(rm.output) > 0 {
+		return rm.writeOutput(b), nil
+	}
+
+	if rm.untilReached {
+		return 0, io.EOF
+	}
+
 start:
 	if !rm.stream && rm.readLimit > 0 && rm.readLines == rm.readLimit {
 		return 0, io.EOF
 	}
 
-	if len(rm.lines) == 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
+	line, err := rm.nextEvent()
+	if err != nil {
+		return 0, err
+	}
 
-		lines, delta, err := rm.read(ctx, rm.offset, chunkSize, nil)
-		if err != nil {
-			return 0, err
+	var fields map[string]interface{}
+	if rm.parseJSON || len(rm.matches) > 0 {
+		fields = rm.lineFields(line.Message)
+		if rm.parseJSON {
+			line.Fields = fields
 		}
+	}
 
-		if len(lines) > 0 {
-			linesLen := 0
-			for _, line := range lines {
-				rm.Prepend(line)
-				linesLen += len(line.Message) + 1
-			}
+	if ts, ok := rm.lineTimestamp(line.Message, line.Fields); ok {
+		rm.lastTimestamp = ts
+	}
+	line.Timestamp = rm.lastTimestamp
 
-			if linesLen < chunkSize {
-				rm.offset = rm.offset + linesLen - 1
-			} else {
-				rm.offset = (rm.offset + chunkSize) - delta - 1
-			}
-		}
+	if !rm.until.IsZero() && line.Timestamp.After(rm.until) {
+		rm.untilReached = true
+		return 0, io.EOF
 	}
 
-	line := rm.Pop()
-	if line == nil {
-		return 0, ErrNoData
+	// the lines are expected to be in time order, but a line before since may follow the seek position.
+	if !rm.since.IsZero() && line.Timestamp.Before(rm.since) {
+		goto start
+	}
+
+	if fields != nil && !rm.match(fields) {
+		goto start
 	}
 
 	if rm.skip > 0 && rm.skipped < rm.skip {
@@ -373,7 +605,77 @@ start:
 	}
 
 	rm.readLines++
-	return strings.NewReader(rm.formatFn(*line, rm)).Read(b)
+	rm.cursor = line.Offset + line.Size
+	rm.output = rm.formatFn(*line, rm)
+	return rm.writeOutput(b), nil
+}
+
+// nextLine returns the next line from the buffer, the buffer is filled from the file if it's empty.
+func (rm *ReadManager) nextLine() (*Line, error) {
+	for len(rm.lines) == 0 {
+		// do not make new requests on behalf of a client which went away.
+		if err := rm.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// the chunk may have only empty lines, the offset moves forward and the next chunk is read.
+		if err := rm.fill(); err != nil {
+			return nil, err
+		}
+	}
+
+	line := rm.Pop()
+	if line == nil {
+		return nil, ErrNoData
+	}
+	return line, nil
+}
+
+// nextEvent returns the next line. With the multiline grouping, the lines continuing an event are appended
+// to its first line, up to the max line size. An event is returned once the next one starts or the end of file
+// is reached.
+func (rm *ReadManager) nextEvent() (*Line, error) {
+	if rm.multiline == nil {
+		return rm.nextLine()
+	}
+
+	for {
+		line, err := rm.nextLine()
+		if err == io.EOF && rm.event != nil {
+			event := rm.event
+			rm.event = nil
+			return event, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rm.event == nil {
+			rm.event, rm.eventLines = line, 1
+			continue
+		}
+
+		if rm.eventLines < rm.multiline.MaxLines && len(rm.event.Message)+len(line.Message) < rm.maxLineSize &&
+			rm.multiline.Continues(line.Message) {
+			rm.event.Message += "\n" + line.Message
+			rm.event.Size = line.Offset + line.Size - rm.event.Offset
+			rm.event.Truncated += line.Truncated
+			rm.eventLines++
+			continue
+		}
+
+		event := rm.event
+		rm.event, rm.eventLines = line, 1
+		return event, nil
+	}
+}
+
+// writeOutput copies the formatted line to b, the rest is returned by the next Read.
+func (rm *ReadManager) writeOutput(b []byte) int {
+	n := copy(b, rm.output)
+	rm.output = rm.output[n:]
+	return n
 }
 
 // SandboxFile represents a file object located in mesos sandbox.
@@ -401,45 +703,39 @@ func (mt *mTime) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// BrowseSandbox returns a url to browse files in the sandbox.
-func (rm ReadManager) BrowseSandbox() ([]SandboxFile, error) {
-	v := url.Values{}
-	v.Add(pathParam, rm.sandboxPath)
-
-	newURL := rm.readEndpoint
-	newURL.RawQuery = v.Encode()
-
-	req, err := http.NewRequest("GET", newURL.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header = rm.header
-
-	resp, err := rm.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("unable to make a GET request: %s. URL %s", err, newURL.String())
-	}
-
-	logrus.Debugf("sandbox browse %s", newURL.String())
-
-	defer resp.Body.Close()
+// BrowseSandbox returns the files in the sandbox directory.
+func (rm *ReadManager) BrowseSandbox(ctx context.Context) ([]SandboxFile, error) {
+	return rm.browseDir(ctx, rm.sandboxPath)
+}
 
-	var files []SandboxFile
+type streamedBodyKey struct{}
 
-	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil {
-		return nil, fmt.Errorf("unable to decode files API response: %s. URL %s", err, newURL.String())
-	}
+// WithStreamedBody marks the requests made with the context as the ones with a streamed response body.
+// A client timeout must only apply until the response headers are received, the body is read until it ends
+// or the context is cancelled.
+func WithStreamedBody(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamedBodyKey{}, true)
+}
 
-	return files, nil
+// StreamedBody returns true if the requests made with the context have a streamed response body.
+func StreamedBody(ctx context.Context) bool {
+	streamed, _ := ctx.Value(streamedBodyKey{}).(bool)
+	return streamed
 }
 
 // Download makes a request to download endpoint and returns a raw http.Response for client to read and close.
-func (rm ReadManager) Download() (*http.Response, error) {
+// The download is not bound by the ReadManager timeout. The client timeout only applies until the response
+// headers are received, see WithStreamedBody, the body lasts until it's read or the context is cancelled.
+func (rm *ReadManager) Download(ctx context.Context) (*http.Response, error) {
+	return rm.download(ctx, rm.readEndpoint, filepath.Join(rm.sandboxPath, rm.file))
+}
+
+// download requests the file from the download endpoint. The response body is streamed.
+func (rm *ReadManager) download(ctx context.Context, endpoint url.URL, filePath string) (*http.Response, error) {
 	v := url.Values{}
-	v.Add(pathParam, filepath.Join(rm.sandboxPath, rm.file))
+	v.Add(pathParam, filePath)
 
-	newURL := rm.readEndpoint
+	newURL := endpoint
 	newURL.RawQuery = v.Encode()
 
 	req, err := http.NewRequest("GET", newURL.String(), nil)
@@ -451,13 +747,5 @@ func (rm ReadManager) Download() (*http.Response, error) {
 
 	req.Header = rm.header
 
-	return rm.client.Do(req)
-}
-
-func reverse(s string) string {
-	runes := []rune(s)
-	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-		runes[i], runes[j] = runes[j], runes[i]
-	}
-	return string(runes)
+	return rm.roundTrip(req.WithContext(WithStreamedBody(ctx)))
 }
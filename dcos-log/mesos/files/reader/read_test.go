@@ -2,14 +2,22 @@ package reader
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/multiline"
+	"github.com/dcos/dcos-log/dcos-log/redact"
 )
 
 var (
@@ -48,7 +56,7 @@ func createHandler(data []byte, forceSendResponse bool, t *testing.T) http.Handl
 		}
 
 		resp := &response{
-			Data:   string(d),
+			Data:   fileData(d),
 			Offset: offset,
 		}
 
@@ -73,7 +81,7 @@ func doRead(t *testing.T, data []byte, opts ...Option) []byte {
 		t.Fatal(err)
 	}
 
-	r, err := NewLineReader(client, *masterURL, "1", "2", "3", "4", "",
+	r, err := NewLineReader(context.Background(), client, *masterURL, "1", "2", "3", "4", "",
 		"stdout", LineFormat, opts...)
 	if err != nil {
 		t.Fatal(err)
@@ -194,13 +202,13 @@ func TestBrowseSandbox(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r, err := NewLineReader(client, *masterURL, "1", "2", "3", "4", "",
+	r, err := NewLineReader(context.Background(), client, *masterURL, "1", "2", "3", "4", "",
 		"stdout", LineFormat)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	files, err := r.BrowseSandbox()
+	files, err := r.BrowseSandbox(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -244,13 +252,13 @@ func TestDownload(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r, err := NewLineReader(client, *masterURL, "1", "2", "3", "4", "",
+	r, err := NewLineReader(context.Background(), client, *masterURL, "1", "2", "3", "4", "",
 		"stdout", LineFormat)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	dl, err := r.Download()
+	dl, err := r.Download(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -272,7 +280,7 @@ func TestHeaderSet(t *testing.T) {
 
 	opts := []Option{OptHeaders(h)}
 
-	r, err := NewLineReader(http.DefaultClient, url.URL{}, "1", "2", "3", "4",
+	r, err := NewLineReader(context.Background(), http.DefaultClient, url.URL{}, "1", "2", "3", "4",
 		"", "stdout", LineFormat, opts...)
 	if err != nil {
 		t.Fatal(err)
@@ -283,9 +291,510 @@ func TestHeaderSet(t *testing.T) {
 	}
 }
 
+func TestSandboxRoot(t *testing.T) {
+	r, err := NewLineReader(context.Background(), http.DefaultClient, url.URL{}, "1", "2", "3", "4",
+		"5", "stdout", LineFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "/var/lib/mesos/slave/slaves/1/frameworks/2/executors/3/runs/4/tasks/5"
+	if r.sandboxPath != expected {
+		t.Fatalf("expect sandbox path %s. Got %s", expected, r.sandboxPath)
+	}
+
+	r, err = NewLineReader(context.Background(), http.DefaultClient, url.URL{}, "1", "2", "3", "4",
+		"", "stdout", LineFormat, OptSandboxRoot("/mnt/mesos/slaves"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "/mnt/mesos/slaves/1/frameworks/2/executors/3/runs/4"
+	if r.sandboxPath != expected {
+		t.Fatalf("expect sandbox path %s. Got %s", expected, r.sandboxPath)
+	}
+
+	if _, err := NewLineReader(context.Background(), http.DefaultClient, url.URL{}, "1", "2", "3", "4",
+		"", "stdout", LineFormat, OptSandboxRoot("relative")); err == nil {
+		t.Fatal("expect an error for relative sandbox root")
+	}
+}
+
 func TestSkipBoundary(t *testing.T) {
 	// Test the values from -100 to 100 are acceptable and not causing panic
 	for i := -100; i < 100; i++ {
 		doRead(t, data, OptReadDirection(BottomToTop), OptSkip(i))
 	}
-}
\ No newline at end of file
+}
+
+func TestRedactedFormat(t *testing.T) {
+	redactor, err := redact.New([]redact.Rule{{Pattern: "t[a-z]+"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedResponse := []byte(`one
+[REDACTED]
+[REDACTED]
+four
+five
+`)
+
+	ts := httptest.NewServer(createHandler(data, true, t))
+	defer ts.Close()
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", Redacted(LineFormat, redactor))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Compare(buf, expectedResponse) != 0 {
+		t.Fatalf("expect %s. Got %s", expectedResponse, buf)
+	}
+}
+
+func TestChunkSize(t *testing.T) {
+	var lengths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lengths = append(lengths, r.URL.Query().Get("length"))
+		createHandler(data, true, t)(w, r)
+	}))
+	defer ts.Close()
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", LineFormat, OptChunkSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lengths) == 0 || lengths[0] != "1024" {
+		t.Fatalf("expect chunks of 1024 bytes. Got %v", lengths)
+	}
+
+	if _, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", LineFormat, OptChunkSize(0)); err == nil {
+		t.Fatal("expect an error for zero chunk size")
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", LineFormat, OptTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := r.Read(make([]byte, 10)); err == nil {
+		t.Fatal("expect a timeout error")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expect the request to time out after 50ms. Took %s", elapsed)
+	}
+}
+
+func TestReadContextCanceled(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		createHandler(data, true, t)(w, r)
+	}))
+	defer ts.Close()
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := NewLineReader(ctx, &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", LineFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if _, err := r.Read(make([]byte, 10)); err != context.Canceled {
+		t.Fatalf("expect %s. Got %v", context.Canceled, err)
+	}
+
+	if requests != 0 {
+		t.Fatalf("expect no requests to files API after the context is cancelled. Got %d", requests)
+	}
+
+	if _, err := r.BrowseSandbox(ctx); err == nil {
+		t.Fatal("expect an error browsing the sandbox with a cancelled context")
+	}
+}
+
+// mesosQuote encodes the file content into a JSON string the way mesos does, the bytes are written as is.
+func mesosQuote(b []byte) string {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c < 0x20:
+			fmt.Fprintf(buf, `\u%04x`, c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// newFilesAPIServer returns a fake mesos files API read endpoint serving the content.
+func newFilesAPIServer(t *testing.T, content []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// t.Fatal must not be called outside the test goroutine.
+		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if offset == -1 {
+			fmt.Fprintf(w, `{"data":"","offset":%d}`, len(content))
+			return
+		}
+
+		end := len(content)
+		if lengthStr := r.URL.Query().Get("length"); lengthStr != "" {
+			length, err := strconv.Atoi(lengthStr)
+			if err != nil {
+				t.Error(err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if offset+length < end {
+				end = offset + length
+			}
+		}
+
+		var chunk []byte
+		if offset < end {
+			chunk = content[offset:end]
+		}
+		fmt.Fprintf(w, `{"data":%s,"offset":%d}`, mesosQuote(chunk), offset)
+	}))
+}
+
+func TestSeekBackward(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		content   string
+		chunkSize int
+		end       int
+		lines     int
+		expected  int
+	}{
+		{name: "ascii", content: "one\ntwo\nthree\n", chunkSize: 4, lines: 2, expected: 4},
+		{name: "ascii single chunk", content: "one\ntwo\nthree\n", chunkSize: 1024, lines: 1, expected: 8},
+		{name: "no trailing newline", content: "one\ntwo\nthree", chunkSize: 4, lines: 1, expected: 8},
+		{name: "more lines than file", content: "one\ntwo\n", chunkSize: 3, lines: 10, expected: 0},
+		{name: "all lines", content: "one\ntwo\n", chunkSize: 3, lines: 2, expected: 0},
+		{name: "empty lines", content: "one\n\n\ntwo\n\n", chunkSize: 2, lines: 2, expected: 0},
+		{name: "crlf", content: "one\r\ntwo\r\nthree\r\n", chunkSize: 3, lines: 2, expected: 5},
+		{name: "multi-byte", content: "ログ一\nログ二\nログ三\n", chunkSize: 5, lines: 2, expected: 10},
+		{name: "multi-byte crlf", content: "日本語\r\nテキスト\r\n", chunkSize: 4, lines: 1, expected: 11},
+		{name: "line longer than chunk", content: "short\n" + strings.Repeat("長", 50) + "\nend\n", chunkSize: 7,
+			lines: 2, expected: 6},
+		{name: "end in the middle of file", content: "one\ntwo\nthree\nfour\n", chunkSize: 4, end: 14, lines: 2,
+			expected: 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newFilesAPIServer(t, []byte(tc.content))
+			defer ts.Close()
+
+			masterURL, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+				"stdout", LineFormat, OptChunkSize(tc.chunkSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			end := tc.end
+			if end == 0 {
+				end = len(tc.content)
+			}
+
+			offset, err := r.seekBackward(end, tc.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if offset != tc.expected {
+				t.Fatalf("expect offset %d. Got %d", tc.expected, offset)
+			}
+		})
+	}
+}
+
+func TestLastLinesMultiByte(t *testing.T) {
+	content := "最初の行\n二番目の行\n三番目の行\r\n最後の行\n"
+	ts := newFilesAPIServer(t, []byte(content))
+	defer ts.Close()
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", LineFormat, OptReadFromEnd(), OptSkip(-2), OptReadDirection(BottomToTop))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "三番目の行\r\n最後の行\n"
+	if string(buf) != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf)
+	}
+
+	// the cursor is right after the last line, before its newline.
+	if r.Cursor() != len(content)-1 {
+		t.Fatalf("expect cursor %d. Got %d", len(content)-1, r.Cursor())
+	}
+}
+
+func jsonLine(t *testing.T, size int) string {
+	payload := map[string]string{"level": "info", "payload": strings.Repeat("ペイロード", size/15)}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func newContentReader(t *testing.T, content string, opts ...Option) *ReadManager {
+	ts := newFilesAPIServer(t, []byte(content))
+	t.Cleanup(ts.Close)
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", LineFormat, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestLongLines(t *testing.T) {
+	first := jsonLine(t, 3<<20)
+	second := jsonLine(t, 1<<20)
+	content := first + "\nshort\n" + second + "\n"
+
+	r := newContentReader(t, content)
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != content {
+		t.Fatalf("expect %d bytes. Got %d", len(content), len(buf))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expect 3 lines. Got %d", len(lines))
+	}
+
+	for _, line := range []string{lines[0], lines[2]} {
+		if !json.Valid([]byte(line)) {
+			t.Fatalf("expect a valid JSON line. Got %.100s...", line)
+		}
+	}
+}
+
+func TestLongLineOffsets(t *testing.T) {
+	content := "first\n" + strings.Repeat("a", 100) + "\nlast\n"
+	r := newContentReader(t, content, OptChunkSize(16), OptStream(true))
+
+	expected := []Line{
+		{Message: "first", Offset: 0, Size: 5},
+		{Message: strings.Repeat("a", 100), Offset: 6, Size: 100},
+		{Message: "last", Offset: 107, Size: 4},
+	}
+
+	var lines []Line
+	for len(lines) < len(expected) {
+		if err := r.fill(); err != nil {
+			t.Fatal(err)
+		}
+
+		for line := r.Pop(); line != nil; line = r.Pop() {
+			lines = append(lines, *line)
+		}
+	}
+
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expect %+v. Got %+v", expected, lines)
+	}
+}
+
+func TestMaxLineSize(t *testing.T) {
+	long := strings.Repeat("日本", 20)
+	content := long + "\nshort\n"
+
+	// cut in the middle of a 3 bytes character.
+	r := newContentReader(t, content, OptChunkSize(7), OptMaxLineSize(10))
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "日本日" + TruncationMarker + "\nshort\n"
+	if string(buf) != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf)
+	}
+
+	// the cursor points to the end of the line in the file.
+	r = newContentReader(t, content, OptMaxLineSize(10), OptLines(1))
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Cursor() != len(long) {
+		t.Fatalf("expect cursor %d. Got %d", len(long), r.Cursor())
+	}
+}
+
+func TestStreamIncompleteLine(t *testing.T) {
+	content := "one\ntw"
+
+	r := newContentReader(t, content, OptStream(true))
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "one\n" {
+		t.Fatalf("expect the incomplete line to be held back. Got %q", buf)
+	}
+
+	r = newContentReader(t, content)
+	buf, err = ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "one\ntw\n" {
+		t.Fatalf("expect the last line without newline. Got %q", buf)
+	}
+}
+
+func TestReadSmallBuffer(t *testing.T) {
+	content := "a line longer than the buffer\n"
+	r := newContentReader(t, content)
+
+	var out []byte
+	b := make([]byte, 4)
+	for {
+		n, err := r.Read(b)
+		out = append(out, b[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if string(out) != content {
+		t.Fatalf("expect %q. Got %q", content, out)
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	trace := "ERROR failed\njava.lang.IllegalStateException: bad\n\tat A.a(A.java:1)\n\tat B.b(B.java:2)"
+	content := "INFO first\n" + trace + "\n\nINFO last\n"
+
+	p, err := multiline.Preset("java")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newContentReader(t, content, OptChunkSize(16), OptMultiline(p), OptSkip(1), OptLines(1))
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != trace+"\n" {
+		t.Fatalf("expect %q. Got %q", trace+"\n", buf)
+	}
+
+	// the cursor points after the last line of the event.
+	if cursor := strings.Index(content, "\n\nINFO"); r.Cursor() != cursor {
+		t.Fatalf("expect cursor %d. Got %d", cursor, r.Cursor())
+	}
+
+	r = newContentReader(t, content, OptChunkSize(16), OptMultiline(p), OptReadFromEnd(), OptReadDirection(BottomToTop),
+		OptSkip(-2))
+	buf, err = ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := trace + "\nINFO last\n"; string(buf) != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf)
+	}
+}
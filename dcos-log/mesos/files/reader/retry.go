@@ -0,0 +1,227 @@
+package reader
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetries is a number of times a failed files API request is retried.
+	DefaultRetries = 3
+
+	// BreakerOpenTimeout is the time the requests to an unavailable agent fail fast with ErrAgentUnavailable.
+	BreakerOpenTimeout = 10 * time.Second
+
+	// breakerThreshold is a number of consecutive failed requests after which the agent is considered unavailable.
+	breakerThreshold = 5
+
+	// maxRetryAfter caps the Retry-After value sent by the agent.
+	maxRetryAfter = 5 * time.Second
+)
+
+// retryBaseDelay and retryMaxDelay bound the exponential backoff between retries.
+var (
+	retryBaseDelay = 100 * time.Millisecond
+	retryMaxDelay  = 2 * time.Second
+)
+
+// retryableStatus returns true for the responses of an overloaded or restarting agent.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the retry attempt, starting with 0. The delay grows exponentially and has
+// a random jitter, so the streams reading from the same agent do not retry at once.
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << uint(attempt)
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter returns the value of Retry-After header in seconds, capped at maxRetryAfter.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	d := time.Duration(seconds) * time.Second
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+	return d, true
+}
+
+// wait sleeps for d or until the context is done.
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker is a circuit breaker of a single agent. After breakerThreshold consecutive failures it opens and
+// rejects the requests for BreakerOpenTimeout. Then a single probe request is let through, the breaker closes
+// if it succeeds and opens again otherwise.
+type breaker struct {
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+// allow returns true if a request can be made.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < BreakerOpenTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// the probe request is in flight.
+		return false
+	}
+	return true
+}
+
+// record updates the breaker with the result of a request.
+func (b *breaker) record(now time.Time, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= breakerThreshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+}
+
+// cancel is called if a request was cancelled by the client. If it was the probe, the next request probes again.
+func (b *breaker) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
+
+// breakers holds a circuit breaker per agent address, shared by all ReadManagers.
+var breakers = struct {
+	sync.Mutex
+	m map[string]*breaker
+}{m: make(map[string]*breaker)}
+
+func agentBreaker(host string) *breaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+
+	b, ok := breakers.m[host]
+	if !ok {
+		b = &breaker{}
+		breakers.m[host] = b
+	}
+	return b
+}
+
+// roundTrip makes a files API request. Transport errors and the retryable statuses are retried with backoff,
+// the last response or error is returned once the retries are exhausted. If the agent is unavailable,
+// ErrAgentUnavailable is returned without making a request. The requests must be idempotent.
+func (rm *ReadManager) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	b := agentBreaker(req.URL.Host)
+
+	for attempt := 0; ; attempt++ {
+		if !b.allow(time.Now()) {
+			filesAPIErrors.Inc("agent_unavailable")
+			return nil, ErrAgentUnavailable
+		}
+
+		start := time.Now()
+		resp, err := rm.client.Do(req)
+		filesAPIDuration.Observe(time.Since(start).Seconds())
+
+		if err != nil && ctx.Err() != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				filesAPIErrors.Inc("timeout")
+				b.record(time.Now(), true)
+				return nil, ctx.Err()
+			}
+
+			// the client went away, it says nothing about the agent.
+			b.cancel()
+			filesAPIErrors.Inc("canceled")
+			return nil, ctx.Err()
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			filesAPIErrors.Inc("transport")
+			b.record(time.Now(), true)
+		case retryableStatus(resp.StatusCode):
+			filesAPIRequests.Inc(strconv.Itoa(resp.StatusCode))
+			// 429 means the agent is up, but busy.
+			b.record(time.Now(), resp.StatusCode != http.StatusTooManyRequests)
+			delay, _ = retryAfter(resp)
+		default:
+			filesAPIRequests.Inc(strconv.Itoa(resp.StatusCode))
+			b.record(time.Now(), false)
+			return resp, nil
+		}
+
+		if attempt >= rm.retries {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if delay == 0 {
+			delay = backoff(attempt)
+		}
+
+		filesAPIRetries.Inc()
+		if err := wait(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
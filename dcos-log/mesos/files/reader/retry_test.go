@@ -0,0 +1,164 @@
+package reader
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	retryBaseDelay = time.Millisecond
+	retryMaxDelay = 10 * time.Millisecond
+}
+
+// failingHandler replies with the given status to the first n requests and serves the data afterwards.
+func failingHandler(t *testing.T, n int32, status int, requests *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+		createHandler(data, true, t)(w, r)
+	}
+}
+
+func newTestReader(t *testing.T, handler http.Handler, opts ...Option) *ReadManager {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", LineFormat, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRetryableStatus(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		var requests int32
+		r := newTestReader(t, failingHandler(t, 2, status, &requests))
+
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("status %d: %s", status, err)
+		}
+
+		if string(buf) != string(data) {
+			t.Fatalf("status %d: expect %s. Got %s", status, data, buf)
+		}
+
+		if requests != 4 {
+			t.Fatalf("status %d: expect 2 failed requests, 1 successful and 1 at EOF. Got %d", status, requests)
+		}
+	}
+}
+
+func TestNotRetryableStatus(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusForbidden} {
+		var requests int32
+		r := newTestReader(t, failingHandler(t, 1, status, &requests))
+
+		if _, err := ioutil.ReadAll(r); err == nil {
+			t.Fatalf("status %d: expect an error", status)
+		}
+
+		if requests != 1 {
+			t.Fatalf("status %d: expect no retries. Got %d requests", status, requests)
+		}
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	var requests int32
+	r := newTestReader(t, failingHandler(t, 100, http.StatusTooManyRequests, &requests), OptRetries(2))
+
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatal("expect an error")
+	}
+
+	if requests != 3 {
+		t.Fatalf("expect 3 requests. Got %d", requests)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests int32
+	r := newTestReader(t, failingHandler(t, 100, http.StatusServiceUnavailable, &requests), OptRetries(breakerThreshold-1))
+
+	if _, err := ioutil.ReadAll(r); err == nil || err == ErrAgentUnavailable {
+		t.Fatalf("expect bad status error. Got %v", err)
+	}
+
+	if _, err := ioutil.ReadAll(r); err != ErrAgentUnavailable {
+		t.Fatalf("expect %s. Got %v", ErrAgentUnavailable, err)
+	}
+
+	if requests != breakerThreshold {
+		t.Fatalf("expect no requests once the breaker is open. Got %d requests", requests)
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	b := &breaker{}
+	now := time.Now()
+
+	for i := 0; i < breakerThreshold; i++ {
+		if !b.allow(now) {
+			t.Fatalf("expect request %d to be allowed", i)
+		}
+		b.record(now, true)
+	}
+
+	if b.allow(now) {
+		t.Fatal("expect the breaker to be open")
+	}
+
+	now = now.Add(BreakerOpenTimeout)
+	if !b.allow(now) {
+		t.Fatal("expect a probe request to be allowed")
+	}
+
+	if b.allow(now) {
+		t.Fatal("expect a single probe request")
+	}
+
+	// the failed probe opens the breaker again.
+	b.record(now, true)
+	if b.allow(now) {
+		t.Fatal("expect the breaker to be open after the failed probe")
+	}
+
+	now = now.Add(BreakerOpenTimeout)
+	if !b.allow(now) {
+		t.Fatal("expect a probe request to be allowed")
+	}
+
+	b.record(now, false)
+	if !b.allow(now) || !b.allow(now) {
+		t.Fatal("expect the breaker to be closed after the successful probe")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		d := retryBaseDelay << uint(attempt)
+		if d > retryMaxDelay {
+			d = retryMaxDelay
+		}
+
+		if delay := backoff(attempt); delay < d/2 || delay > d {
+			t.Fatalf("attempt %d: expect delay between %s and %s. Got %s", attempt, d/2, d, delay)
+		}
+	}
+}
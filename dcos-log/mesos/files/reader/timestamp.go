@@ -0,0 +1,230 @@
+package reader
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// maxTimestampScan is a number of chunks scanned for a line with a timestamp at a bisection point.
+const maxTimestampScan = 4
+
+// DefaultTimestampLayouts are the layouts of the timestamps recognized at the beginning of a line or in
+// the timestamp field of a JSON line. The timestamps without a zone are in UTC.
+var DefaultTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05,999999999",
+	"2006/01/02 15:04:05.999999999",
+}
+
+// parseTimestamp parses the value with the layouts.
+func parseTimestamp(value string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// prefixTimestamp parses a timestamp at the beginning of a line, optionally in square brackets. The timestamp
+// may have up to 3 space separated parts, e.g. a date, a time and a zone. The longest timestamp is used, so the zone
+// is not ignored.
+func prefixTimestamp(msg string, layouts []string) (time.Time, bool) {
+	s := strings.TrimPrefix(msg, "[")
+	// the ends of the first 3 words, the words may be separated by several spaces like in syslog dates.
+	var ends []int
+	for pos := 0; len(ends) < 3 && pos < len(s); {
+		for pos < len(s) && s[pos] == ' ' {
+			pos++
+		}
+		if pos == len(s) {
+			break
+		}
+
+		if next := strings.IndexByte(s[pos:], ' '); next == -1 {
+			pos = len(s)
+		} else {
+			pos += next
+		}
+		ends = append(ends, pos)
+	}
+
+	for i := len(ends) - 1; i >= 0; i-- {
+		candidate := strings.TrimRight(s[:ends[i]], "]:")
+		if t, ok := parseTimestamp(candidate, layouts); ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// fieldTimestamp parses the timestamp field of a JSON line, a string or a unix time in seconds or milliseconds.
+func fieldTimestamp(value interface{}, layouts []string) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		return parseTimestamp(v, layouts)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil || f <= 0 {
+			return time.Time{}, false
+		}
+
+		// the values above year 33658 in seconds are milliseconds.
+		if f > 1e12 {
+			f /= 1000
+		}
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// lineTimestamp returns the timestamp of a line, taken from the timestamp field of a JSON line or from
+// the beginning of the line.
+func (rm *ReadManager) lineTimestamp(msg string, fields map[string]interface{}) (time.Time, bool) {
+	// JSON lines are only parsed for the timestamp if a time range is requested.
+	if fields == nil && (!rm.since.IsZero() || !rm.until.IsZero()) {
+		fields, _ = parseJSONLine(msg, rm.fieldMapping)
+	}
+
+	if value, ok := fields[FieldTimestamp]; ok {
+		if t, ok := fieldTimestamp(value, rm.timestampLayouts); ok {
+			return t, true
+		}
+	}
+	return prefixTimestamp(msg, rm.timestampLayouts)
+}
+
+// scanLines calls fn for the lines starting at or after from and ending before to, until fn returns false.
+// The line passed to fn is cut at the max line size.
+func (rm *ReadManager) scanLines(from, to int, fn func(offset int, msg string) bool) error {
+	pos := from
+	// skip the line in progress, unless from is at the beginning of a line.
+	skip := from > 0
+	if skip {
+		pos--
+	}
+
+	var (
+		lineStart = pos
+		line      []byte
+	)
+
+	for pos < to {
+		length := rm.chunkSize
+		if to-pos < length {
+			length = to - pos
+		}
+
+		ctx, cancel := rm.requestContext()
+		data, err := rm.readData(ctx, pos, length)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		if data == "" {
+			return nil
+		}
+
+		for consumed := 0; consumed < len(data); {
+			i := strings.IndexByte(data[consumed:], '\n')
+			if i == -1 {
+				if !skip && len(line) < rm.maxLineSize {
+					line = append(line, data[consumed:]...)
+				}
+				break
+			}
+
+			if skip {
+				skip = false
+			} else {
+				line = append(line, data[consumed:consumed+i]...)
+				if len(line) > rm.maxLineSize {
+					line = line[:rm.maxLineSize]
+				}
+
+				if !fn(lineStart, string(line)) {
+					return nil
+				}
+			}
+
+			consumed += i + 1
+			lineStart = pos + consumed
+			line = line[:0]
+		}
+
+		pos += len(data)
+	}
+
+	return nil
+}
+
+// timestampAfter returns the first line with a timestamp starting at or after from and before to. Only
+// maxTimestampScan chunks are scanned.
+func (rm *ReadManager) timestampAfter(from, to int) (int, time.Time, bool, error) {
+	if limit := from + maxTimestampScan*rm.chunkSize; limit < to {
+		to = limit
+	}
+
+	var (
+		offset int
+		ts     time.Time
+		found  bool
+	)
+
+	err := rm.scanLines(from, to, func(lineOffset int, msg string) bool {
+		ts, found = rm.lineTimestamp(msg, nil)
+		offset = lineOffset
+		return !found
+	})
+	return offset, ts, found, err
+}
+
+// seekTime returns the offset of the first line with a timestamp at or after t. The file is bisected by offsets,
+// so only a few chunks are read regardless of the file size. The lines are expected to be in time order,
+// the lines without a timestamp belong to the previous line.
+func (rm *ReadManager) seekTime(t time.Time) (int, error) {
+	ctx, cancel := rm.requestContext()
+	end, err := rm.fileLen(ctx)
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+
+	// the line at lo is before t, the line at hi is at or after t.
+	lo, hi := 0, end
+	for hi-lo > rm.chunkSize {
+		mid := lo + (hi-lo)/2
+		offset, ts, ok, err := rm.timestampAfter(mid, hi)
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case !ok:
+			// no timestamps in the scanned range, look in the first half. If the lines after mid are at or
+			// after t, Read skips the lines before t.
+			hi = mid
+		case ts.Before(t):
+			lo = offset
+		default:
+			hi = offset
+		}
+	}
+
+	result := hi
+	err = rm.scanLines(lo, hi, func(offset int, msg string) bool {
+		if ts, ok := rm.lineTimestamp(msg, nil); ok && !ts.Before(t) {
+			result = offset
+			return false
+		}
+		return true
+	})
+	return result, err
+}
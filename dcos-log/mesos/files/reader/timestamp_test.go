@@ -0,0 +1,217 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrefixTimestamp(t *testing.T) {
+	expected := time.Date(2018, 1, 2, 10, 4, 5, 123000000, time.UTC)
+	for _, msg := range []string{
+		"2018-01-02T10:04:05.123Z message",
+		"2018-01-02T11:04:05.123+01:00 message",
+		"2018-01-02T10:04:05.123 message",
+		"2018-01-02 10:04:05.123 message",
+		"2018-01-02 10:04:05,123 INFO message",
+		"2018-01-02 11:04:05.123 +0100 message",
+		"2018/01/02 10:04:05.123 message",
+		"[2018-01-02T10:04:05.123Z] message",
+		"2018-01-02 10:04:05.123: message",
+		"2018-01-02T10:04:05.123Z",
+	} {
+		ts, ok := prefixTimestamp(msg, DefaultTimestampLayouts)
+		if !ok {
+			t.Fatalf("%q: expect a timestamp", msg)
+		}
+
+		if !ts.Equal(expected) {
+			t.Fatalf("%q: expect %s. Got %s", msg, expected, ts)
+		}
+	}
+
+	for _, msg := range []string{"", "message", "  at main.go:10", "2018 was a good year", "[info] message"} {
+		if ts, ok := prefixTimestamp(msg, DefaultTimestampLayouts); ok {
+			t.Fatalf("%q: expect no timestamp. Got %s", msg, ts)
+		}
+	}
+}
+
+func TestPrefixTimestampLayouts(t *testing.T) {
+	layouts := []string{"Jan _2 15:04:05.000"}
+	ts, ok := prefixTimestamp("Jan  2 10:04:05.123 message", layouts)
+	if !ok {
+		t.Fatal("expect a timestamp")
+	}
+
+	if expected := time.Date(0, 1, 2, 10, 4, 5, 123000000, time.UTC); !ts.Equal(expected) {
+		t.Fatalf("expect %s. Got %s", expected, ts)
+	}
+}
+
+func TestFieldTimestamp(t *testing.T) {
+	expected := time.Date(2018, 1, 2, 10, 4, 5, 0, time.UTC)
+	for _, value := range []interface{}{
+		"2018-01-02T10:04:05Z",
+		json.Number("1514887445"),
+		json.Number("1514887445000"),
+	} {
+		ts, ok := fieldTimestamp(value, DefaultTimestampLayouts)
+		if !ok {
+			t.Fatalf("%v: expect a timestamp", value)
+		}
+
+		if !ts.Equal(expected) {
+			t.Fatalf("%v: expect %s. Got %s", value, expected, ts)
+		}
+	}
+
+	for _, value := range []interface{}{"yesterday", json.Number("-1"), true, nil} {
+		if ts, ok := fieldTimestamp(value, DefaultTimestampLayouts); ok {
+			t.Fatalf("%v: expect no timestamp. Got %s", value, ts)
+		}
+	}
+}
+
+var timeRangeStart = time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC)
+
+// timeRangeContent returns the lines logged every second, every 10th line is followed by a line without
+// a timestamp.
+func timeRangeContent(n int) string {
+	var lines []string
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("%s line %d", timeRangeStart.Add(time.Duration(i)*time.Second).Format(time.RFC3339), i))
+		if i%10 == 0 {
+			lines = append(lines, fmt.Sprintf("  at line %d", i))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestSinceUntil(t *testing.T) {
+	content := timeRangeContent(1000)
+	since := timeRangeStart.Add(300 * time.Second)
+	until := timeRangeStart.Add(303 * time.Second)
+
+	r := newContentReader(t, content, OptChunkSize(256), OptSince(since), OptUntil(until))
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "2018-01-02T10:05:00Z line 300\n  at line 300\n2018-01-02T10:05:01Z line 301\n" +
+		"2018-01-02T10:05:02Z line 302\n2018-01-02T10:05:03Z line 303\n"
+	if string(buf) != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf)
+	}
+}
+
+func TestSinceBisection(t *testing.T) {
+	content := timeRangeContent(100000)
+	files := newFilesAPIServer(t, []byte(content))
+	files.Close()
+
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		files.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	masterURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	since := timeRangeStart.Add(54321 * time.Second)
+	r, err := NewLineReader(context.Background(), &http.Client{}, *masterURL, "1", "2", "3", "4", "",
+		"stdout", LineFormat, OptChunkSize(1024), OptSince(since), OptLines(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset := strings.Index(content, since.Format(time.RFC3339))
+	if r.offset != offset {
+		t.Fatalf("expect offset %d. Got %d", offset, r.offset)
+	}
+
+	// the file has more than 3000 chunks.
+	if requests > 100 {
+		t.Fatalf("expect the file to be bisected. Got %d requests", requests)
+	}
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := since.Format(time.RFC3339) + " line 54321\n"; string(buf) != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf)
+	}
+}
+
+func TestSinceOutOfRange(t *testing.T) {
+	content := timeRangeContent(100)
+
+	r := newContentReader(t, content, OptChunkSize(128), OptSince(timeRangeStart.Add(-time.Hour)), OptLines(1))
+	if r.offset != 0 {
+		t.Fatalf("expect offset 0. Got %d", r.offset)
+	}
+
+	r = newContentReader(t, content, OptChunkSize(128), OptSince(timeRangeStart.Add(time.Hour)))
+	if r.offset != len(content) {
+		t.Fatalf("expect offset %d. Got %d", len(content), r.offset)
+	}
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(buf) != 0 {
+		t.Fatalf("expect no lines. Got %q", buf)
+	}
+}
+
+func TestSinceJSONLines(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		ts := timeRangeStart.Add(time.Duration(i) * time.Second)
+		lines = append(lines, fmt.Sprintf(`{"ts":%d,"msg":"line %d"}`, ts.Unix(), i))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	r := newContentReader(t, content, OptChunkSize(128), OptSince(timeRangeStart.Add(50*time.Second)),
+		OptUntil(timeRangeStart.Add(51*time.Second)))
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := lines[50] + "\n" + lines[51] + "\n"; string(buf) != expected {
+		t.Fatalf("expect %q. Got %q", expected, buf)
+	}
+}
+
+func TestSSETimestamp(t *testing.T) {
+	r := newContentReader(t, "2018-01-02T10:00:00.5Z first\nsecond\n", OptLines(2))
+	r.formatFn = SSEFormat
+
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second line has no timestamp and gets the timestamp of the first one.
+	if n := strings.Count(string(buf), `"realtime_timestamp":1514887200500000`); n != 2 {
+		t.Fatalf("expect 2 lines with the timestamp. Got %s", buf)
+	}
+}
@@ -0,0 +1,249 @@
+package sandbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LocalFiles serves mesos files API read, browse and download endpoints from the sandboxes on the local disk.
+// Only the paths in the sandbox root can be read.
+type LocalFiles struct {
+	Root string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (l *LocalFiles) RoundTrip(req *http.Request) (*http.Response, error) {
+	filePath, err := l.resolve(req.URL.Query().Get("path"))
+	if err != nil {
+		return l.response(req, http.StatusNotFound, nil, nil), nil
+	}
+
+	switch path.Base(req.URL.Path) {
+	case "read":
+		return l.read(req, filePath)
+	case "browse":
+		return l.browse(req, filePath)
+	case "download":
+		return l.download(req, filePath)
+	}
+	return l.response(req, http.StatusNotFound, nil, nil), nil
+}
+
+// resolve returns the path of a file on disk. The symlinks, e.g. runs/latest, must point inside the sandbox root.
+func (l *LocalFiles) resolve(filePath string) (string, error) {
+	root, err := filepath.EvalSymlinks(l.Root)
+	if err != nil {
+		return "", err
+	}
+
+	filePath = filepath.Clean("/" + filePath)
+	if !within(filepath.Clean(l.Root), filePath) {
+		return "", fmt.Errorf("%s is outside of the sandbox root", filePath)
+	}
+
+	resolved, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if !within(root, resolved) {
+		return "", fmt.Errorf("%s is outside of the sandbox root", filePath)
+	}
+	return resolved, nil
+}
+
+// within returns true if the path is the root or a path in it.
+func within(root, p string) bool {
+	return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func (l *LocalFiles) response(req *http.Request, code int, header http.Header, body io.ReadCloser) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	if body == nil {
+		body = ioutil.NopCloser(bytes.NewReader(nil))
+	}
+
+	return &http.Response{
+		Status:     strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       body,
+		Request:    req,
+	}
+}
+
+// read returns a file chunk like mesos files API. offset -1 returns the file size.
+func (l *LocalFiles) read(req *http.Request, filePath string) (*http.Response, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return l.response(req, http.StatusNotFound, nil, nil), nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return l.response(req, http.StatusNotFound, nil, nil), nil
+	}
+
+	query := req.URL.Query()
+	offset, err := strconv.ParseInt(query.Get("offset"), 10, 64)
+	if err != nil {
+		offset = 0
+	}
+
+	var data []byte
+	if offset < 0 {
+		offset = info.Size()
+	} else {
+		length, err := strconv.ParseInt(query.Get("length"), 10, 64)
+		if err != nil || length < 0 || offset+length > info.Size() {
+			length = info.Size() - offset
+		}
+
+		if length > 0 {
+			data = make([]byte, length)
+			n, err := f.ReadAt(data, offset)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			data = data[:n]
+		}
+	}
+
+	body := &bytes.Buffer{}
+	body.WriteString(`{"data":`)
+	body.Write(quoteBytes(data))
+	fmt.Fprintf(body, `,"offset":%d}`, offset)
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return l.response(req, http.StatusOK, header, ioutil.NopCloser(body)), nil
+}
+
+// browseEntry is a directory entry of files API browse endpoint.
+type browseEntry struct {
+	Path  string `json:"path"`
+	Mode  string `json:"mode"`
+	MTime int64  `json:"mtime"`
+	Size  int64  `json:"size"`
+}
+
+func (l *LocalFiles) browse(req *http.Request, dirPath string) (*http.Response, error) {
+	infos, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return l.response(req, http.StatusNotFound, nil, nil), nil
+	}
+
+	// the entries keep the requested path, not the resolved one.
+	dir := path.Clean("/" + req.URL.Query().Get("path"))
+	entries := make([]browseEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, browseEntry{
+			Path:  path.Join(dir, info.Name()),
+			Mode:  info.Mode().String(),
+			MTime: info.ModTime().Unix(),
+			Size:  info.Size(),
+		})
+	}
+
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return l.response(req, http.StatusOK, header, ioutil.NopCloser(bytes.NewReader(body))), nil
+}
+
+func (l *LocalFiles) download(req *http.Request, filePath string) (*http.Response, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return l.response(req, http.StatusNotFound, nil, nil), nil
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return l.response(req, http.StatusNotFound, nil, nil), nil
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+
+	resp := l.response(req, http.StatusOK, header, f)
+	resp.ContentLength = info.Size()
+	return resp, nil
+}
+
+// quoteBytes encodes the file content as a JSON string. Like mesos files API, the bytes which are not valid UTF-8
+// are kept, so the offsets in the file match the length of the data.
+func quoteBytes(b []byte) []byte {
+	buf := make([]byte, 0, len(b)+2)
+	buf = append(buf, '"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c == '\n':
+			buf = append(buf, '\\', 'n')
+		case c == '\t':
+			buf = append(buf, '\\', 't')
+		case c < 0x20:
+			buf = append(buf, fmt.Sprintf(`\u%04x`, c)...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '"')
+}
+
+// FallbackTransport sends the requests to mesos files API. The sandboxes mesos agent no longer knows, e.g. after
+// the agent restarted or the executor was forgotten, are served from the local disk until they are removed.
+// The local disk is only read if mesos agent responds 404, the transport errors are returned as is, so the
+// files are not served while mesos can't authorize the request.
+type FallbackTransport struct {
+	Base  http.RoundTripper
+	Local *LocalFiles
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *FallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		return resp, err
+	}
+
+	local, err := t.Local.RoundTrip(req)
+	if err != nil || local.StatusCode == http.StatusNotFound {
+		if local != nil {
+			local.Body.Close()
+		}
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	return local, nil
+}
@@ -0,0 +1,119 @@
+package sandbox
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dcos/dcos-log/dcos-log/mesos/files/reader"
+)
+
+func TestQuoteBytes(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":                   `""`,
+		"line\n":             `"line\n"`,
+		`"quoted" \ tab	end`: `"\"quoted\" \\ tab\tend"`,
+		"bell\x07":           `"bell\u0007"`,
+		"invalid \xff utf8":  "\"invalid \xff utf8\"",
+	} {
+		if actual := string(quoteBytes([]byte(input))); actual != expected {
+			t.Fatalf("%q: expect %s. Got %s", input, expected, actual)
+		}
+	}
+}
+
+func TestLocalFilesOutsideRoot(t *testing.T) {
+	root := newSandboxRoot(t, map[string]string{
+		"S1/frameworks/F1/executors/E1/runs/C1/stdout": "",
+	})
+
+	runDir := filepath.Join(root, "S1/frameworks/F1/executors/E1/runs/C1")
+	if err := os.Symlink("/etc", filepath.Join(runDir, "etc")); err != nil {
+		t.Fatal(err)
+	}
+
+	local := &LocalFiles{Root: root}
+	for _, filePath := range []string{"/etc/passwd", filepath.Join(root, "../../etc/passwd"), filepath.Join(runDir, "etc/passwd")} {
+		req := httptest.NewRequest("GET", "http://agent/files/read?"+url.Values{"path": {filePath}}.Encode(), nil)
+		resp, err := local.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: expect 404. Got %d", filePath, resp.StatusCode)
+		}
+	}
+}
+
+func TestFallbackTransport(t *testing.T) {
+	root := newSandboxRoot(t, map[string]string{
+		"S1/frameworks/F1/executors/E1/runs/C1/stdout": "first\nsecond \xff\nthird\n",
+	})
+
+	// mesos agent no longer knows the sandbox.
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	agentURL, err := url.Parse(ts.URL + "/files/read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &FallbackTransport{Local: &LocalFiles{Root: root}}}
+	r, err := reader.NewLineReader(context.Background(), client, *agentURL, "S1", "F1", "E1", "C1", "", "stdout",
+		reader.LineFormat, reader.OptSandboxRoot(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil && err != reader.ErrNoData {
+		t.Fatal(err)
+	}
+
+	if string(data) != "first\nsecond \xff\nthird\n" {
+		t.Fatalf("expect the local stdout. Got %q", data)
+	}
+
+	agentURL.Path = "/files/browse"
+	r, err = reader.NewLineReader(context.Background(), client, *agentURL, "S1", "F1", "E1", "C1", "", "stdout",
+		reader.LineFormat, reader.OptSandboxRoot(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := r.BrowseSandbox(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || !strings.HasSuffix(files[0].Path, "/stdout") || files[0].Size != 21 {
+		t.Fatalf("expect stdout of 21 bytes. Got %+v", files)
+	}
+}
+
+func TestFallbackTransportError(t *testing.T) {
+	root := newSandboxRoot(t, map[string]string{
+		"S1/frameworks/F1/executors/E1/runs/C1/stdout": "secret\n",
+	})
+
+	// mesos agent is down, it can't authorize the request.
+	ts := httptest.NewServer(http.NotFoundHandler())
+	agentURL := ts.URL
+	ts.Close()
+
+	client := &http.Client{Transport: &FallbackTransport{Local: &LocalFiles{Root: root}}}
+	path := filepath.Join(root, "S1/frameworks/F1/executors/E1/runs/C1/stdout")
+	resp, err := client.Get(agentURL + "/files/read?" + url.Values{"path": {path}, "offset": {"0"}}.Encode())
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expect an error. Got %d", resp.StatusCode)
+	}
+}
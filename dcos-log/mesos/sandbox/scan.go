@@ -0,0 +1,157 @@
+// Package sandbox finds the task sandboxes left on the agent disk and serves their files. Mesos forgets the
+// completed tasks and the tasks of the previous agent runs, their sandboxes are kept until garbage collected.
+package sandbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dcos/dcos-log/dcos-log/mesos/files/reader"
+)
+
+// latestRun is a symlink mesos agent creates to the last run of an executor.
+const latestRun = "latest"
+
+// File is a log file in a run sandbox.
+type File struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Run is an executor run found in the sandbox root.
+type Run struct {
+	AgentID     string `json:"agent_id"`
+	FrameworkID string `json:"framework_id"`
+	ExecutorID  string `json:"executor_id"`
+	ContainerID string `json:"container_id"`
+
+	// TaskPath is the task directory of a pod task, empty for the command tasks.
+	TaskPath string `json:"task_path,omitempty"`
+
+	// ModTime is the time the sandbox was last modified, usually the last write to the task logs.
+	ModTime time.Time `json:"mtime"`
+
+	Files []File `json:"files"`
+}
+
+// Dir returns the sandbox directory of the run.
+func (r Run) Dir(root string) string {
+	dir := filepath.Join(root, r.AgentID, "frameworks", r.FrameworkID, "executors", r.ExecutorID, "runs", r.ContainerID)
+	if r.TaskPath != "" {
+		dir = filepath.Join(dir, "tasks", r.TaskPath)
+	}
+	return dir
+}
+
+// Scan returns the runs of the executors with an ID containing the task ID and the pod tasks with the ID,
+// the latest modified first.
+func Scan(root, task string) ([]Run, error) {
+	if task == "" {
+		return nil, fmt.Errorf("task ID is empty")
+	}
+
+	runDirs, err := filepath.Glob(filepath.Join(root, "*", "frameworks", "*", "executors", "*", "runs", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+	for _, runDir := range runDirs {
+		if filepath.Base(runDir) == latestRun {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, runDir)
+		if err != nil {
+			return nil, err
+		}
+
+		// <agent>/frameworks/<framework>/executors/<executor>/runs/<container>
+		parts := strings.Split(rel, string(filepath.Separator))
+		run := Run{
+			AgentID:     parts[0],
+			FrameworkID: parts[2],
+			ExecutorID:  parts[4],
+			ContainerID: parts[6],
+		}
+
+		if strings.Contains(run.ExecutorID, task) {
+			if found, ok := readRun(root, run); ok {
+				runs = append(runs, found)
+			}
+		}
+
+		// the pod tasks run in the executor sandbox, the executor ID does not have the task ID.
+		taskDirs, err := ioutil.ReadDir(filepath.Join(runDir, "tasks"))
+		if err != nil {
+			continue
+		}
+
+		for _, taskDir := range taskDirs {
+			if !taskDir.IsDir() || !strings.Contains(taskDir.Name(), task) {
+				continue
+			}
+
+			podRun := run
+			podRun.TaskPath = taskDir.Name()
+			if found, ok := readRun(root, podRun); ok {
+				runs = append(runs, found)
+			}
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].ModTime.After(runs[j].ModTime)
+	})
+	return runs, nil
+}
+
+// readRun sets the modification time and the log files of a run.
+func readRun(root string, run Run) (Run, bool) {
+	dir := run.Dir(root)
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return run, false
+	}
+	run.ModTime = info.ModTime()
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return run, false
+	}
+
+	run.Files = []File{}
+	for _, info := range infos {
+		if info.IsDir() || !reader.IsLogFile(info.Name()) {
+			continue
+		}
+
+		run.Files = append(run.Files, File{Name: info.Name(), Size: info.Size()})
+		if info.ModTime().After(run.ModTime) {
+			run.ModTime = info.ModTime()
+		}
+	}
+	return run, true
+}
+
+// FindAgentID returns the ID of the agent which ran the executor. After the agent lost its checkpoint, the
+// sandboxes of its previous runs are kept in the directory of the previous agent ID.
+func FindAgentID(root, frameworkID, executorID, containerID string) (string, error) {
+	agents, err := ioutil.ReadDir(root)
+	if err != nil {
+		return "", err
+	}
+
+	for _, agent := range agents {
+		runDir := filepath.Join(root, agent.Name(), "frameworks", frameworkID, "executors", executorID, "runs", containerID)
+		if info, err := os.Stat(runDir); err == nil && info.IsDir() {
+			return agent.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("sandbox of executor %s run %s not found", executorID, containerID)
+}
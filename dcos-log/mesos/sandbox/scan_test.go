@@ -0,0 +1,105 @@
+package sandbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newSandboxRoot creates the sandbox root with the files, by path relative to the root.
+func newSandboxRoot(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "dcos-log-sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	for name, content := range files {
+		filePath := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestScan(t *testing.T) {
+	root := newSandboxRoot(t, map[string]string{
+		"S1/frameworks/F1/executors/app.1/runs/C1/stdout":                      "old",
+		"S2/frameworks/F1/executors/app.2/runs/C2/stdout":                      "new",
+		"S2/frameworks/F1/executors/app.2/runs/C2/stderr":                      "error",
+		"S2/frameworks/F1/executors/app.2/runs/C2/config.yml":                  "config",
+		"S2/frameworks/F1/executors/other.3/runs/C3/stdout":                    "other",
+		"S2/frameworks/F2/executors/instance-pod/runs/C4/tasks/pod.app/stdout": "pod",
+	})
+
+	// the runs are returned the latest first.
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "S1/frameworks/F1/executors/app.1/runs/C1/stdout"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(root, "S1/frameworks/F1/executors/app.1/runs/C1"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	latest := filepath.Join(root, "S2/frameworks/F1/executors/app.2/runs/latest")
+	if err := os.Symlink(filepath.Join(root, "S2/frameworks/F1/executors/app.2/runs/C2"), latest); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := Scan(root, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runs) != 3 {
+		t.Fatalf("expect 3 runs. Got %+v", runs)
+	}
+
+	if runs[2].AgentID != "S1" || runs[2].ExecutorID != "app.1" || runs[2].ContainerID != "C1" {
+		t.Fatalf("expect the old run last. Got %+v", runs[2])
+	}
+
+	for _, run := range runs {
+		switch run.ContainerID {
+		case "C2":
+			if len(run.Files) != 2 || run.Files[0].Name != "stderr" || run.Files[0].Size != 5 {
+				t.Fatalf("expect stderr and stdout. Got %+v", run.Files)
+			}
+		case "C4":
+			if run.TaskPath != "pod.app" || run.FrameworkID != "F2" {
+				t.Fatalf("expect pod task pod.app. Got %+v", run)
+			}
+		}
+	}
+
+	runs, err = Scan(root, "missing")
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("expect no runs. Got %+v, %v", runs, err)
+	}
+
+	if _, err := Scan(root, ""); err == nil {
+		t.Fatal("expect an error for an empty task ID")
+	}
+}
+
+func TestFindAgentID(t *testing.T) {
+	root := newSandboxRoot(t, map[string]string{
+		"S1/frameworks/F1/executors/E1/runs/C1/stdout": "",
+	})
+
+	agentID, err := FindAgentID(root, "F1", "E1", "C1")
+	if err != nil || agentID != "S1" {
+		t.Fatalf("expect agent S1. Got %s, %v", agentID, err)
+	}
+
+	if _, err := FindAgentID(root, "F1", "E1", "C2"); err == nil {
+		t.Fatal("expect an error for a missing run")
+	}
+}
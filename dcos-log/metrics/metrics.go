@@ -0,0 +1,195 @@
+// Package metrics wraps the Prometheus client metric vectors with the constructors used by dcos-log.
+// The metrics are registered in DefaultRegistry and exposed in the Prometheus text exposition format.
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// ContentType is a content type of the Prometheus text format.
+const ContentType = string(expfmt.FmtText)
+
+// DefaultBuckets are the default histogram buckets in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// DefaultRegistry is a registry used by the metric constructors. It also exposes the Go runtime and
+// process metrics.
+var DefaultRegistry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return r
+}
+
+// Registry holds the registered metrics.
+type Registry struct {
+	registry *prometheus.Registry
+}
+
+// NewRegistry returns a new empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{registry: prometheus.NewRegistry()}
+}
+
+// WriteTo writes all the registered metrics sorted by name in the text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	families, err := r.registry.Gather()
+	if err != nil {
+		return 0, err
+	}
+
+	var written int64
+	for _, family := range families {
+		n, err := expfmt.MetricFamilyToText(w, family)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Handler returns an http.Handler which serves the metrics from the registry.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
+
+// Handler returns an http.Handler which serves the metrics from DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// Counter is a metric which value only goes up.
+type Counter struct {
+	vec *prometheus.CounterVec
+}
+
+// NewCounter registers and returns a new Counter with the given label names in DefaultRegistry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+// NewCounter registers and returns a new Counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)}
+	r.registry.MustRegister(c.vec)
+
+	// a metric without labels is always exposed.
+	if len(labels) == 0 {
+		c.vec.WithLabelValues()
+	}
+	return c
+}
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Inc()
+}
+
+// Add increments the counter by the given non-negative value.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Add(delta)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value(labelValues ...string) float64 {
+	m := &dto.Metric{}
+	if err := c.vec.WithLabelValues(labelValues...).Write(m); err != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
+
+// Gauge is a metric which value can go up and down.
+type Gauge struct {
+	vec *prometheus.GaugeVec
+}
+
+// NewGauge registers and returns a new Gauge with the given label names in DefaultRegistry.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+// NewGauge registers and returns a new Gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)}
+	r.registry.MustRegister(g.vec)
+
+	// a metric without labels is always exposed.
+	if len(labels) == 0 {
+		g.vec.WithLabelValues()
+	}
+	return g
+}
+
+// Inc increments the gauge by 1.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Inc()
+}
+
+// Dec decrements the gauge by 1.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Dec()
+}
+
+// Add adds the given value to the gauge.
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Add(delta)
+}
+
+// Set sets the gauge to the given value.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Set(value)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	m := &dto.Metric{}
+	if err := g.vec.WithLabelValues(labelValues...).Write(m); err != nil {
+		return 0
+	}
+	return m.GetGauge().GetValue()
+}
+
+// Histogram samples observations and counts them in the configurable buckets.
+type Histogram struct {
+	vec *prometheus.HistogramVec
+}
+
+// NewHistogram registers and returns a new Histogram in DefaultRegistry. If buckets are nil, DefaultBuckets
+// are used.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram registers and returns a new Histogram. If buckets are nil, DefaultBuckets are used.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	// the client requires the buckets in increasing order.
+	sortedBuckets := append([]float64{}, buckets...)
+	sort.Float64s(sortedBuckets)
+
+	h := &Histogram{prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help,
+		Buckets: sortedBuckets,
+	}, labels)}
+	r.registry.MustRegister(h.vec)
+	return h
+}
+
+// Observe adds a single observation to the histogram.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.vec.WithLabelValues(labelValues...).Observe(value)
+}
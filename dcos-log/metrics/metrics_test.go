@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	// a registry per test keeps the values independent of the other tests and -count.
+	r := NewRegistry()
+	testCounter := r.NewCounter("test_requests_total", "Total test requests.", "code")
+	testGauge := r.NewGauge("test_active", "Active test items.")
+	testHistogram := r.NewHistogram("test_duration_seconds", "Test duration.", []float64{1, 0.1}, "route")
+
+	testCounter.Inc("200")
+	testCounter.Add(2, "200")
+	testCounter.Inc(`5"00`)
+	testGauge.Inc()
+	testGauge.Inc()
+	testGauge.Dec()
+	testHistogram.Observe(0.05, "/v1/range/")
+	testHistogram.Observe(0.5, "/v1/range/")
+	testHistogram.Observe(5, "/v1/range/")
+
+	if v := testCounter.Value("200"); v != 3 {
+		t.Fatalf("expect counter value 3. Got %f", v)
+	}
+
+	if v := testGauge.Value(); v != 1 {
+		t.Fatalf("expect gauge value 1. Got %f", v)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := r.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+	for _, expected := range []string{
+		"# HELP test_requests_total Total test requests.\n# TYPE test_requests_total counter\n",
+		`test_requests_total{code="200"} 3` + "\n",
+		`test_requests_total{code="5\"00"} 1` + "\n",
+		"# TYPE test_active gauge\ntest_active 1\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{route="/v1/range/",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{route="/v1/range/",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{route="/v1/range/",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{route="/v1/range/"} 5.55` + "\n",
+		`test_duration_seconds_count{route="/v1/range/"} 3` + "\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expect %q in output:\n%s", expected, output)
+		}
+	}
+
+	// metrics must be sorted by name
+	if strings.Index(output, "test_active") > strings.Index(output, "test_requests_total") {
+		t.Fatalf("expect sorted output. Got %s", output)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200. Got %d", w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Fatalf("expect content type %s. Got %s", ContentType, ct)
+	}
+
+	if !strings.Contains(w.Body.String(), "go_goroutines ") {
+		t.Fatalf("expect the go runtime metrics. Got %s", w.Body.String())
+	}
+}
+
+func TestLabelsMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect panic on wrong number of label values")
+		}
+	}()
+
+	NewRegistry().NewCounter("test_requests_total", "Total test requests.", "code").Inc()
+}
@@ -0,0 +1,87 @@
+// Package multiline groups the lines of multi-line log events, e.g. stack traces, into single entries.
+package multiline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// DefaultMaxLines is a maximum number of lines grouped into a single event. The following lines start a new event.
+const DefaultMaxLines = 500
+
+// presets match the continuation lines of the stack traces.
+var presets = map[string]*regexp.Regexp{
+	// \tat com.example.Main.main(Main.java:10), ... 5 more, Caused by: ..., the exception line after a log message.
+	"java": regexp.MustCompile(`^(\s+at\s|\s+\.\.\.\s+\d+\s+(more|common frames omitted)|\s*Caused by:|\s*Suppressed:|` +
+		`[\w$.]+(Exception|Error|Throwable)(:.*)?$|\s*$)`),
+
+	// Traceback (most recent call last):, the indented frames, ValueError: message.
+	"python": regexp.MustCompile(`^(\s|Traceback \(most recent call last\):|During handling of the above exception|` +
+		`The above exception was the direct cause|[\w.]+(Error|Exception|Exit|Interrupt|Warning)(:.*)?$|$)`),
+
+	// goroutine 1 [running]:, main.main(), the indented file lines, exit status 2.
+	"go": regexp.MustCompile(`^(\s|goroutine \d+ \[.*\]:$|[\w.\-/*()]+\(.*\)$|\[signal |created by |exit status \d+$|$)`),
+}
+
+// Presets returns the names of the built-in patterns.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pattern decides which lines continue the previous event, e.g. the lines of a stack trace following the log message.
+type Pattern struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+
+	// MaxLines is a maximum number of lines in an event.
+	MaxLines int
+}
+
+// New returns a Pattern with a regular expression matching the first line of an event. The lines which do not
+// match continue the previous event.
+func New(start string) (*Pattern, error) {
+	re, err := regexp.Compile(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multiline start pattern %q: %s", start, err)
+	}
+
+	return &Pattern{start: re, MaxLines: DefaultMaxLines}, nil
+}
+
+// Preset returns a built-in Pattern grouping the stack traces of a language.
+func Preset(name string) (*Pattern, error) {
+	re, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown multiline preset %q. Supported presets: %v", name, Presets())
+	}
+
+	return &Pattern{continuation: re, MaxLines: DefaultMaxLines}, nil
+}
+
+// Parse returns a Pattern from a preset name or a start pattern, at most one can be set. If both are empty,
+// nil is returned.
+func Parse(preset, start string) (*Pattern, error) {
+	switch {
+	case preset != "" && start != "":
+		return nil, fmt.Errorf("multiline preset and start pattern cannot be used at the same time")
+	case preset != "":
+		return Preset(preset)
+	case start != "":
+		return New(start)
+	}
+	return nil, nil
+}
+
+// Continues returns true if the line belongs to the previous event.
+func (p *Pattern) Continues(line string) bool {
+	if p.start != nil {
+		return !p.start.MatchString(line)
+	}
+	return p.continuation.MatchString(line)
+}
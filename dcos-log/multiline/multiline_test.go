@@ -0,0 +1,107 @@
+package multiline
+
+import (
+	"strings"
+	"testing"
+)
+
+// group returns the events of the lines grouped with the pattern.
+func group(p *Pattern, lines []string) []string {
+	var events []string
+	for _, line := range lines {
+		if len(events) > 0 && p.Continues(line) {
+			events[len(events)-1] += "\n" + line
+			continue
+		}
+		events = append(events, line)
+	}
+	return events
+}
+
+func TestPresets(t *testing.T) {
+	for _, tc := range []struct {
+		preset string
+		events []string
+	}{
+		{
+			preset: "java",
+			events: []string{
+				"2018-01-02 10:00:00 INFO started",
+				"2018-01-02 10:00:01 ERROR request failed\n" +
+					"java.lang.IllegalStateException: bad state\n" +
+					"\tat com.example.Service.handle(Service.java:42)\n" +
+					"\tat com.example.Main.main(Main.java:10)\n" +
+					"Caused by: java.io.IOException: closed\n" +
+					"\tat com.example.Conn.read(Conn.java:7)\n" +
+					"\t... 2 more",
+				"2018-01-02 10:00:02 INFO done",
+			},
+		},
+		{
+			preset: "python",
+			events: []string{
+				"ERROR:root:request failed\n" +
+					"Traceback (most recent call last):\n" +
+					"  File \"app.py\", line 10, in <module>\n" +
+					"    main()\n" +
+					"ValueError: bad value",
+				"INFO:root:done",
+			},
+		},
+		{
+			preset: "go",
+			events: []string{
+				"starting server",
+				"panic: runtime error: index out of range\n" +
+					"\n" +
+					"goroutine 1 [running]:\n" +
+					"main.handle(0xc000010000, 0x1)\n" +
+					"\t/src/main.go:12 +0x1d\n" +
+					"github.com/example/app.(*Server).Serve(...)\n" +
+					"\t/src/server.go:30\n" +
+					"exit status 2",
+				"restarting",
+			},
+		},
+	} {
+		p, err := Preset(tc.preset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events := group(p, strings.Split(strings.Join(tc.events, "\n"), "\n"))
+		if strings.Join(events, "|") != strings.Join(tc.events, "|") {
+			t.Fatalf("%s: expect events %q. Got %q", tc.preset, tc.events, events)
+		}
+	}
+}
+
+func TestStartPattern(t *testing.T) {
+	p, err := New(`^\[\d{4}-`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := group(p, []string{"[2018-01-02] first", "details", "[2018-01-02] second"})
+	if len(events) != 2 || events[0] != "[2018-01-02] first\ndetails" {
+		t.Fatalf("expect 2 events. Got %q", events)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if p, err := Parse("", ""); p != nil || err != nil {
+		t.Fatalf("expect no pattern. Got %v, %v", p, err)
+	}
+
+	for _, args := range [][2]string{{"java", ""}, {"", "^start"}} {
+		if p, err := Parse(args[0], args[1]); p == nil || err != nil {
+			t.Fatalf("%q: expect a pattern. Got %v", args, err)
+		}
+	}
+
+	for _, args := range [][2]string{{"cobol", ""}, {"", "("}, {"java", "^start"}} {
+		if _, err := Parse(args[0], args[1]); err == nil {
+			t.Fatalf("%q: expect an error", args)
+		}
+	}
+}
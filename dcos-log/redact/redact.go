@@ -0,0 +1,172 @@
+package redact
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/dcos/dcos-log/dcos-log/metrics"
+)
+
+// DefaultReplacement is used if a rule does not define a replacement text.
+const DefaultReplacement = "[REDACTED]"
+
+var redactionsTotal = metrics.NewCounter("dcos_log_redactions_total",
+	"Total number of redactions made by rule.", "rule")
+
+// Rule is a redaction rule defined in dcos-log config file.
+type Rule struct {
+	// Name is used to identify the rule in the counters. If empty, the pattern is used.
+	Name string `json:"name,omitempty"`
+
+	// Pattern is a regular expression matching the sensitive data.
+	Pattern string `json:"pattern"`
+
+	// Replacement is a text the matches are replaced with. Submatches can be referenced with $1, ${name} etc.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+type compiledRule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement []byte
+	count       uint64
+}
+
+// Redactor applies redaction rules to the log content. A nil *Redactor is valid and does not modify
+// the content.
+type Redactor struct {
+	rules []*compiledRule
+}
+
+// New compiles the rules and returns a new instance of Redactor. If no rules are given, nil is returned.
+func New(rules []Rule) (*Redactor, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	r := &Redactor{}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %s", rule.Pattern, err)
+		}
+
+		name := rule.Name
+		if name == "" {
+			name = rule.Pattern
+		}
+
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = DefaultReplacement
+		}
+
+		r.rules = append(r.rules, &compiledRule{
+			name:        name,
+			re:          re,
+			replacement: []byte(replacement),
+		})
+	}
+
+	return r, nil
+}
+
+// RedactBytes returns a copy of b with all the rules applied. If nothing matched, b is returned.
+func (r *Redactor) RedactBytes(b []byte) []byte {
+	if r == nil {
+		return b
+	}
+
+	for _, rule := range r.rules {
+		matches := rule.re.FindAllSubmatchIndex(b, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var (
+			redacted []byte
+			last     int
+		)
+		for _, match := range matches {
+			redacted = append(redacted, b[last:match[0]]...)
+			redacted = rule.re.Expand(redacted, rule.replacement, b, match)
+			last = match[1]
+		}
+		b = append(redacted, b[last:]...)
+
+		atomic.AddUint64(&rule.count, uint64(len(matches)))
+		redactionsTotal.Add(float64(len(matches)), rule.name)
+	}
+
+	return b
+}
+
+// Redact returns a string with all the rules applied.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+
+	return string(r.RedactBytes([]byte(s)))
+}
+
+// Counts returns a number of redactions made by each rule since the start.
+func (r *Redactor) Counts() map[string]uint64 {
+	counts := make(map[string]uint64)
+	if r == nil {
+		return counts
+	}
+
+	for _, rule := range r.rules {
+		counts[rule.name] += atomic.LoadUint64(&rule.count)
+	}
+	return counts
+}
+
+// Total returns a total number of redactions made since the start.
+func (r *Redactor) Total() (total uint64) {
+	for _, count := range r.Counts() {
+		total += count
+	}
+	return total
+}
+
+// NewReader returns an io.Reader which applies the redaction rules to every line read from rd.
+// The rules are applied line by line, so patterns spanning multiple lines never match.
+func (r *Redactor) NewReader(rd io.Reader) io.Reader {
+	if r == nil {
+		return rd
+	}
+
+	return &reader{
+		redactor: r,
+		src:      bufio.NewReader(rd),
+	}
+}
+
+type reader struct {
+	redactor *Redactor
+	src      *bufio.Reader
+	buf      bytes.Buffer
+	err      error
+}
+
+func (r *reader) Read(b []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		line, err := r.src.ReadBytes('\n')
+		if len(line) > 0 {
+			r.buf.Write(r.redactor.RedactBytes(line))
+		}
+		r.err = err
+	}
+
+	return r.buf.Read(b)
+}
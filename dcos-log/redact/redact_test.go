@@ -0,0 +1,88 @@
+package redact
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	r, err := New([]Rule{
+		{
+			Name:    "password",
+			Pattern: `(password=)\S+`,
+			// keep the key, hide the value
+			Replacement: "${1}***",
+		},
+		{
+			Name:    "token",
+			Pattern: `token=[A-Za-z0-9.]+`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for input, expected := range map[string]string{
+		"user=root password=secret":        "user=root password=***",
+		"password=a password=b":            "password=*** password=***",
+		"Authorization: token=abc.def.ghi": "Authorization: [REDACTED]",
+		"nothing to hide":                  "nothing to hide",
+	} {
+		if output := r.Redact(input); output != expected {
+			t.Fatalf("expect %q. Got %q", expected, output)
+		}
+	}
+
+	counts := r.Counts()
+	if counts["password"] != 3 {
+		t.Fatalf("expect 3 password redactions. Got %d", counts["password"])
+	}
+
+	if counts["token"] != 1 {
+		t.Fatalf("expect 1 token redaction. Got %d", counts["token"])
+	}
+
+	if r.Total() != 4 {
+		t.Fatalf("expect 4 redactions. Got %d", r.Total())
+	}
+}
+
+func TestInvalidRule(t *testing.T) {
+	if _, err := New([]Rule{{Pattern: "("}}); err == nil {
+		t.Fatal("expect an error for invalid pattern")
+	}
+}
+
+func TestNilRedactor(t *testing.T) {
+	r, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r != nil {
+		t.Fatalf("expect nil redactor without rules. Got %+v", r)
+	}
+
+	if s := r.Redact("password=secret"); s != "password=secret" {
+		t.Fatalf("nil redactor must not modify the input. Got %s", s)
+	}
+}
+
+func TestReader(t *testing.T) {
+	r, err := New([]Rule{{Pattern: `secret`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "one secret\ntwo\nthree secret secret"
+	output, err := ioutil.ReadAll(r.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "one [REDACTED]\ntwo\nthree [REDACTED] [REDACTED]"
+	if string(output) != expected {
+		t.Fatalf("expect %q. Got %q", expected, output)
+	}
+}
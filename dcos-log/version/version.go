@@ -0,0 +1,34 @@
+// Package version contains dcos-log build metadata. The variables are set at build time with
+// -ldflags "-X github.com/dcos/dcos-log/dcos-log/version.Version=..."
+package version
+
+import "runtime"
+
+var (
+	// Version is a dcos-log release version.
+	Version = "dev"
+
+	// Commit is a git commit dcos-log was built from.
+	Commit = "unknown"
+
+	// BuildDate is a date dcos-log was built.
+	BuildDate = "unknown"
+)
+
+// Info describes the running dcos-log binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build metadata.
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}